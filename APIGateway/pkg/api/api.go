@@ -50,7 +50,10 @@ func (api *API) endpoints() {
 
 	api.r.HandleFunc("/news/latest", api.latestNewsProxy).Methods(http.MethodGet)
 	api.r.HandleFunc("/news/filter", api.filterNewsProxy).Methods(http.MethodGet)
+	api.r.HandleFunc("/news/search", api.searchNewsProxy).Methods(http.MethodGet)
+	api.r.HandleFunc("/news/sources", api.newsSourcesProxy).Methods(http.MethodGet)
 	api.r.HandleFunc("/news/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$}", api.newsDetailedProxy).Methods(http.MethodGet)
+	api.r.HandleFunc("/news/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/revisions", api.newsRevisionsProxy).Methods(http.MethodGet)
 
 	api.r.HandleFunc("/comments", api.createCommentProxy).Methods(http.MethodPost)
 }
@@ -68,71 +71,86 @@ func New(name string, services map[string]Service, kafkaWriter *kafka.Writer) (*
 }
 
 func (api *API) latestNewsProxy(w http.ResponseWriter, r *http.Request) {
+	page, limit, cursor := parsePagination(r, 100)
+
+	params := url.Values{}
+	setPagination(params, page, limit, cursor)
+	copyParams(params, r.URL.Query(), "source", "lang", "brief")
+
+	api.aggregatorProxy(w, r, "latestNewsProxy", "/news/latest", params, http.StatusInternalServerError)
+}
+
+func (api *API) filterNewsProxy(w http.ResponseWriter, r *http.Request) {
 	reqID := GetRequestID(r.Context())
 	sID := shorten(reqID)
 
-	page, limit := parsePagination(r, 100)
-	targetURL := fmt.Sprintf("%s/news/latest?page=%d&limit=%d", api.Services["Aggregator"].URL, page, limit)
-
-	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL, nil)
-	if err != nil {
-		log.Errorf("[latestNewsProxy][%s] error creating proxy request %s %s: %v", sID, r.Method, targetURL, err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	contains := r.URL.Query().Get("contains")
+	if contains == "" {
+		log.Debugf("[filterNewsProxy][%s] empty contains parameter", sID)
+		http.Error(w, "Empty contains parameter", http.StatusBadRequest)
 		return
 	}
 
-	proxyReq.Header = cloneHeaderNoHop(r.Header)
-	if reqID != "" {
-		proxyReq.Header.Set("X-Request-Id", reqID)
-	}
+	page, limit, cursor := parsePagination(r, 100)
 
-	client := &http.Client{Timeout: httpClientTimeout}
+	params := url.Values{}
+	params.Set("contains", contains)
+	setPagination(params, page, limit, cursor)
+	copyParams(params, r.URL.Query(), "source", "lang", "brief")
 
-	resp, err := client.Do(proxyReq)
-	if err != nil {
-		log.Errorf("[latestNewsProxy][%s] error proxying request %s %s: %v", sID, r.Method, targetURL, err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	api.aggregatorProxy(w, r, "filterNewsProxy", "/news/filter", params, http.StatusBadGateway)
+}
+
+// searchNewsProxy returns the posts found by the full-text search of the
+// aggregator, the most relevant first.
+func (api *API) searchNewsProxy(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		log.Debugf("[searchNewsProxy][%s] empty q parameter", sID)
+		http.Error(w, "Empty q parameter", http.StatusBadRequest)
 		return
 	}
-	defer resp.Body.Close()
 
-	for k, vv := range resp.Header {
-		for _, v := range vv {
-			w.Header().Set(k, v)
-		}
-	}
+	// The search results are ranked, so they are only paginated by numbers.
+	page, limit, _ := parsePagination(r, 100)
 
-	w.WriteHeader(resp.StatusCode)
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(limit))
+	copyParams(params, r.URL.Query(), "source", "lang", "brief")
 
-	if _, err := io.Copy(w, resp.Body); err != nil {
-		log.Errorf("[latestNewsProxy][%s] error copying response body: %v", sID, err)
-	}
+	api.aggregatorProxy(w, r, "searchNewsProxy", "/news/search", params, http.StatusBadGateway)
+}
 
-	log.Debugf("[latestNewsProxy][%s] response sent to %v", sID, r.RemoteAddr)
+func (api *API) newsSourcesProxy(w http.ResponseWriter, r *http.Request) {
+	api.aggregatorProxy(w, r, "newsSourcesProxy", "/news/sources", nil, http.StatusBadGateway)
 }
 
-func (api *API) filterNewsProxy(w http.ResponseWriter, r *http.Request) {
+// newsRevisionsProxy returns the changes of a post made by the updates of its feed.
+func (api *API) newsRevisionsProxy(w http.ResponseWriter, r *http.Request) {
+	api.aggregatorProxy(w, r, "newsRevisionsProxy", "/news/"+mux.Vars(r)["id"]+"/revisions", nil, http.StatusBadGateway)
+}
+
+// aggregatorProxy forwards the request to the given path of the news aggregator
+// with the given query parameters and copies the response back to the client.
+// The handler name is used as the log prefix. If the aggregator can't be reached,
+// the client gets the unavailable status code.
+func (api *API) aggregatorProxy(w http.ResponseWriter, r *http.Request, handler, path string, params url.Values, unavailable int) {
 	reqID := GetRequestID(r.Context())
 	sID := shorten(reqID)
 
-	contains := r.URL.Query().Get("contains")
-	if contains == "" {
-		log.Debugf("[filterNewsProxy][%s] empty contains parameter", sID)
-		http.Error(w, "Empty contains parameter", http.StatusBadRequest)
-		return
+	targetURL := api.Services["Aggregator"].URL + path
+	if len(params) > 0 {
+		targetURL += "?" + params.Encode()
 	}
 
-	page, limit := parsePagination(r, 100)
-
-	targetURL := fmt.Sprintf(
-		"%s/news/filter?contains=%s&page=%d&limit=%d",
-		api.Services["Aggregator"].URL,
-		url.QueryEscape(contains),
-		page, limit)
-
 	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL, nil)
 	if err != nil {
-		log.Errorf("[filterNewsProxy][%s] error creating proxy request: %v", sID, err)
+		log.Errorf("[%s][%s] error creating proxy request %s %s: %v", handler, sID, r.Method, targetURL, err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -145,8 +163,8 @@ func (api *API) filterNewsProxy(w http.ResponseWriter, r *http.Request) {
 	client := &http.Client{Timeout: httpClientTimeout}
 	resp, err := client.Do(proxyReq)
 	if err != nil {
-		log.Errorf("[filterNewsProxy][%s] error calling news aggregator: %v", sID, err)
-		http.Error(w, "News Aggregator Unavailable", http.StatusBadGateway)
+		log.Errorf("[%s][%s] error calling news aggregator: %v", handler, sID, err)
+		http.Error(w, "News Aggregator Unavailable", unavailable)
 		return
 	}
 	defer resp.Body.Close()
@@ -160,10 +178,10 @@ func (api *API) filterNewsProxy(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(resp.StatusCode)
 
 	if _, err := io.Copy(w, resp.Body); err != nil {
-		log.Errorf("[filterNewsProxy][%s] error copying response body: %v", sID, err)
+		log.Errorf("[%s][%s] error copying response body: %v", handler, sID, err)
 	}
 
-	log.Debugf("[filterNewsProxy][%s] response sent to %v", sID, r.RemoteAddr)
+	log.Debugf("[%s][%s] response sent to %v", handler, sID, r.RemoteAddr)
 }
 
 func (api *API) newsDetailedProxy(w http.ResponseWriter, r *http.Request) {
@@ -396,9 +414,11 @@ func cloneHeaderNoHop(header http.Header) http.Header {
 // parsePagination extracts and validates 'page' and 'limit' query parameters from the request.
 // It returns default values if parameters are missing or invalid.
 // 'maxLimit' caps the maximum allowed limit to prevent abuse.
-func parsePagination(r *http.Request, maxLimit int) (page, limit int) {
+// The 'cursor' parameter is opaque to the gateway and is returned as is.
+func parsePagination(r *http.Request, maxLimit int) (page, limit int, cursor string) {
 	page = 1
 	limit = 10
+	cursor = r.URL.Query().Get("cursor")
 
 	pageStr := r.URL.Query().Get("page")
 	if pageStr != "" {
@@ -421,6 +441,26 @@ func parsePagination(r *http.Request, maxLimit int) (page, limit int) {
 	return
 }
 
+// setPagination sets the pagination parameters for the aggregator: the cursor
+// of the page if there is one, the page number otherwise.
+func setPagination(params url.Values, page, limit int, cursor string) {
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	} else {
+		params.Set("page", strconv.Itoa(page))
+	}
+	params.Set("limit", strconv.Itoa(limit))
+}
+
+// copyParams copies the listed query parameters from src to dst, skipping empty ones.
+func copyParams(dst, src url.Values, keys ...string) {
+	for _, key := range keys {
+		if v := src.Get(key); v != "" {
+			dst.Set(key, v)
+		}
+	}
+}
+
 // GetRequestID extracts the request ID from the context.
 // It returns the request ID as a string if present, otherwise returns an empty string.
 func GetRequestID(ctx context.Context) string {
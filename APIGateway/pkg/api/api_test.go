@@ -3,11 +3,14 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -341,3 +344,294 @@ func TestAPI_latestNewsProxy(t *testing.T) {
 		t.Errorf("want response\n%+v\n\ngot response\n%+v\n", responseSample, gotResponse)
 	}
 }
+
+func TestAPI_newsProxyUnavailable(t *testing.T) {
+	defer gock.Off()
+
+	api, err := New("", mockServices, nil)
+	if err != nil {
+		t.Fatalf("failed to create API: %v", err)
+	}
+
+	tests := []struct {
+		target         string
+		wantStatusCode int
+	}{
+		{"/news/latest", http.StatusInternalServerError},
+		{"/news/filter?contains=Go", http.StatusBadGateway},
+		{"/news/sources", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			gock.New(api.Services["Aggregator"].URL).ReplyError(errors.New("connection refused"))
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			rr := httptest.NewRecorder()
+			api.Router().ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatusCode {
+				t.Errorf("want status code %v, got status code %v", tt.wantStatusCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAPI_newsProxyNoBody(t *testing.T) {
+	defer gock.Off()
+
+	api, err := New("", mockServices, nil)
+	if err != nil {
+		t.Fatalf("failed to create API: %v", err)
+	}
+
+	var gotBody []byte
+	gock.New(api.Services["Aggregator"].URL).
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			if req.Body != nil {
+				gotBody, _ = io.ReadAll(req.Body)
+			}
+			return true, nil
+		}).
+		Reply(http.StatusOK).JSON(PostsResponse{})
+
+	// The body of a GET request is not forwarded to the aggregator.
+	req := httptest.NewRequest(http.MethodGet, "/news/latest", strings.NewReader("unexpected body"))
+	rr := httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+	if len(gotBody) != 0 {
+		t.Errorf("want no body sent to the aggregator, got %q", gotBody)
+	}
+}
+
+func TestAPI_newsProxySourceFilter(t *testing.T) {
+	defer gock.Off()
+
+	api, err := New("", mockServices, nil)
+	if err != nil {
+		t.Fatalf("failed to create API: %v", err)
+	}
+
+	source := "https://habr.com/ru/rss/hub/go/all/?fl=ru"
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "latest", path: "/news/latest", want: "/news/latest?source="},
+		{name: "filter", path: "/news/filter", want: "/news/filter?contains=go&source="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gock.New(api.Services["Aggregator"].URL).
+				Get(tt.path).
+				MatchParam("source", regexp.QuoteMeta(source)).
+				Reply(http.StatusOK).
+				JSON(PostsResponse{})
+
+			target := tt.want + url.QueryEscape(source)
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rr := httptest.NewRecorder()
+			api.Router().ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Errorf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+			}
+			if !gock.IsDone() {
+				t.Errorf("want source %q passed to aggregator", source)
+			}
+		})
+	}
+}
+
+func TestAPI_newsRevisionsProxy(t *testing.T) {
+	defer gock.Off()
+
+	api, err := New("", mockServices, nil)
+	if err != nil {
+		t.Fatalf("failed to create API: %v", err)
+	}
+
+	id := "0e0f3f31-854f-512d-b4d7-14d341155b20"
+	// The revisions are passed through as the aggregator encodes them.
+	want := `[{"changed":"2025-05-23T10:00:00Z","source":{"url":"https://habr.com/rss","name":"Habr"},` +
+		`"changes":[{"field":"title","old":"Old title","new":"New title"}]}]`
+	gock.New(api.Services["Aggregator"].URL).
+		Get("/news/" + id + "/revisions").
+		Reply(http.StatusOK).
+		BodyString(want)
+
+	req := httptest.NewRequest(http.MethodGet, "/news/"+id+"/revisions", nil)
+	rr := httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+
+	if got := rr.Body.String(); got != want {
+		t.Errorf("want revisions %s, got %s", want, got)
+	}
+	if !gock.IsDone() {
+		t.Error("want request passed to aggregator")
+	}
+}
+
+func TestAPI_latestNewsProxyBrief(t *testing.T) {
+	defer gock.Off()
+
+	api, err := New("", mockServices, nil)
+	if err != nil {
+		t.Fatalf("failed to create API: %v", err)
+	}
+
+	gock.New(api.Services["Aggregator"].URL).
+		Get("/news/latest").
+		MatchParam("brief", "true").
+		Reply(http.StatusOK).
+		JSON(PostsResponse{})
+
+	req := httptest.NewRequest(http.MethodGet, "/news/latest?brief=true", nil)
+	rr := httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+	if !gock.IsDone() {
+		t.Error("want brief parameter passed to aggregator")
+	}
+}
+
+func TestAPI_newsProxyLang(t *testing.T) {
+	defer gock.Off()
+
+	api, err := New("", mockServices, nil)
+	if err != nil {
+		t.Fatalf("failed to create API: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		target string
+	}{
+		{name: "latest", path: "/news/latest", target: "/news/latest?lang=ru"},
+		{name: "filter", path: "/news/filter", target: "/news/filter?contains=go&lang=ru"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gock.New(api.Services["Aggregator"].URL).
+				Get(tt.path).
+				MatchParam("lang", "ru").
+				Reply(http.StatusOK).
+				JSON(PostsResponse{})
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			rr := httptest.NewRecorder()
+			api.Router().ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Errorf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+			}
+			if !gock.IsDone() {
+				t.Error("want lang parameter passed to aggregator")
+			}
+		})
+	}
+}
+
+func TestAPI_searchNewsProxy(t *testing.T) {
+	defer gock.Off()
+
+	api, err := New("", mockServices, nil)
+	if err != nil {
+		t.Fatalf("failed to create API: %v", err)
+	}
+
+	query := `"go release" -beta`
+	gock.New(api.Services["Aggregator"].URL).
+		Get("/news/search").
+		MatchParam("q", regexp.QuoteMeta(query)).
+		MatchParam("lang", "en").
+		Reply(http.StatusOK).
+		JSON(PostsResponse{})
+
+	req := httptest.NewRequest(http.MethodGet, "/news/search?lang=en&q="+url.QueryEscape(query), nil)
+	rr := httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+	if !gock.IsDone() {
+		t.Errorf("want query %q passed to aggregator", query)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/news/search?q=", nil)
+	rr = httptest.NewRecorder()
+	api.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("want status code %v, got status code %v", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestAPI_newsProxyCursor(t *testing.T) {
+	defer gock.Off()
+
+	api, err := New("", mockServices, nil)
+	if err != nil {
+		t.Fatalf("failed to create API: %v", err)
+	}
+
+	cursor := "bl8yMDI1LTA2LTMwVDEyOjAwOjAwWl8"
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "latest", path: "/news/latest", want: "/news/latest?limit=5&cursor="},
+		{name: "filter", path: "/news/filter", want: "/news/filter?contains=go&cursor="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gock.New(api.Services["Aggregator"].URL).
+				Get(tt.path).
+				MatchParam("cursor", cursor).
+				ParamPresent("limit").
+				Reply(http.StatusOK).
+				JSON(PostsResponse{Pagination: Pagination{Limit: 5, NextCursor: "next", PrevCursor: "prev"}})
+
+			req := httptest.NewRequest(http.MethodGet, tt.want+cursor, nil)
+			rr := httptest.NewRecorder()
+			api.Router().ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Errorf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+			}
+			if !gock.IsDone() {
+				t.Errorf("want cursor %q passed to aggregator", cursor)
+			}
+
+			var resp PostsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to unmarshal response body: %v", err)
+			}
+			if resp.Pagination.NextCursor != "next" || resp.Pagination.PrevCursor != "prev" {
+				t.Errorf("want cursors passed back to client, got %+v", resp.Pagination)
+			}
+		})
+	}
+}
+
+func Test_setPagination(t *testing.T) {
+	params := url.Values{}
+	setPagination(params, 2, 10, "")
+	if got := params.Encode(); got != "limit=10&page=2" {
+		t.Errorf("want page parameters, got %q", got)
+	}
+
+	params = url.Values{}
+	setPagination(params, 2, 10, "abc")
+	if got := params.Encode(); got != "cursor=abc&limit=10" {
+		t.Errorf("want cursor instead of page, got %q", got)
+	}
+}
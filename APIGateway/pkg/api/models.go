@@ -7,9 +7,11 @@ import (
 )
 
 type Pagination struct {
-	TotalPages  int `json:"total_pages"`
-	CurrentPage int `json:"current_page"`
-	Limit       int `json:"limit"`
+	TotalPages  int    `json:"total_pages"`
+	CurrentPage int    `json:"current_page"`
+	Limit       int    `json:"limit"`
+	NextCursor  string `json:"next_cursor,omitempty"`
+	PrevCursor  string `json:"prev_cursor,omitempty"`
 }
 
 type PostsResponse struct {
@@ -7,12 +7,40 @@ import (
 )
 
 type Post struct {
-	ID        uuid.UUID `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Published time.Time `json:"published"`
-	Link      string    `json:"link"`
-	Comments  []Comment `json:"comments,omitempty"`
+	ID         uuid.UUID   `json:"id"`
+	Title      string      `json:"title"`
+	Content    string      `json:"content,omitempty"`
+	Summary    string      `json:"summary"`
+	Published  time.Time   `json:"published"`
+	Link       string      `json:"link"`
+	Source     Source      `json:"source"`
+	Authors    []string    `json:"authors,omitempty"`
+	Categories []string    `json:"categories,omitempty"`
+	ImageURL   string      `json:"image_url,omitempty"`
+	Enclosures []Enclosure `json:"enclosures,omitempty"`
+	Lang       string      `json:"lang,omitempty"`
+	Alternates []Alternate `json:"alternates,omitempty"`
+	Comments   []Comment   `json:"comments,omitempty"`
+}
+
+// Alternate is another copy of the same story, published under another link or by another feed.
+type Alternate struct {
+	ID     uuid.UUID `json:"id"`
+	Link   string    `json:"link"`
+	Source Source    `json:"source"`
+}
+
+// Enclosure is a media file attached to a post.
+type Enclosure struct {
+	URL    string `json:"url"`
+	Type   string `json:"type"`
+	Length int64  `json:"length,omitempty"`
+}
+
+// Source identifies the feed a post was taken from.
+type Source struct {
+	URL  string `json:"url"`
+	Name string `json:"name"`
 }
 
 type Preview struct {
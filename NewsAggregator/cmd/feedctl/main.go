@@ -0,0 +1,142 @@
+// Command feedctl imports and exports the feed subscriptions of the aggregator as OPML.
+//
+//	feedctl export [-addr URL | -config PATH] [-o FILE]
+//	feedctl import [-addr URL] FILE
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"news/pkg/api"
+	"news/pkg/opml"
+	"news/pkg/rss"
+)
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "import":
+		err = importFeeds(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalf("[feedctl] %s: %v", os.Args[1], err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: feedctl export [-addr URL | -config PATH] [-o FILE]")
+	fmt.Fprintln(os.Stderr, "       feedctl import [-addr URL] FILE")
+	os.Exit(2)
+}
+
+// export writes the feeds of a running aggregator, or of an RSS parser config
+// file if -config is set, as OPML.
+func export(args []string) error {
+	var addr, configPath, out string
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.StringVar(&addr, "addr", "http://localhost:8066", "News Aggregator address.")
+	fs.StringVar(&configPath, "config", "", "Export the feeds of this RSS parser config instead of the aggregator ones.")
+	fs.StringVar(&out, "o", "", "Output file, stdout if not set.")
+	fs.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if configPath != "" {
+		conf, err := rss.LoadConf(configPath)
+		if err != nil {
+			return err
+		}
+		return opml.Write(w, "News Aggregator", conf.RSS)
+	}
+
+	resp, err := request(http.MethodGet, addr+"/feeds/export.opml", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// importFeeds sends an OPML file to a running aggregator and prints the result.
+func importFeeds(args []string) error {
+	var addr string
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.StringVar(&addr, "addr", "http://localhost:8066", "News Aggregator address.")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	b, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	resp, err := request(http.MethodPost, addr+"/feeds/import", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var res api.ImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("unable to decode response: %w", err)
+	}
+	fmt.Printf("created: %d, updated: %d, skipped: %d\n", res.Created, res.Updated, res.Skipped)
+
+	return nil
+}
+
+// request sends a request to the aggregator and returns the response if its status is 2xx.
+func request(method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Request-Id", uuid.Must(uuid.NewV4()).String())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -18,10 +19,13 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"news/pkg/api"
+	"news/pkg/retention"
 	"news/pkg/rss"
 	"news/pkg/storage"
 	"news/pkg/storage/memdb"
 	"news/pkg/storage/postgres"
+	"news/pkg/storage/sqlite"
+	"news/pkg/websub"
 )
 
 type Config struct {
@@ -31,6 +35,17 @@ type Config struct {
 	KafkaAddr   string `toml:"kafkaAddr"`
 	KafkaTopic  string `toml:"kafkaTopic"`
 	KafkaBatch  int    `toml:"kafkaBatch"`
+	RSSConfig   string `toml:"rssConfig"` // Path to the JSON config of the RSS parser.
+
+	Storage   StorageConfig    `toml:"storage"`
+	Retention retention.Config `toml:"retention"`
+	WebSub    websub.Config    `toml:"websub"`
+}
+
+// StorageConfig selects the storage of the posts and the feeds.
+type StorageConfig struct {
+	Driver string `toml:"driver"` // One of "postgres", "sqlite" and "memory", postgres if empty.
+	Path   string `toml:"path"`   // Database file of the sqlite driver.
 }
 
 func main() {
@@ -39,6 +54,7 @@ func main() {
 		dev bool
 
 		configPath string
+		rssPath    string
 		httpAddr   string
 		logLevel   string
 		kafkaAddr  string
@@ -48,13 +64,15 @@ func main() {
 
 	var (
 		sigChan = make(chan os.Signal, 1)
+		hupChan = make(chan os.Signal, 1)
 		msgChan = make(chan rss.ParserMsg)
-		done    = make(chan struct{})
 	)
 
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(hupChan, syscall.SIGHUP)
 	flag.StringVar(&configPath, "config", "cmd/server/config.toml", "Path to TOML config file")
-	flag.BoolVar(&dev, "dev", false, "Run the server in development mode with in-memory DB.")
+	flag.StringVar(&rssPath, "rss", "", "Path to JSON config file of the RSS parser, reloaded on SIGHUP or change.")
+	flag.BoolVar(&dev, "dev", false, "Run the server in development mode with in-memory DB, whatever the storage config.")
 	flag.StringVar(&httpAddr, "http", ":8066", "HTTP server address in the form 'host:port'.")
 	flag.StringVar(&logLevel, "log", "info", "Log level: debug, info, warn, error.")
 	flag.StringVar(&kafkaAddr, "kafka", "", "Kafka server address in the form 'host:port'.")
@@ -82,6 +100,12 @@ func main() {
 	if kafkaBatch != 0 {
 		cfg.KafkaBatch = kafkaBatch
 	}
+	if rssPath != "" {
+		cfg.RSSConfig = rssPath
+	}
+	if cfg.RSSConfig == "" {
+		cfg.RSSConfig = "cmd/server/config.json"
+	}
 
 	if !strings.Contains(httpAddr, ":") {
 		log.Warn("[server] use ':' before port number, e.g. ':8080'")
@@ -98,8 +122,11 @@ func main() {
 		log.SetLevel(log.ErrorLevel)
 	}
 
-	switch dev {
-	case false:
+	if dev {
+		cfg.Storage.Driver = "memory"
+	}
+	switch cfg.Storage.Driver {
+	case "", "postgres":
 		conf := postgres.Config{
 			User:     "postgres",
 			Password: os.Getenv("POSTGRES_PASSWORD"),
@@ -126,12 +153,30 @@ func main() {
 		log.Infof("[server] connected to postgres: %s", conf)
 		sdb = db
 
-	case true:
+	case "sqlite":
+		if cfg.Storage.Path == "" {
+			log.Fatal("[server] invalid storage config: sqlite requires path")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db, err := sqlite.New(ctx, cfg.Storage.Path)
+		if err != nil {
+			log.Fatalf("[server] unable to open sqlite DB %s: %v", cfg.Storage.Path, err)
+		}
+		defer db.Close()
+		log.Infof("[server] running with sqlite DB %s", cfg.Storage.Path)
+		sdb = db
+
+	case "memory":
 		log.Info("[server] running with in memory DB")
 		sdb = memdb.New()
+
+	default:
+		log.Fatalf("[server] invalid storage config: unknown driver %q", cfg.Storage.Driver)
 	}
 
-	conf, err := rss.LoadConf("cmd/server/config.json")
+	conf, err := rss.LoadConf(cfg.RSSConfig)
 	if err != nil {
 		log.Fatalf("[server] unable to load RSS parser config: %v", err)
 	}
@@ -151,11 +196,52 @@ func main() {
 		log.Warnf("[server] kafka was not configured, logs will not be sent to Kafka")
 	}
 
-	api := api.New(cfg.ServiceName, sdb, kafkaWriter)
-	parser := rss.NewParser(*conf)
+	state, err := rss.LoadState(conf.StateFile)
+	if err != nil {
+		log.Fatalf("[server] unable to load RSS feed state: %v", err)
+	}
 
+	seedCtx, seedCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	feeds, err := seedFeeds(seedCtx, sdb, conf.RSS)
+	seedCancel()
+	if err != nil {
+		log.Fatalf("[server] unable to load feeds: %v", err)
+	}
+
+	parser := rss.NewParser(*conf, state)
+	parser.SetFeeds(feeds)
+	api := api.New(cfg.ServiceName, sdb, parser, kafkaWriter)
+
+	runCtx, stop := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
 
+	var subscriber *websub.Subscriber
+	if cfg.WebSub.Enabled() {
+		subscriber, err = websub.New(cfg.WebSub, nil)
+		if err != nil {
+			log.Fatalf("[server] invalid websub config: %v", err)
+		}
+		subscriber.Deliver = func(ctx context.Context, feedURL string, body []byte) {
+			storePosts(ctx, sdb, parser, parser.Push(ctx, feedURL, body))
+		}
+		subscriber.Wanted = func(feedURL string) bool {
+			return slices.ContainsFunc(parser.Feeds(), func(f storage.Feed) bool { return f.URL == feedURL && f.Enabled })
+		}
+		parser.Subscriptions = subscriber
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				log.Info("[server] websub subscriber stopped")
+				wg.Done()
+			}()
+
+			subscriber.Run(runCtx)
+		}()
+	} else {
+		log.Info("[server] websub callback URL is not set, feeds are only polled")
+	}
+
 	wg.Add(1)
 	go func() {
 		defer func() {
@@ -167,14 +253,11 @@ func main() {
 		defer cancel()
 
 		for msg := range msgChan {
-			if msg.Err != nil {
-				log.Warnf("[server] error while parsing %s: %v", msg.Source, msg.Err)
-			} else {
-				err := api.DB.AddPosts(ctx, storage.ValidatePosts(msg.Data...))
-				if err != nil {
-					log.Warnf("[server] error while adding posts from %s to DB: %v", msg.Source, err)
-				} else {
-					log.Infof("[server] DB updated with posts from %s", msg.Source)
+			storePosts(ctx, api.DB, parser, msg)
+
+			if subscriber != nil && msg.Hub != "" {
+				if err := subscriber.Subscribe(ctx, msg.Source, msg.Hub, msg.Topic); err != nil {
+					log.Warnf("[server] %v", err)
 				}
 			}
 		}
@@ -182,29 +265,58 @@ func main() {
 
 	wg.Add(1)
 	go func() {
-		ticker := time.NewTicker(parser.Delay)
-
 		defer func() {
 			close(msgChan)
-			ticker.Stop()
 			log.Info("[server] parser stopped")
 			wg.Done()
 		}()
 
-		parser.Run(msgChan)
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				parser.Run(msgChan)
-			}
-		}
+		// Run returns once the in-flight fetches are over, so nothing is sent to the closed channel.
+		parser.Run(runCtx, msgChan)
+	}()
+
+	watcher := rss.NewConfigWatcher(cfg.RSSConfig, conf.RSS, sdb, parser)
+	wg.Add(1)
+	go func() {
+		defer func() {
+			log.Info("[server] config watcher stopped")
+			wg.Done()
+		}()
+
+		watcher.Run(runCtx, hupChan)
 	}()
 
+	if cfg.Retention.Enabled() {
+		janitor, err := retention.New(sdb, cfg.Retention)
+		if err != nil {
+			log.Fatalf("[server] invalid retention policy: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				log.Info("[server] janitor stopped")
+				wg.Done()
+			}()
+
+			janitor.Run(runCtx)
+		}()
+	} else {
+		log.Info("[server] retention policy is not set, posts are kept forever")
+	}
+
+	var handler http.Handler = api.Router
+	if subscriber != nil {
+		// The hubs don't send X-Request-Id, so the callback endpoint is served apart from the API.
+		mux := http.NewServeMux()
+		mux.Handle(subscriber.Path(), subscriber)
+		mux.Handle("/", api.Router)
+		handler = mux
+	}
+
 	server := &http.Server{
 		Addr:    httpAddr,
-		Handler: api.Router,
+		Handler: handler,
 	}
 
 	go func() {
@@ -215,7 +327,7 @@ func main() {
 	}()
 
 	<-sigChan
-	close(done)
+	stop()
 	wg.Wait()
 
 	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), 10*time.Second)
@@ -227,6 +339,45 @@ func main() {
 	log.Info("[server] stopped")
 }
 
+// storePosts adds the posts of a fetched or pushed copy of a feed to the storage.
+// The validators of the copy are committed to the parser only once the posts are stored.
+func storePosts(ctx context.Context, db storage.Storage, parser *rss.Parser, msg rss.ParserMsg) {
+	if errors.Is(msg.Err, rss.ErrNotModified) {
+		log.Debugf("[server] %s not modified, skipped", msg.Source)
+	} else if msg.Err != nil {
+		log.Warnf("[server] error while parsing %s: %v", msg.Source, msg.Err)
+	} else {
+		err := db.AddPosts(ctx, msg.Data)
+		if err != nil {
+			log.Warnf("[server] error while adding posts from %s to DB: %v", msg.Source, err)
+		} else {
+			log.Infof("[server] DB updated with posts from %s", msg.Source)
+			parser.Commit(msg)
+		}
+	}
+}
+
+// seedFeeds fills an empty feed storage with the feeds from the RSS config and
+// returns the stored feeds. Once the storage has feeds, the config ones are ignored.
+func seedFeeds(ctx context.Context, db storage.Storage, feeds []storage.Feed) ([]storage.Feed, error) {
+	stored, err := db.Feeds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > 0 {
+		return stored, nil
+	}
+
+	for _, f := range feeds {
+		if _, err := db.AddFeed(ctx, f); err != nil {
+			return nil, fmt.Errorf("unable to add feed %s: %w", f.URL, err)
+		}
+	}
+	log.Infof("[server] feed storage seeded with %d feeds from config", len(feeds))
+
+	return db.Feeds(ctx)
+}
+
 func createTopic(broker, topic string) error {
 	conn, err := kafka.DialContext(context.Background(), "tcp", broker)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
 
 	"net/http"
@@ -13,25 +14,42 @@ import (
 	"github.com/segmentio/kafka-go"
 	log "github.com/sirupsen/logrus"
 
+	"news/pkg/discover"
+	"news/pkg/lang"
+	"news/pkg/rss"
 	"news/pkg/storage"
 )
 
-const maxPostsLimit = 100
+const (
+	maxPostsLimit = 100
+	maxBodySize   = 1 << 20
+
+	uuidPattern = "[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}"
+)
 
 type API struct {
 	ServiceName string
 	DB          storage.Storage
+	Parser      *rss.Parser
 	Router      *mux.Router
 	kw          *kafka.Writer
+
+	discoverClient *http.Client // Client of POST /feeds/discover, see rss.Parser.DiscoveryClient.
 }
 
-func New(name string, db storage.Storage, kafkaWriter *kafka.Writer) *API {
+func New(name string, db storage.Storage, parser *rss.Parser, kafkaWriter *kafka.Writer) *API {
 	api := API{
 		ServiceName: name,
 		DB:          db,
+		Parser:      parser,
 		Router:      mux.NewRouter(),
 		kw:          kafkaWriter,
 	}
+	if parser != nil {
+		api.discoverClient = parser.DiscoveryClient()
+	} else {
+		api.discoverClient = &http.Client{Transport: discover.PublicTransport()}
+	}
 	api.endpoints()
 
 	return &api
@@ -47,7 +65,18 @@ func (api *API) endpoints() {
 
 	api.Router.HandleFunc("/news/filter", api.filterPostsHandler).Methods(http.MethodGet)
 	api.Router.HandleFunc("/news/latest", api.latestPostsHandler).Methods(http.MethodGet)
+	api.Router.HandleFunc("/news/search", api.searchPostsHandler).Methods(http.MethodGet)
+	api.Router.HandleFunc("/news/sources", api.sourcesHandler).Methods(http.MethodGet)
+	api.Router.HandleFunc("/feeds", api.feedsHandler).Methods(http.MethodGet)
+	api.Router.HandleFunc("/feeds", api.addFeedHandler).Methods(http.MethodPost)
+	api.Router.HandleFunc("/feeds/status", api.feedsStatusHandler).Methods(http.MethodGet)
+	api.Router.HandleFunc("/feeds/export.opml", api.exportFeedsHandler).Methods(http.MethodGet)
+	api.Router.HandleFunc("/feeds/import", api.importFeedsHandler).Methods(http.MethodPost)
+	api.Router.HandleFunc("/feeds/discover", api.discoverFeedsHandler).Methods(http.MethodPost)
+	api.Router.HandleFunc("/feeds/{id:"+uuidPattern+"}", api.updateFeedHandler).Methods(http.MethodPatch)
+	api.Router.HandleFunc("/feeds/{id:"+uuidPattern+"}", api.deleteFeedHandler).Methods(http.MethodDelete)
 	api.Router.HandleFunc("/news/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$}", api.postDetailedHandler).Methods(http.MethodGet)
+	api.Router.HandleFunc("/news/{id:"+uuidPattern+"}/revisions", api.revisionsHandler).Methods(http.MethodGet)
 }
 
 func (api *API) latestPostsHandler(w http.ResponseWriter, r *http.Request) {
@@ -69,16 +98,40 @@ func (api *API) latestPostsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	posts, numPages, err := api.DB.LatestPosts(r.Context(), page, limit)
+	filter, err := postFilter(r)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Errorf("[latestPostsHandler][%s] LatestPosts() returned error: %v", sID, err)
+		http.Error(w, "Invalid lang parameter", http.StatusBadRequest)
+		log.Debugf("[latestPostsHandler][%s] request with invalid lang parameter: %v", sID, err)
 		return
 	}
 
-	resp := PostsResponse{
-		Posts:      posts,
-		Pagination: Pagination{TotalPages: numPages, CurrentPage: page, Limit: limit},
+	var resp PostsResponse
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		cursor, err := storage.ParseCursor(c)
+		if err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			log.Debugf("[latestPostsHandler][%s] request with invalid cursor parameter: %v", sID, err)
+			return
+		}
+		pg, err := api.DB.LatestPostsAt(r.Context(), filter, cursor, limit)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Errorf("[latestPostsHandler][%s] LatestPostsAt() returned error: %v", sID, err)
+			return
+		}
+		resp = cursorResponse(pg, limit)
+	} else {
+		posts, numPages, err := api.DB.LatestPosts(r.Context(), filter, page, limit)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Errorf("[latestPostsHandler][%s] LatestPosts() returned error: %v", sID, err)
+			return
+		}
+		resp = pageResponse(posts, page, numPages, limit)
+	}
+
+	if brief, _ := strconv.ParseBool(r.URL.Query().Get("brief")); brief {
+		resp.Posts = withoutContent(resp.Posts)
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -114,16 +167,40 @@ func (api *API) filterPostsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	posts, numPages, err := api.DB.FilterPosts(r.Context(), contains, page, limit)
+	filter, err := postFilter(r)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Errorf("[filterPostsHandler][%s] FilterPosts() returned error: %v", sID, err)
+		http.Error(w, "Invalid lang parameter", http.StatusBadRequest)
+		log.Debugf("[filterPostsHandler][%s] request with invalid lang parameter: %v", sID, err)
 		return
 	}
 
-	resp := PostsResponse{
-		Posts:      posts,
-		Pagination: Pagination{TotalPages: numPages, CurrentPage: page, Limit: limit},
+	var resp PostsResponse
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		cursor, err := storage.ParseCursor(c)
+		if err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			log.Debugf("[filterPostsHandler][%s] request with invalid cursor parameter: %v", sID, err)
+			return
+		}
+		pg, err := api.DB.FilterPostsAt(r.Context(), contains, filter, cursor, limit)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Errorf("[filterPostsHandler][%s] FilterPostsAt() returned error: %v", sID, err)
+			return
+		}
+		resp = cursorResponse(pg, limit)
+	} else {
+		posts, numPages, err := api.DB.FilterPosts(r.Context(), contains, filter, page, limit)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Errorf("[filterPostsHandler][%s] FilterPosts() returned error: %v", sID, err)
+			return
+		}
+		resp = pageResponse(posts, page, numPages, limit)
+	}
+
+	if brief, _ := strconv.ParseBool(r.URL.Query().Get("brief")); brief {
+		resp.Posts = withoutContent(resp.Posts)
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -135,6 +212,90 @@ func (api *API) filterPostsHandler(w http.ResponseWriter, r *http.Request) {
 	log.Debugf("[filterPostsHandler][%s] response sent to: %v", sID, r.RemoteAddr)
 }
 
+func (api *API) searchPostsHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "Empty q parameter", http.StatusBadRequest)
+		log.Debugf("[searchPostsHandler][%s] request with empty q parameter", sID)
+		return
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	if limit > maxPostsLimit {
+		http.Error(w, "Limit parameter is too big", http.StatusBadRequest)
+		log.Debugf("[searchPostsHandler][%s] request with too big limit parameter", sID)
+		return
+	}
+
+	filter, err := postFilter(r)
+	if err != nil {
+		http.Error(w, "Invalid lang parameter", http.StatusBadRequest)
+		log.Debugf("[searchPostsHandler][%s] request with invalid lang parameter: %v", sID, err)
+		return
+	}
+
+	results, numPages, err := api.DB.SearchPosts(r.Context(), query, filter, page, limit)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[searchPostsHandler][%s] SearchPosts() returned error: %v", sID, err)
+		return
+	}
+	if results == nil {
+		results = []storage.SearchResult{}
+	}
+
+	if brief, _ := strconv.ParseBool(r.URL.Query().Get("brief")); brief {
+		for i := range results {
+			results[i].Content = ""
+		}
+	}
+
+	resp := SearchResponse{
+		Posts:      results,
+		Pagination: Pagination{TotalPages: numPages, CurrentPage: page, Limit: limit},
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[searchPostsHandler][%s] failed to encode response data: %v", sID, err)
+		return
+	}
+
+	log.Debugf("[searchPostsHandler][%s] response sent to: %v", sID, r.RemoteAddr)
+}
+
+func (api *API) sourcesHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	sources, err := api.DB.Sources(r.Context())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[sourcesHandler][%s] Sources() returned error: %v", sID, err)
+		return
+	}
+	if sources == nil {
+		sources = []storage.SourceStat{}
+	}
+
+	if err := json.NewEncoder(w).Encode(sources); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[sourcesHandler][%s] failed to encode response data: %v", sID, err)
+		return
+	}
+
+	log.Debugf("[sourcesHandler][%s] response sent to: %v", sID, r.RemoteAddr)
+}
+
 func (api *API) postDetailedHandler(w http.ResponseWriter, r *http.Request) {
 	reqID := GetRequestID(r.Context())
 	sID := shorten(reqID)
@@ -169,6 +330,44 @@ func (api *API) postDetailedHandler(w http.ResponseWriter, r *http.Request) {
 	log.Debugf("[postDetailedHandler][%s] response sent to: %v", sID, r.RemoteAddr)
 }
 
+// revisionsHandler returns the changes of the title and the content of a post
+// made by the updates of its feed, newest first.
+func (api *API) revisionsHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	id, err := uuid.FromString(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid UUID parameter", http.StatusBadRequest)
+		log.Debugf("[revisionsHandler][%s] failed to parse post ID: %v", sID, err)
+		return
+	}
+
+	revisions, err := api.DB.Revisions(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrPostNotFound) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			log.Debugf("[revisionsHandler][%s] failed to retrieve revisions: %v", sID, err)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[revisionsHandler][%s] post ID:%v: %v", sID, id, err)
+		return
+	}
+	if revisions == nil {
+		revisions = []storage.Revision{}
+	}
+
+	err = json.NewEncoder(w).Encode(revisions)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[revisionsHandler][%s] failed to encode revisions: %v", sID, err)
+		return
+	}
+
+	log.Debugf("[revisionsHandler][%s] response sent to: %v", sID, r.RemoteAddr)
+}
+
 // GetRequestID extracts the request ID from the context.
 // It returns the request ID as a string if present, otherwise returns an empty string.
 func GetRequestID(ctx context.Context) string {
@@ -186,3 +385,55 @@ func shorten(s string) string {
 	}
 	return s
 }
+
+// postFilter returns the filter set by the source and lang query parameters.
+// The language may be given as a tag like en-US and is reduced to its code.
+func postFilter(r *http.Request) (storage.PostFilter, error) {
+	filter := storage.PostFilter{Source: r.URL.Query().Get("source")}
+
+	if tag := r.URL.Query().Get("lang"); tag != "" {
+		filter.Lang = lang.Normalize(tag)
+		if filter.Lang == "" {
+			return storage.PostFilter{}, fmt.Errorf("%q is not a language code", tag)
+		}
+	}
+
+	return filter, nil
+}
+
+// pageResponse returns the response with the numbered page of posts. The
+// cursors of the pages around it let the client go on with cursor pagination.
+func pageResponse(posts []storage.Post, page, numPages, limit int) PostsResponse {
+	resp := PostsResponse{
+		Posts:      posts,
+		Pagination: Pagination{TotalPages: numPages, CurrentPage: page, Limit: limit},
+	}
+	if len(posts) > 0 && page < numPages {
+		resp.Pagination.NextCursor = storage.After(posts[len(posts)-1]).String()
+	}
+	if len(posts) > 0 && page > 1 {
+		resp.Pagination.PrevCursor = storage.Before(posts[0]).String()
+	}
+	return resp
+}
+
+// cursorResponse returns the response with the page of posts fetched by a cursor.
+// The total page count is not known then.
+func cursorResponse(pg storage.PostsPage, limit int) PostsResponse {
+	return PostsResponse{
+		Posts: pg.Posts,
+		Pagination: Pagination{
+			Limit:      limit,
+			NextCursor: pg.Next.String(),
+			PrevCursor: pg.Prev.String(),
+		},
+	}
+}
+
+// withoutContent drops the full content of the posts, leaving their summaries.
+func withoutContent(posts []storage.Post) []storage.Post {
+	for i := range posts {
+		posts[i].Content = ""
+	}
+	return posts
+}
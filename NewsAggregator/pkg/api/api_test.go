@@ -7,13 +7,18 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gofrs/uuid"
 	log "github.com/sirupsen/logrus"
 
+	"news/pkg/rss"
 	"news/pkg/storage"
 	"news/pkg/storage/memdb"
 )
@@ -44,7 +49,7 @@ func TestAPI_latestPostsHandler(t *testing.T) {
 		t.Fatalf("unexpected error while adding posts: %v", err)
 	}
 
-	api := New("", db, nil)
+	api := New("", db, nil, nil)
 	path := fmt.Sprintf("/news/latest?page=1&limit=%d", len(testPosts))
 	req := httptest.NewRequest(http.MethodGet, path, nil)
 	req.Header.Set("X-Request-Id", testRequestID)
@@ -75,7 +80,7 @@ func TestAPI_latestPostsHandler(t *testing.T) {
 
 func TestAPI_postDetailedHandlerLimitExceeded(t *testing.T) {
 	db := memdb.New()
-	api := New("", db, nil)
+	api := New("", db, nil, nil)
 
 	// Expect 400
 	path := fmt.Sprintf("/news/latest?page=1&limit=%d", maxPostsLimit+1)
@@ -90,7 +95,7 @@ func TestAPI_postDetailedHandlerLimitExceeded(t *testing.T) {
 
 func TestAPI_filterPostsHandler(t *testing.T) {
 	db := memdb.New()
-	api := New("", db, nil)
+	api := New("", db, nil, nil)
 
 	// Expect 200
 	req := httptest.NewRequest(http.MethodGet, "/news/filter?contains=some_text", nil)
@@ -135,7 +140,7 @@ func TestAPI_postDetailedHandler(t *testing.T) {
 		t.Fatalf("unexpected error while adding posts: %v", err)
 	}
 
-	api := New("", db, nil)
+	api := New("", db, nil, nil)
 	targetPost := testPosts[0]
 	targetPostID := targetPost.ID
 	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/news/%s", targetPostID.String()), nil)
@@ -165,7 +170,7 @@ func TestAPI_postDetailedHandler(t *testing.T) {
 
 func TestAPI_postDetailedHandlerNotExist(t *testing.T) {
 	db := memdb.New()
-	api := New("", db, nil)
+	api := New("", db, nil, nil)
 
 	targetPostID, err := uuid.FromString("01234567-89ab-cdef-0123-456789abcdef")
 	if err != nil {
@@ -184,7 +189,7 @@ func TestAPI_postDetailedHandlerNotExist(t *testing.T) {
 
 func TestAPI_postDetailedHandlerInvalidUUID(t *testing.T) {
 	db := memdb.New()
-	api := New("", db, nil)
+	api := New("", db, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/news/invalid-uuid", nil)
 	req.Header.Set("X-Request-Id", testRequestID)
@@ -195,3 +200,574 @@ func TestAPI_postDetailedHandlerInvalidUUID(t *testing.T) {
 		t.Errorf("want status code %v, got status code %v", http.StatusNotFound, rr.Code)
 	}
 }
+
+func TestAPI_revisionsHandler(t *testing.T) {
+	db := memdb.New()
+	ctx := context.Background()
+
+	post := storage.Post{Title: "Old title", Content: "Content", Link: "https://example.com/1"}
+	id, err := db.AddPost(ctx, post)
+	if err != nil {
+		t.Fatalf("unexpected error while adding post: %v", err)
+	}
+	post.Title = "New title"
+	if _, err := db.AddPost(ctx, post); err != nil {
+		t.Fatalf("unexpected error while adding post: %v", err)
+	}
+
+	api := New("", db, nil, nil)
+	for _, tt := range []struct {
+		id       string
+		wantCode int
+	}{
+		{id: id.String(), wantCode: http.StatusOK},
+		{id: "01234567-89ab-cdef-0123-456789abcdef", wantCode: http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/news/%s/revisions", tt.id), nil)
+		req.Header.Set("X-Request-Id", testRequestID)
+		rr := httptest.NewRecorder()
+
+		api.Router.ServeHTTP(rr, req)
+		if rr.Code != tt.wantCode {
+			t.Fatalf("want status code %v for post %s, got status code %v", tt.wantCode, tt.id, rr.Code)
+		}
+		if rr.Code != http.StatusOK {
+			continue
+		}
+
+		var revisions []storage.Revision
+		if err := json.NewDecoder(rr.Body).Decode(&revisions); err != nil {
+			t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+		}
+		want := []storage.Change{{Field: "title", Old: "Old title", New: "New title"}}
+		if len(revisions) != 1 || !reflect.DeepEqual(revisions[0].Changes, want) {
+			t.Errorf("want one revision with changes %+v, got %+v", want, revisions)
+		}
+	}
+}
+
+func TestAPI_sourcesHandler(t *testing.T) {
+	db := memdb.New()
+
+	source := storage.Source{URL: "https://habr.com/rss", Name: "Habr"}
+	testPosts := []storage.Post{
+		{Title: "Post 1", Content: "Content 1", Published: time.Now(), Link: "https://habr.com/1", Source: source},
+		{Title: "Post 2", Content: "Content 2", Published: time.Now(), Link: "https://example.com/2"},
+	}
+	err := db.AddPosts(context.Background(), testPosts)
+	if err != nil {
+		t.Fatalf("unexpected error while adding posts: %v", err)
+	}
+
+	api := New("", db, nil, nil)
+	req := httptest.NewRequest(http.MethodGet, "/news/sources", nil)
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr := httptest.NewRecorder()
+
+	api.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+
+	var gotSources []storage.SourceStat
+	if err := json.NewDecoder(rr.Body).Decode(&gotSources); err != nil {
+		t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+	}
+	if len(gotSources) != 2 {
+		t.Fatalf("want 2 sources, got %d sources", len(gotSources))
+	}
+
+	// Filter latest posts by source.
+	req = httptest.NewRequest(http.MethodGet, "/news/latest?source="+url.QueryEscape(source.URL), nil)
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr = httptest.NewRecorder()
+
+	api.Router.ServeHTTP(rr, req)
+	var resp PostsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+	}
+	if len(resp.Posts) != 1 || resp.Posts[0].Source != source {
+		t.Errorf("want 1 post from %s, got %+v", source.URL, resp.Posts)
+	}
+}
+
+func TestAPI_latestPostsHandlerLang(t *testing.T) {
+	db := memdb.New()
+
+	testPosts := []storage.Post{
+		{Title: "Вышел Go 1.23", Published: time.Now(), Link: "https://habr.com/1", Lang: "ru"},
+		{Title: "Go 1.23 is released", Published: time.Now(), Link: "https://go.dev/1", Lang: "en"},
+		{Title: "Go 1.23", Published: time.Now(), Link: "https://example.com/1"},
+	}
+	err := db.AddPosts(context.Background(), testPosts)
+	if err != nil {
+		t.Fatalf("unexpected error while adding posts: %v", err)
+	}
+
+	api := New("", db, nil, nil)
+	for _, tag := range []string{"ru", "ru-RU"} {
+		req := httptest.NewRequest(http.MethodGet, "/news/latest?lang="+tag, nil)
+		req.Header.Set("X-Request-Id", testRequestID)
+		rr := httptest.NewRecorder()
+
+		api.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+		}
+		var resp PostsResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+		}
+		if len(resp.Posts) != 1 || resp.Posts[0].Lang != "ru" {
+			t.Errorf("want 1 post in russian for lang=%s, got %+v", tag, resp.Posts)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/news/latest?lang=russian", nil)
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr := httptest.NewRecorder()
+
+	api.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("want status code %v for invalid lang, got status code %v", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestAPI_feedsStatusHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	confPath := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(confPath, []byte(fmt.Sprintf(`{"rss": [{"url": %q, "name": "Broken"}]}`, srv.URL)), 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error while writing config: %v", err)
+	}
+	conf, err := rss.LoadConf(confPath)
+	if err != nil {
+		t.Fatalf("unexpected error while loading config: %v", err)
+	}
+
+	parser := rss.NewParser(*conf, nil)
+	msgChan := make(chan rss.ParserMsg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go parser.Run(ctx, msgChan)
+	<-msgChan
+
+	api := New("", memdb.New(), parser, nil)
+	req := httptest.NewRequest(http.MethodGet, "/feeds/status", nil)
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr := httptest.NewRecorder()
+
+	api.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+
+	var statuses []rss.FeedStatus
+	if err := json.NewDecoder(rr.Body).Decode(&statuses); err != nil {
+		t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("want 1 feed status, got %d", len(statuses))
+	}
+	if statuses[0].Name != "Broken" || statuses[0].ConsecutiveFailures != 1 {
+		t.Errorf("want 1 failure of feed Broken, got %+v", statuses[0])
+	}
+}
+
+func TestAPI_feedsCRUD(t *testing.T) {
+	confPath := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(confPath, []byte(`{"rss": []}`), 0o644); err != nil {
+		t.Fatalf("unexpected error while writing config: %v", err)
+	}
+	conf, err := rss.LoadConf(confPath)
+	if err != nil {
+		t.Fatalf("unexpected error while loading config: %v", err)
+	}
+	parser := rss.NewParser(*conf, nil)
+	api := New("", memdb.New(), parser, nil)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Request-Id", testRequestID)
+		rr := httptest.NewRecorder()
+		api.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/feeds", `{"url": "https://habr.com/rss", "name": "Habr"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("want status code %v, got status code %v", http.StatusCreated, rr.Code)
+	}
+	var feed storage.Feed
+	if err := json.NewDecoder(rr.Body).Decode(&feed); err != nil {
+		t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+	}
+	if !feed.Enabled || feed.ID == uuid.Nil {
+		t.Errorf("want enabled feed with an ID, got %+v", feed)
+	}
+	if feeds := parser.Feeds(); len(feeds) != 1 || feeds[0].URL != feed.URL {
+		t.Errorf("want parser to poll the added feed, got feeds %+v", feeds)
+	}
+
+	if rr := do(http.MethodPost, "/feeds", `{"url": "https://habr.com/rss"}`); rr.Code != http.StatusConflict {
+		t.Errorf("want status code %v for duplicate feed, got status code %v", http.StatusConflict, rr.Code)
+	}
+	if rr := do(http.MethodPost, "/feeds", `{"name": "No URL"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("want status code %v for feed without URL, got status code %v", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = do(http.MethodPatch, "/feeds/"+feed.ID.String(), `{"enabled": false, "interval": 30}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&feed); err != nil {
+		t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+	}
+	if feed.Enabled || feed.Interval != 30 || feed.Name != "Habr" {
+		t.Errorf("want disabled feed Habr with interval 30, got %+v", feed)
+	}
+	if feeds := parser.Feeds(); len(feeds) != 1 || feeds[0].Enabled {
+		t.Errorf("want parser to see the feed disabled, got feeds %+v", feeds)
+	}
+
+	if rr := do(http.MethodDelete, "/feeds/"+feed.ID.String(), ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("want status code %v, got status code %v", http.StatusNoContent, rr.Code)
+	}
+	if feeds := parser.Feeds(); len(feeds) != 0 {
+		t.Errorf("want parser without feeds, got feeds %+v", feeds)
+	}
+	if rr := do(http.MethodDelete, "/feeds/"+feed.ID.String(), ""); rr.Code != http.StatusNotFound {
+		t.Errorf("want status code %v for deleted feed, got status code %v", http.StatusNotFound, rr.Code)
+	}
+
+	rr = do(http.MethodGet, "/feeds", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+	if body := strings.TrimSpace(rr.Body.String()); body != "[]" {
+		t.Errorf("want empty feed list, got %s", body)
+	}
+}
+
+func TestAPI_importExportFeeds(t *testing.T) {
+	db := memdb.New()
+	ctx := context.Background()
+	habrID, err := db.AddFeed(ctx, storage.Feed{URL: "https://habr.com/rss", Name: "Habr", Interval: 15, Enabled: true})
+	if err != nil {
+		t.Fatalf("unexpected error while populating DB: %v", err)
+	}
+	_, err = db.AddFeed(ctx, storage.Feed{URL: "https://golangweekly.com/rss", Name: "Golang Weekly", Enabled: true})
+	if err != nil {
+		t.Fatalf("unexpected error while populating DB: %v", err)
+	}
+	api := New("", db, nil, nil)
+
+	const body = `<?xml version="1.0"?>
+<opml version="2.0">
+  <body>
+    <outline text="golang">
+      <outline type="rss" text="Habr Go" xmlUrl="https://habr.com/rss"/>
+      <outline type="rss" text="Go Blog" xmlUrl="https://go.dev/blog/feed.atom"/>
+    </outline>
+    <outline type="rss" text="Golang Weekly" xmlUrl="https://golangweekly.com/rss"/>
+  </body>
+</opml>`
+	req := httptest.NewRequest(http.MethodPost, "/feeds/import", strings.NewReader(body))
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr := httptest.NewRecorder()
+
+	api.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+
+	var res ImportResult
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+	}
+	if want := (ImportResult{Created: 1, Updated: 1, Skipped: 1}); res != want {
+		t.Errorf("want import result %+v, got %+v", want, res)
+	}
+
+	habr, err := db.Feed(ctx, habrID)
+	if err != nil {
+		t.Fatalf("unexpected error while retrieving feed: %v", err)
+	}
+	if habr.Name != "Habr Go" || habr.Category != "golang" || habr.Interval != 15 {
+		t.Errorf("want feed renamed and categorized with settings kept, got %+v", habr)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/feeds/export.opml", nil)
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr = httptest.NewRecorder()
+
+	api.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/x-opml") {
+		t.Errorf("want OPML content type, got %s", ct)
+	}
+	for _, url := range []string{"https://habr.com/rss", "https://go.dev/blog/feed.atom", "https://golangweekly.com/rss"} {
+		if !strings.Contains(rr.Body.String(), url) {
+			t.Errorf("want %s in exported OPML, got\n%s", url, rr.Body.String())
+		}
+	}
+}
+
+func TestAPI_importFeedsInvalid(t *testing.T) {
+	api := New("", memdb.New(), nil, nil)
+
+	for _, body := range []string{
+		"not an OPML document",
+		`<opml version="2.0"><body><outline text="Bad" xmlUrl="not a url"/></body></opml>`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/feeds/import", strings.NewReader(body))
+		req.Header.Set("X-Request-Id", testRequestID)
+		rr := httptest.NewRecorder()
+
+		api.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("want status code %v for %q, got status code %v", http.StatusBadRequest, body, rr.Code)
+		}
+	}
+}
+
+func TestAPI_discoverFeedsHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="/rss"></head></html>`))
+	})
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel><title>Site</title><item><title>Post</title></item></channel></rss>`))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	db := memdb.New()
+	if _, err := db.AddFeed(context.Background(), storage.Feed{URL: srv.URL + "/rss", Enabled: true}); err != nil {
+		t.Fatalf("unexpected error while populating DB: %v", err)
+	}
+	api := New("", db, nil, nil)
+
+	// Loopback addresses are refused.
+	req := httptest.NewRequest(http.MethodPost, "/feeds/discover", strings.NewReader(`{"url": "`+srv.URL+`/"}`))
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr := httptest.NewRecorder()
+	api.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("want status code %v for loopback address, got status code %v", http.StatusBadRequest, rr.Code)
+	}
+
+	// The test server is on a loopback address.
+	api.discoverClient = srv.Client()
+
+	req = httptest.NewRequest(http.MethodPost, "/feeds/discover", strings.NewReader(`{"url": "`+srv.URL+`/"}`))
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr = httptest.NewRecorder()
+
+	api.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+
+	var feeds []DiscoveredFeed
+	if err := json.NewDecoder(rr.Body).Decode(&feeds); err != nil {
+		t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+	}
+	if len(feeds) != 1 || feeds[0].URL != srv.URL+"/rss" || feeds[0].Title != "Site" || feeds[0].Items != 1 || !feeds[0].Subscribed {
+		t.Errorf("want the subscribed feed %s/rss with 1 item, got %+v", srv.URL, feeds)
+	}
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{body: "not JSON", want: http.StatusBadRequest},
+		{body: `{"url": "example.com"}`, want: http.StatusBadRequest},
+		{body: `{"url": "` + srv.URL + `/missing"}`, want: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/feeds/discover", strings.NewReader(tt.body))
+		req.Header.Set("X-Request-Id", testRequestID)
+		rr := httptest.NewRecorder()
+
+		api.Router.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("want status code %v for %s, got status code %v", tt.want, tt.body, rr.Code)
+		}
+	}
+}
+
+func TestAPI_latestPostsHandlerBrief(t *testing.T) {
+	db := memdb.New()
+	post := storage.Post{
+		Title:     "Post",
+		Content:   "<p>Full content</p>",
+		Summary:   "Full content",
+		Published: time.Now().UTC(),
+		Link:      "https://example.com/1",
+	}
+	if _, err := db.AddPost(context.Background(), post); err != nil {
+		t.Fatalf("unexpected error while adding post: %v", err)
+	}
+
+	api := New("", db, nil, nil)
+	req := httptest.NewRequest(http.MethodGet, "/news/latest?brief=true", nil)
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr := httptest.NewRecorder()
+
+	api.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+
+	var resp PostsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+	}
+	if len(resp.Posts) != 1 {
+		t.Fatalf("want 1 post, got %d posts", len(resp.Posts))
+	}
+	if got := resp.Posts[0]; got.Content != "" || got.Summary != post.Summary {
+		t.Errorf("want summary %q without content, got summary %q and content %q", post.Summary, got.Summary, got.Content)
+	}
+
+	stored, err := db.Post(context.Background(), resp.Posts[0].ID)
+	if err != nil {
+		t.Fatalf("unexpected error while retrieving post: %v", err)
+	}
+	if stored.Content != post.Content {
+		t.Errorf("want stored content kept, got %q", stored.Content)
+	}
+}
+
+func TestAPI_searchPostsHandler(t *testing.T) {
+	db := memdb.New()
+	posts := []storage.Post{
+		{
+			Title:     "Go 1.25 is released",
+			Content:   "<p>The new release of Go is out.</p>",
+			Published: time.Date(2025, 8, 12, 0, 0, 0, 0, time.UTC),
+			Link:      "https://example.com/go",
+		},
+		{
+			Title:     "Rust 1.89",
+			Content:   "<p>Rust gets closer to Go in compile times.</p>",
+			Published: time.Date(2025, 8, 7, 0, 0, 0, 0, time.UTC),
+			Link:      "https://example.com/rust",
+		},
+	}
+	if err := db.AddPosts(context.Background(), posts); err != nil {
+		t.Fatalf("unexpected error while adding posts: %v", err)
+	}
+
+	api := New("", db, nil, nil)
+	req := httptest.NewRequest(http.MethodGet, "/news/search?q=go+-beta&brief=true", nil)
+	req.Header.Set("X-Request-Id", testRequestID)
+	rr := httptest.NewRecorder()
+
+	api.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, rr.Code)
+	}
+
+	var resp SearchResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+	}
+	if len(resp.Posts) != 2 || resp.Posts[0].Title != posts[0].Title {
+		t.Fatalf("want post with the word in title first of 2, got %+v", resp.Posts)
+	}
+	if got := resp.Posts[1]; got.Content != "" || !strings.Contains(got.Snippet, "<b>Go</b>") {
+		t.Errorf("want snippet with highlighted word and no content, got snippet %q and content %q", got.Snippet, got.Content)
+	}
+	if resp.Pagination.TotalPages != 1 {
+		t.Errorf("want 1 page, got %d", resp.Pagination.TotalPages)
+	}
+
+	for _, path := range []string{"/news/search?q=", fmt.Sprintf("/news/search?q=go&limit=%d", maxPostsLimit+1)} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Request-Id", testRequestID)
+		rr := httptest.NewRecorder()
+		api.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: want status code %v, got %v", path, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestAPI_latestPostsHandlerCursor(t *testing.T) {
+	db := memdb.New()
+
+	testPosts, err := memdb.LoadTestPosts(testPostsPath)
+	if err != nil {
+		t.Fatalf("unexpected error while loading test posts: %v", err)
+	}
+	if err := db.AddPosts(context.Background(), testPosts); err != nil {
+		t.Fatalf("unexpected error while adding posts: %v", err)
+	}
+
+	api := New("", db, nil, nil)
+	get := func(path string) (int, PostsResponse) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Request-Id", testRequestID)
+		rr := httptest.NewRecorder()
+		api.Router.ServeHTTP(rr, req)
+
+		var resp PostsResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("unexpected error while unmarshaling response data: %v", err)
+			}
+		}
+		return rr.Code, resp
+	}
+
+	// The numbered first page leads on to the cursor pages.
+	code, resp := get("/news/latest?page=1&limit=5")
+	if code != http.StatusOK {
+		t.Fatalf("want status code %v, got status code %v", http.StatusOK, code)
+	}
+	if resp.Pagination.NextCursor == "" || resp.Pagination.PrevCursor != "" {
+		t.Fatalf("want next cursor only on the first page, got %+v", resp.Pagination)
+	}
+	seen := make(map[uuid.UUID]bool)
+	for _, p := range resp.Posts {
+		seen[p.ID] = true
+	}
+
+	for cursor := resp.Pagination.NextCursor; cursor != ""; cursor = resp.Pagination.NextCursor {
+		code, resp = get("/news/latest?limit=5&cursor=" + url.QueryEscape(cursor))
+		if code != http.StatusOK {
+			t.Fatalf("want status code %v, got status code %v", http.StatusOK, code)
+		}
+		if resp.Pagination.PrevCursor == "" || resp.Pagination.TotalPages != 0 {
+			t.Errorf("want previous cursor without page count, got %+v", resp.Pagination)
+		}
+		for _, p := range resp.Posts {
+			if seen[p.ID] {
+				t.Errorf("want post %v listed once", p.ID)
+			}
+			seen[p.ID] = true
+		}
+	}
+	if len(seen) != len(testPosts) {
+		t.Errorf("want %d posts listed, got %d", len(testPosts), len(seen))
+	}
+
+	if code, _ := get("/news/latest?cursor=invalid"); code != http.StatusBadRequest {
+		t.Errorf("want status code %v for invalid cursor, got %v", http.StatusBadRequest, code)
+	}
+	if code, _ := get("/news/filter?contains=post&cursor=invalid"); code != http.StatusBadRequest {
+		t.Errorf("want status code %v for invalid cursor, got %v", http.StatusBadRequest, code)
+	}
+}
@@ -0,0 +1,360 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"news/pkg/discover"
+	"news/pkg/opml"
+	"news/pkg/rss"
+	"news/pkg/storage"
+)
+
+func (api *API) feedsHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	feeds, err := api.DB.Feeds(r.Context())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[feedsHandler][%s] Feeds() returned error: %v", sID, err)
+		return
+	}
+	if feeds == nil {
+		feeds = []storage.Feed{}
+	}
+
+	if err := json.NewEncoder(w).Encode(feeds); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[feedsHandler][%s] failed to encode response data: %v", sID, err)
+		return
+	}
+
+	log.Debugf("[feedsHandler][%s] response sent to: %v", sID, r.RemoteAddr)
+}
+
+func (api *API) addFeedHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	var feed storage.Feed
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&feed); err != nil {
+		http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+		log.Debugf("[addFeedHandler][%s] invalid JSON: %v", sID, err)
+		return
+	}
+	if err := feed.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Debugf("[addFeedHandler][%s] %v", sID, err)
+		return
+	}
+
+	id, err := api.DB.AddFeed(r.Context(), feed)
+	if err != nil {
+		if errors.Is(err, storage.ErrFeedExists) {
+			http.Error(w, "Feed already exists", http.StatusConflict)
+			log.Debugf("[addFeedHandler][%s] feed %s already exists", sID, feed.URL)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[addFeedHandler][%s] AddFeed() returned error: %v", sID, err)
+		return
+	}
+	feed.ID = id
+
+	api.syncFeeds(r.Context(), sID)
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(feed); err != nil {
+		log.Errorf("[addFeedHandler][%s] failed to encode response data: %v", sID, err)
+		return
+	}
+
+	log.Infof("[addFeedHandler][%s] feed %s added", sID, feed.URL)
+}
+
+func (api *API) updateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	id, err := uuid.FromString(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid UUID parameter", http.StatusBadRequest)
+		log.Debugf("[updateFeedHandler][%s] failed to parse feed ID: %v", sID, err)
+		return
+	}
+
+	var patch FeedPatch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&patch); err != nil {
+		http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+		log.Debugf("[updateFeedHandler][%s] invalid JSON: %v", sID, err)
+		return
+	}
+
+	feed, err := api.DB.Feed(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrFeedNotFound) {
+			http.Error(w, "Feed not found", http.StatusNotFound)
+			log.Debugf("[updateFeedHandler][%s] failed to retrieve feed: %v", sID, err)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[updateFeedHandler][%s] feed ID:%v: %v", sID, id, err)
+		return
+	}
+
+	feed = patch.Apply(feed)
+	if err := feed.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Debugf("[updateFeedHandler][%s] %v", sID, err)
+		return
+	}
+
+	err = api.DB.UpdateFeed(r.Context(), feed)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrFeedNotFound):
+			http.Error(w, "Feed not found", http.StatusNotFound)
+		case errors.Is(err, storage.ErrFeedExists):
+			http.Error(w, "Feed already exists", http.StatusConflict)
+		default:
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Errorf("[updateFeedHandler][%s] UpdateFeed() returned error: %v", sID, err)
+			return
+		}
+		log.Debugf("[updateFeedHandler][%s] failed to update feed ID:%v: %v", sID, id, err)
+		return
+	}
+
+	api.syncFeeds(r.Context(), sID)
+
+	if err := json.NewEncoder(w).Encode(feed); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[updateFeedHandler][%s] failed to encode response data: %v", sID, err)
+		return
+	}
+
+	log.Infof("[updateFeedHandler][%s] feed %s updated", sID, feed.URL)
+}
+
+func (api *API) deleteFeedHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	id, err := uuid.FromString(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid UUID parameter", http.StatusBadRequest)
+		log.Debugf("[deleteFeedHandler][%s] failed to parse feed ID: %v", sID, err)
+		return
+	}
+
+	err = api.DB.DeleteFeed(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrFeedNotFound) {
+			http.Error(w, "Feed not found", http.StatusNotFound)
+			log.Debugf("[deleteFeedHandler][%s] failed to delete feed: %v", sID, err)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[deleteFeedHandler][%s] feed ID:%v: %v", sID, id, err)
+		return
+	}
+
+	api.syncFeeds(r.Context(), sID)
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Infof("[deleteFeedHandler][%s] feed ID:%v deleted", sID, id)
+}
+
+func (api *API) exportFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	feeds, err := api.DB.Feeds(r.Context())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[exportFeedsHandler][%s] Feeds() returned error: %v", sID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="feeds.opml"`)
+	if err := opml.Write(w, api.ServiceName, feeds); err != nil {
+		log.Errorf("[exportFeedsHandler][%s] failed to encode OPML: %v", sID, err)
+		return
+	}
+
+	log.Debugf("[exportFeedsHandler][%s] response sent to: %v", sID, r.RemoteAddr)
+}
+
+// discoverFeedsHandler finds the feeds of the site the page in the request belongs to.
+// The feeds are not stored, any of them can be added with POST /feeds.
+func (api *API) discoverFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	var req DiscoverRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		http.Error(w, "Bad Request: invalid JSON", http.StatusBadRequest)
+		log.Debugf("[discoverFeedsHandler][%s] invalid JSON: %v", sID, err)
+		return
+	}
+
+	candidates, err := discover.Discover(r.Context(), api.discoverClient, req.URL)
+	if err != nil {
+		if errors.Is(err, discover.ErrInvalidURL) || errors.Is(err, discover.ErrNotPublic) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Debugf("[discoverFeedsHandler][%s] %v", sID, err)
+			return
+		}
+		http.Error(w, "Unable to fetch the page", http.StatusBadGateway)
+		log.Debugf("[discoverFeedsHandler][%s] unable to fetch %s: %v", sID, req.URL, err)
+		return
+	}
+
+	stored, err := api.DB.Feeds(r.Context())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[discoverFeedsHandler][%s] Feeds() returned error: %v", sID, err)
+		return
+	}
+	subscribed := make(map[string]bool, len(stored))
+	for _, f := range stored {
+		subscribed[f.URL] = true
+	}
+
+	feeds := make([]DiscoveredFeed, 0, len(candidates))
+	for _, c := range candidates {
+		feeds = append(feeds, DiscoveredFeed{Candidate: c, Subscribed: subscribed[c.URL]})
+	}
+
+	if err := json.NewEncoder(w).Encode(feeds); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[discoverFeedsHandler][%s] failed to encode response data: %v", sID, err)
+		return
+	}
+
+	log.Debugf("[discoverFeedsHandler][%s] %d feeds found at %s", sID, len(feeds), req.URL)
+}
+
+func (api *API) importFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	imported, err := opml.Parse(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "Bad Request: invalid OPML", http.StatusBadRequest)
+		log.Debugf("[importFeedsHandler][%s] %v", sID, err)
+		return
+	}
+	for _, f := range imported {
+		if err := f.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("%v: %s", err, f.URL), http.StatusBadRequest)
+			log.Debugf("[importFeedsHandler][%s] %v: %s", sID, err, f.URL)
+			return
+		}
+	}
+
+	stored, err := api.DB.Feeds(r.Context())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[importFeedsHandler][%s] Feeds() returned error: %v", sID, err)
+		return
+	}
+	byURL := make(map[string]storage.Feed, len(stored))
+	for _, f := range stored {
+		byURL[f.URL] = f
+	}
+
+	var res ImportResult
+	for _, f := range imported {
+		existing, ok := byURL[f.URL]
+		switch {
+		case !ok:
+			f.ID, err = api.DB.AddFeed(r.Context(), f)
+			res.Created++
+		case mergeImported(&existing, f):
+			err = api.DB.UpdateFeed(r.Context(), existing)
+			f = existing
+			res.Updated++
+		default:
+			res.Skipped++
+			continue
+		}
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Errorf("[importFeedsHandler][%s] unable to import feed %s: %v", sID, f.URL, err)
+			api.syncFeeds(r.Context(), sID)
+			return
+		}
+		byURL[f.URL] = f
+	}
+
+	api.syncFeeds(r.Context(), sID)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[importFeedsHandler][%s] failed to encode response data: %v", sID, err)
+		return
+	}
+
+	log.Infof("[importFeedsHandler][%s] feeds imported: %d created, %d updated, %d skipped",
+		sID, res.Created, res.Updated, res.Skipped)
+}
+
+// mergeImported copies the name and category of an imported feed onto the
+// stored one and reports whether anything changed. Empty imported values and
+// the settings OPML does not carry are kept.
+func mergeImported(stored *storage.Feed, imported storage.Feed) bool {
+	changed := false
+	if imported.Name != "" && imported.Name != stored.Name {
+		stored.Name = imported.Name
+		changed = true
+	}
+	if imported.Category != "" && imported.Category != stored.Category {
+		stored.Category = imported.Category
+		changed = true
+	}
+	return changed
+}
+
+func (api *API) feedsStatusHandler(w http.ResponseWriter, r *http.Request) {
+	reqID := GetRequestID(r.Context())
+	sID := shorten(reqID)
+
+	statuses := []rss.FeedStatus{}
+	if api.Parser != nil {
+		statuses = api.Parser.Status()
+	}
+
+	if err := json.NewEncoder(w).Encode(statuses); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Errorf("[feedsStatusHandler][%s] failed to encode response data: %v", sID, err)
+		return
+	}
+
+	log.Debugf("[feedsStatusHandler][%s] response sent to: %v", sID, r.RemoteAddr)
+}
+
+// syncFeeds hands the stored feeds over to the parser, so changes take effect without a restart.
+// The change is already stored when this is called, so a failure is only logged.
+func (api *API) syncFeeds(ctx context.Context, sID string) {
+	if api.Parser == nil {
+		return
+	}
+
+	feeds, err := api.DB.Feeds(ctx)
+	if err != nil {
+		log.Errorf("[syncFeeds][%s] unable to reload feeds for the parser: %v", sID, err)
+		return
+	}
+
+	api.Parser.SetFeeds(feeds)
+}
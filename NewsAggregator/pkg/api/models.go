@@ -3,13 +3,18 @@ package api
 import (
 	"time"
 
+	"news/pkg/discover"
 	"news/pkg/storage"
 )
 
+// Pagination describes the page of a list. The total page count and the page
+// number are zero for the pages fetched by a cursor.
 type Pagination struct {
-	TotalPages  int `json:"total_pages"`
-	CurrentPage int `json:"current_page"`
-	Limit       int `json:"limit"`
+	TotalPages  int    `json:"total_pages"`
+	CurrentPage int    `json:"current_page"`
+	Limit       int    `json:"limit"`
+	NextCursor  string `json:"next_cursor,omitempty"` // Cursor of the following page, if there is one.
+	PrevCursor  string `json:"prev_cursor,omitempty"` // Cursor of the preceding page, if there is one.
 }
 
 type PostsResponse struct {
@@ -17,6 +22,79 @@ type PostsResponse struct {
 	Pagination Pagination     `json:"pagination"`
 }
 
+// SearchResponse is the response to a GET /news/search request, the most relevant posts first.
+type SearchResponse struct {
+	Posts      []storage.SearchResult `json:"posts"`
+	Pagination Pagination             `json:"pagination"`
+}
+
+// FeedPatch holds the feed fields to change in a PATCH request, nil fields are left as is.
+type FeedPatch struct {
+	URL       *string          `json:"url"`
+	Name      *string          `json:"name"`
+	Category  *string          `json:"category"`
+	Interval  *int             `json:"interval"`
+	Enabled   *bool            `json:"enabled"`
+	UserAgent *string          `json:"user_agent"`
+	Timeout   *int             `json:"timeout"`
+	FullText  *bool            `json:"full_text"`
+	Rules     *storage.Rules   `json:"rules"`
+	Scraper   *storage.Scraper `json:"scraper"`
+}
+
+// Apply returns a copy of the feed with the patch fields set.
+func (p FeedPatch) Apply(f storage.Feed) storage.Feed {
+	if p.URL != nil {
+		f.URL = *p.URL
+	}
+	if p.Name != nil {
+		f.Name = *p.Name
+	}
+	if p.Category != nil {
+		f.Category = *p.Category
+	}
+	if p.Interval != nil {
+		f.Interval = *p.Interval
+	}
+	if p.Enabled != nil {
+		f.Enabled = *p.Enabled
+	}
+	if p.UserAgent != nil {
+		f.UserAgent = *p.UserAgent
+	}
+	if p.Timeout != nil {
+		f.Timeout = *p.Timeout
+	}
+	if p.FullText != nil {
+		f.FullText = *p.FullText
+	}
+	if p.Rules != nil {
+		f.Rules = *p.Rules
+	}
+	if p.Scraper != nil {
+		f.Scraper = p.Scraper
+	}
+	return f
+}
+
+// DiscoverRequest is the body of a POST /feeds/discover request.
+type DiscoverRequest struct {
+	URL string `json:"url"` // Address of any page of the site.
+}
+
+// DiscoveredFeed is a feed found on the site, see discover.Discover.
+type DiscoveredFeed struct {
+	discover.Candidate
+	Subscribed bool `json:"subscribed"` // The feed is already stored.
+}
+
+// ImportResult reports how the feeds of an imported OPML document were applied.
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"` // Already stored and unchanged.
+}
+
 type LogEntry struct {
 	Timestamp  time.Time `json:"timestamp"`
 	IP         string    `json:"ip"`
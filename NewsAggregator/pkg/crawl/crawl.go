@@ -0,0 +1,275 @@
+// Package crawl provides the HTTP transport the aggregator crawls sites with.
+// The transport identifies the aggregator with its User-Agent, limits the rate
+// of requests to each host, obeys robots.txt and stops requesting a host that
+// asked to retry later.
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultUserAgent identifies the aggregator to the sites it crawls.
+	DefaultUserAgent = "FeedFusion/1.0 (+news aggregator)"
+	// DefaultHostRate is the number of requests per second made to a host
+	// when the rate is not set.
+	DefaultHostRate = 1.0
+	// DefaultHostBurst is the number of requests made to a host back to back
+	// when the burst is not set.
+	DefaultHostBurst = 1
+
+	// maxRetryAfter caps the pause requested by a host with Retry-After.
+	maxRetryAfter = 6 * time.Hour
+	// maxHosts is the number of hosts tracked before idle hosts are forgotten.
+	maxHosts = 1000
+)
+
+// ErrDisallowed is returned for requests to the addresses that the robots.txt
+// of the site disallows for the aggregator.
+var ErrDisallowed = fmt.Errorf("disallowed by robots.txt")
+
+// RetryAfterError is returned when a host answered 429 Too Many Requests or
+// 503 Service Unavailable with a Retry-After header, and for the requests made
+// to the host before the time it asked to retry after.
+type RetryAfterError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%s asked to retry after %s", e.Host, e.Until.UTC().Format(time.RFC3339))
+}
+
+// Config is the crawling policy.
+type Config struct {
+	UserAgent string  // Sent with the requests that have no User-Agent of their own.
+	HostRate  float64 // Requests per second to a host.
+	HostBurst int     // Requests to a host allowed back to back.
+}
+
+// Transport is an http.RoundTripper that crawls politely. Requests to each host
+// are rate limited with a token bucket, so they queue up instead of hitting the
+// host at once. Before the first request to a host its robots.txt is fetched,
+// cached for a day and checked for every request. Once a host answers 429 or
+// 503 with Retry-After, the response is dropped with a RetryAfterError and so
+// are the requests to the host until the time it asked for.
+type Transport struct {
+	base      http.RoundTripper
+	userAgent string
+	rate      float64
+	burst     int
+
+	hosts *hostSet // Shared with the transports made by WithBase.
+}
+
+// hostSet is the state of the requests to the hosts by scheme and host of the URL.
+type hostSet struct {
+	mu     sync.Mutex
+	byAddr map[string]*host
+}
+
+// host is the state of the requests to a host.
+type host struct {
+	mu     sync.Mutex
+	tokens float64   // Requests that can be made at once, negative if requests are queued.
+	last   time.Time // Time the tokens were last counted.
+	until  time.Time // Requests are dropped until then, see RetryAfterError.
+
+	robots *robotsEntry
+}
+
+// NewTransport returns a transport that makes the requests with base according
+// to the policy. If base is nil, http.DefaultTransport is used; the unset
+// fields of the policy take the default values.
+func NewTransport(base http.RoundTripper, conf Config) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if conf.UserAgent == "" {
+		conf.UserAgent = DefaultUserAgent
+	}
+	if conf.HostRate <= 0 {
+		conf.HostRate = DefaultHostRate
+	}
+	if conf.HostBurst <= 0 {
+		conf.HostBurst = DefaultHostBurst
+	}
+
+	return &Transport{
+		base:      base,
+		userAgent: conf.UserAgent,
+		rate:      conf.HostRate,
+		burst:     conf.HostBurst,
+		hosts:     &hostSet{byAddr: make(map[string]*host)},
+	}
+}
+
+// WithBase returns a transport that makes the requests with base, but follows
+// the policy of t and shares the state of the hosts with it, so the requests
+// of both transports to a host are rate limited and paused together.
+func (t *Transport) WithBase(base http.RoundTripper) *Transport {
+	c := *t
+	c.base = base
+	return &c
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	h := t.host(req.URL)
+	if err := h.paused(req.URL.Host); err != nil {
+		return nil, err
+	}
+	if req.URL.Path != "/robots.txt" {
+		rules, err := t.robots(req.Context(), h, req.URL)
+		if err != nil {
+			return nil, err
+		}
+		if !rules.allowed(req.URL.RequestURI()) {
+			return nil, fmt.Errorf("%w: %s", ErrDisallowed, req.URL)
+		}
+	}
+	if err := h.wait(req.Context(), t.rate, t.burst); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if until, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			resp.Body.Close()
+			h.pause(until)
+			return nil, &RetryAfterError{Host: req.URL.Host, Until: until}
+		}
+	}
+
+	return resp, nil
+}
+
+// host returns the state of the host of the URL. Once too many hosts are
+// tracked, the idle ones are forgotten.
+func (t *Transport) host(u *url.URL) *host {
+	key := u.Scheme + "://" + u.Host
+
+	t.hosts.mu.Lock()
+	defer t.hosts.mu.Unlock()
+
+	if h, ok := t.hosts.byAddr[key]; ok {
+		return h
+	}
+	if len(t.hosts.byAddr) >= maxHosts {
+		now := time.Now()
+		for k, h := range t.hosts.byAddr {
+			if h.idle(now, t.rate, t.burst) {
+				delete(t.hosts.byAddr, k)
+			}
+		}
+	}
+
+	h := &host{tokens: float64(t.burst), last: time.Now()}
+	t.hosts.byAddr[key] = h
+
+	return h
+}
+
+// wait blocks until a request to the host can be made or ctx is done.
+// Tokens are added at the given rate up to burst and each request takes one.
+// A request that finds no token reserves the next one and waits for it, so
+// the requests are made in the order they came.
+func (h *host) wait(ctx context.Context, rate float64, burst int) error {
+	h.mu.Lock()
+	now := time.Now()
+	h.tokens = min(float64(burst), h.tokens+now.Sub(h.last).Seconds()*rate)
+	h.last = now
+	h.tokens--
+	delay := time.Duration(-h.tokens / rate * float64(time.Second))
+	h.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give back the reserved token.
+		h.mu.Lock()
+		h.tokens++
+		h.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// pause drops the requests to the host until the given time.
+func (h *host) pause(until time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if until.After(h.until) {
+		h.until = until
+	}
+}
+
+// paused returns a RetryAfterError if the requests to the host are dropped.
+func (h *host) paused(name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Now().Before(h.until) {
+		return &RetryAfterError{Host: name, Until: h.until}
+	}
+	return nil
+}
+
+// idle reports whether the host state can be forgotten: its bucket is full,
+// it is not paused and its robots.txt is not cached.
+func (h *host) idle(now time.Time, rate float64, burst int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	full := h.tokens+now.Sub(h.last).Seconds()*rate >= float64(burst)
+	return full && !now.Before(h.until) && (h.robots == nil || h.robots.expired(now))
+}
+
+// retryAfter parses the value of a Retry-After header, either a number of
+// seconds or an HTTP date, into the time to retry after, capped at maxRetryAfter.
+func retryAfter(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	var until time.Time
+	if secs, err := strconv.Atoi(value); err == nil {
+		until = now.Add(time.Duration(secs) * time.Second)
+	} else if t, err := http.ParseTime(value); err == nil {
+		until = t
+	} else {
+		return time.Time{}, false
+	}
+
+	if !until.After(now) {
+		return time.Time{}, false
+	}
+	if limit := now.Add(maxRetryAfter); until.After(limit) {
+		until = limit
+	}
+	return until, true
+}
@@ -0,0 +1,249 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRobotsServer returns a server that disallows /private/ in its robots.txt
+// and answers other requests with 200 OK. The requests of robots.txt are
+// counted in robots and the User-Agent of the last other request is stored in agent.
+func newRobotsServer(t *testing.T, robots *atomic.Int32, agent *atomic.Value) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robots.Add(1)
+			w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+			return
+		}
+		agent.Store(r.UserAgent())
+		w.Write([]byte("OK"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestTransport_Robots(t *testing.T) {
+	var robots atomic.Int32
+	var agent atomic.Value
+	srv := newRobotsServer(t, &robots, &agent)
+
+	client := &http.Client{Transport: NewTransport(nil, Config{HostRate: 1000})}
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Get(srv.URL + "/feed")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if _, err := client.Get(srv.URL + "/private/feed"); !errors.Is(err, ErrDisallowed) {
+		t.Errorf("want error %v, got %v", ErrDisallowed, err)
+	}
+	if n := robots.Load(); n != 1 {
+		t.Errorf("want robots.txt fetched once, got %d times", n)
+	}
+	if got := agent.Load(); got != DefaultUserAgent {
+		t.Errorf("want User-Agent %q, got %q", DefaultUserAgent, got)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/feed", nil)
+	req.Header.Set("User-Agent", "Custom/1.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if got := agent.Load(); got != "Custom/1.0" {
+		t.Errorf("want User-Agent of the request kept, got %q", got)
+	}
+}
+
+func TestTransport_HostRate(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	const rate = 20 // One request per 50ms.
+	client := &http.Client{Transport: NewTransport(nil, Config{HostRate: rate, HostBurst: 1})}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) != 4 {
+		t.Fatalf("want 4 requests, got %d", len(times))
+	}
+	// The first request is made at once and the others one interval apart.
+	first, last := times[0], times[0]
+	for _, tm := range times {
+		if tm.Before(first) {
+			first = tm
+		}
+		if tm.After(last) {
+			last = tm
+		}
+	}
+	if d := last.Sub(first); d < 140*time.Millisecond {
+		t.Errorf("want requests spread over at least 150ms, got %v", d)
+	}
+
+	// A request that is cancelled while waiting gives its turn back.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want error %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestTransport_RetryAfter(t *testing.T) {
+	var served atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		served.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, Config{HostRate: 1000})}
+
+	for i := range 2 {
+		_, err := client.Get(srv.URL + "/feed")
+		var retry *RetryAfterError
+		if !errors.As(err, &retry) {
+			t.Fatalf("want RetryAfterError on request #%d, got %v", i, err)
+		}
+		if d := time.Until(retry.Until); d < 110*time.Second || d > 120*time.Second {
+			t.Errorf("want retry in 120s, got %v", d)
+		}
+	}
+	if n := served.Load(); n != 1 {
+		t.Errorf("want host requested once until it asked to retry, got %d times", n)
+	}
+}
+
+func TestTransport_WithBase(t *testing.T) {
+	var served atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		served.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	transport := NewTransport(nil, Config{HostRate: 1000})
+	var used atomic.Int32
+	other := transport.WithBase(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		used.Add(1)
+		return http.DefaultTransport.RoundTrip(req)
+	}))
+
+	if _, err := (&http.Client{Transport: transport}).Get(srv.URL + "/feed"); err == nil {
+		t.Fatal("want error, got nil")
+	}
+
+	// The host asked the first transport to retry later, so the other one doesn't request it either.
+	_, err := (&http.Client{Transport: other}).Get(srv.URL + "/feed")
+	var retry *RetryAfterError
+	if !errors.As(err, &retry) {
+		t.Errorf("want RetryAfterError, got %v", err)
+	}
+	if n := served.Load(); n != 1 {
+		t.Errorf("want host requested once, got %d times", n)
+	}
+	if n := used.Load(); n != 0 {
+		t.Errorf("want base of the other transport unused, got %d requests", n)
+	}
+
+	// The requests to the other hosts are made with the base of the other transport.
+	idle := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer idle.Close()
+	resp, err := (&http.Client{Transport: other}).Get(idle.URL + "/feed")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if n := used.Load(); n != 2 {
+		t.Errorf("want robots.txt and feed requested with the base of the other transport, got %d requests", n)
+	}
+}
+
+// roundTripFunc is an http.RoundTripper made of a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Time
+		wantOK bool
+	}{
+		{"30", now.Add(30 * time.Second), true},
+		{"Wed, 01 May 2024 12:05:00 GMT", now.Add(5 * time.Minute), true},
+		{"86400", now.Add(maxRetryAfter), true},
+		{"0", time.Time{}, false},
+		{"Wed, 01 May 2024 11:00:00 GMT", time.Time{}, false},
+		{"", time.Time{}, false},
+		{"soon", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.value, now)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("want retryAfter(%q) = %v, %v, got %v, %v", tt.value, tt.want, tt.wantOK, got, ok)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package crawl
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// robotsTTL is how long a fetched robots.txt is used.
+	robotsTTL = 24 * time.Hour
+	// robotsRetry is how long a site whose robots.txt could not be fetched is
+	// crawled without restrictions before the robots.txt is requested again.
+	robotsRetry = 10 * time.Minute
+	// robotsTimeout limits a request of robots.txt.
+	robotsTimeout = 10 * time.Second
+	// maxRobotsSize limits the size of a downloaded robots.txt.
+	maxRobotsSize = 500 << 10
+)
+
+// robotsEntry is the cached robots.txt of a host.
+type robotsEntry struct {
+	ready   chan struct{} // Closed once the rules are fetched.
+	rules   *robotsRules
+	expires time.Time
+}
+
+// expired reports whether the rules have been fetched and are out of date.
+func (e *robotsEntry) expired(now time.Time) bool {
+	select {
+	case <-e.ready:
+		return !now.Before(e.expires)
+	default:
+		return false
+	}
+}
+
+// robots returns the robots.txt rules of the site of the URL, fetching them
+// if they are not cached. Concurrent requests to the site wait for a single fetch.
+func (t *Transport) robots(ctx context.Context, h *host, u *url.URL) (*robotsRules, error) {
+	h.mu.Lock()
+	e := h.robots
+	if e == nil || e.expired(time.Now()) {
+		e = &robotsEntry{ready: make(chan struct{})}
+		h.robots = e
+		h.mu.Unlock()
+
+		e.rules, e.expires = t.fetchRobots(ctx, u)
+		close(e.ready)
+		return e.rules, nil
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.rules, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetchRobots downloads and parses the robots.txt of the site of the URL and
+// returns its rules along with the time they expire. A missing robots.txt
+// allows everything. So does one that could not be fetched, but only until
+// it is requested again after robotsRetry.
+func (t *Transport) fetchRobots(ctx context.Context, u *url.URL) (*robotsRules, time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, robotsTimeout)
+	defer cancel()
+
+	addr := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr.String(), nil)
+	if err != nil {
+		return nil, time.Now().Add(robotsRetry)
+	}
+	req.Header.Set("User-Agent", t.userAgent)
+
+	client := http.Client{Transport: t.base}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Debugf("[crawl] unable to fetch %s: %v", addr.String(), err)
+		return nil, time.Now().Add(robotsRetry)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return parseRobots(io.LimitReader(resp.Body, maxRobotsSize), t.userAgent), time.Now().Add(robotsTTL)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return nil, time.Now().Add(robotsTTL)
+	default:
+		log.Debugf("[crawl] unable to fetch %s: %s", addr.String(), resp.Status)
+		return nil, time.Now().Add(robotsRetry)
+	}
+}
+
+// robotsRules are the rules of a robots.txt that apply to the aggregator.
+// Nil rules allow everything.
+type robotsRules struct {
+	rules []robotsRule
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string // Path prefix, * matches any characters and a trailing $ the end of the path.
+}
+
+// parseRobots returns the rules of the robots.txt groups for the product token
+// of userAgent, e.g. "FeedFusion" of "FeedFusion/1.0", or else the rules of the
+// groups for any agent, see RFC 9309.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	token := agentToken(userAgent)
+
+	var own, common []robotsRule
+	var hasOwn bool
+	var agents []string
+	inRules := false // Whether the current group has rules, so a user-agent line starts a new group.
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, agentToken(value))
+			if agentToken(value) == token {
+				hasOwn = true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				// An empty disallow rule allows everything, same as no rule.
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			for _, a := range agents {
+				switch a {
+				case token:
+					own = append(own, rule)
+				case "*":
+					common = append(common, rule)
+				}
+			}
+		}
+	}
+
+	if hasOwn {
+		return &robotsRules{rules: own}
+	}
+	return &robotsRules{rules: common}
+}
+
+// agentToken returns the product token of a user agent in lower case.
+func agentToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	if i := strings.IndexAny(token, " \t"); i >= 0 {
+		token = token[:i]
+	}
+	return strings.ToLower(token)
+}
+
+// allowed reports whether the rules allow the path. The rule with the longest
+// matching pattern decides, allow rules win ties.
+func (r *robotsRules) allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if !matchRobots(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allow, longest = rule.allow, n
+		}
+	}
+
+	return allow
+}
+
+// matchRobots reports whether the path matches the robots.txt pattern.
+func matchRobots(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, p := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, p)
+		}
+		j := strings.Index(rest, p)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(p):]
+	}
+
+	return !anchored || rest == ""
+}
@@ -0,0 +1,88 @@
+package crawl
+
+import (
+	"strings"
+	"testing"
+)
+
+const testRobots = `# Example robots.txt
+User-agent: *
+Disallow: /private/
+Allow: /private/feed.xml
+Disallow: /*.php$
+
+User-agent: BadBot
+User-agent: OtherBot
+Disallow: /
+`
+
+func TestRobotsRules_Allowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(testRobots), DefaultUserAgent)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/news/rss", true},
+		{"/private/", false},
+		{"/private/posts", false},
+		{"/private/feed.xml", true},
+		{"/index.php", false},
+		{"/index.php?page=2", true},
+		{"/index.phpx", true},
+	}
+	for _, tt := range tests {
+		if got := rules.allowed(tt.path); got != tt.want {
+			t.Errorf("want allowed(%q) = %v, got %v", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestParseRobots_OwnGroup(t *testing.T) {
+	robots := testRobots + `
+User-agent: feedfusion
+Disallow: /news/
+`
+	rules := parseRobots(strings.NewReader(robots), "FeedFusion/2.0 (+test)")
+
+	if rules.allowed("/news/rss") {
+		t.Error("want path disallowed by own group")
+	}
+	// Once there is a group for the aggregator, the groups for any agent are ignored.
+	if !rules.allowed("/private/posts") {
+		t.Error("want path disallowed for any agent allowed")
+	}
+
+	rules = parseRobots(strings.NewReader(testRobots), "BadBot/1.0")
+	if rules.allowed("/news/rss") {
+		t.Error("want everything disallowed for agent of a group with several agents")
+	}
+}
+
+func TestMatchRobots(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/fish", "/fish", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/fish*", "/fishheads/yummy.html", true},
+		{"/*.php", "/filename.php", true},
+		{"/*.php", "/folder/filename.php?parameters", true},
+		{"/*.php", "/windows.PHP", false},
+		{"/*.php$", "/filename.php", true},
+		{"/*.php$", "/filename.php?parameters", false},
+		{"/fish*.php", "/fishheads/catfish.php?parameters", true},
+		{"/fish*.php", "/Fish.PHP", false},
+		{"/$", "/", true},
+		{"/$", "/page", false},
+	}
+	for _, tt := range tests {
+		if got := matchRobots(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("want matchRobots(%q, %q) = %v, got %v", tt.pattern, tt.path, tt.want, got)
+		}
+	}
+}
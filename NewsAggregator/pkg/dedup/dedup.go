@@ -0,0 +1,133 @@
+// Package dedup detects copies of the same story published under different
+// links or by different feeds.
+package dedup
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// MaxDistance is the largest number of differing fingerprint bits
+	// of two posts that are considered near-duplicates.
+	MaxDistance = 3
+	// Window limits near-duplicate matching to posts published within this
+	// period of each other.
+	Window = 72 * time.Hour
+
+	// minWords is the least number of words a fingerprinted text must have,
+	// shorter texts are too alike to be compared by fingerprint.
+	minWords = 8
+)
+
+// trackingParams are query parameters that identify a campaign or a referrer
+// rather than a resource. Parameters with the utm_ prefix are dropped as well.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"yclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+	"ref":     true,
+	"ref_src": true,
+}
+
+// CanonicalLink normalizes a post link, so the copies of a story that differ
+// only in tracking parameters, scheme, "www." prefix, default port, fragment
+// or trailing slash get the same link. A link that can't be parsed is
+// returned as is.
+func CanonicalLink(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return link
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "http" {
+		scheme = "https"
+	}
+	u.Scheme = scheme
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if len(u.Path) > 1 {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = ""
+	}
+
+	query := u.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	// Encode sorts the parameters by key.
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// Fingerprint returns the SimHash of the title and summary of a post: a
+// 64-bit hash that differs in few bits for similar texts. Texts shorter than
+// minWords words get a zero fingerprint, which matches nothing.
+func Fingerprint(title, summary string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(title+" "+summary), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < minWords {
+		return 0
+	}
+
+	// Hash word pairs rather than single words, so the word order counts.
+	var weights [64]int
+	for i := 0; i+1 < len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(words[i]))
+		h.Write([]byte{' '})
+		h.Write([]byte(words[i+1]))
+		sum := h.Sum64()
+
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fp uint64
+	for bit, w := range weights {
+		if w > 0 {
+			fp |= 1 << bit
+		}
+	}
+
+	return fp
+}
+
+// Distance returns the number of bits that differ in two fingerprints.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Similar reports whether two fingerprints belong to near-duplicate texts.
+func Similar(a, b uint64) bool {
+	return a != 0 && b != 0 && Distance(a, b) <= MaxDistance
+}
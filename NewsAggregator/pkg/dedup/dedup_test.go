@@ -0,0 +1,54 @@
+package dedup
+
+import "testing"
+
+func TestCanonicalLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/news/1", "https://example.com/news/1"},
+		{"http://www.Example.com/news/1/", "https://example.com/news/1"},
+		{"https://example.com:443/news/1#comments", "https://example.com/news/1"},
+		{"https://example.com/news/1?utm_source=rss&utm_medium=feed&fbclid=abc", "https://example.com/news/1"},
+		{"https://example.com/news?page=2&id=1&utm_campaign=x", "https://example.com/news?id=1&page=2"},
+		{"https://example.com:8080/", "https://example.com:8080/"},
+		{"not a link", "not a link"},
+	}
+
+	for _, tt := range tests {
+		if got := CanonicalLink(tt.link); got != tt.want {
+			t.Errorf("CanonicalLink(%q): want %q, got %q", tt.link, tt.want, got)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	const (
+		title   = "Go 1.23 released with range over function iterators"
+		summary = "The Go team is happy to announce the release of Go 1.23, which brings iterators, telemetry and many toolchain improvements to every Go developer."
+	)
+
+	original := Fingerprint(title, summary)
+	if original == 0 {
+		t.Fatal("want non-zero fingerprint")
+	}
+
+	republished := Fingerprint(title+"!", summary+" Read more")
+	if !Similar(original, republished) {
+		t.Errorf("want republished copy similar, distance %d", Distance(original, republished))
+	}
+
+	other := Fingerprint("PostgreSQL 17 adds incremental backups",
+		"The PostgreSQL Global Development Group announced the release of PostgreSQL 17 with incremental backups and faster vacuum.")
+	if Similar(original, other) {
+		t.Errorf("want different stories not similar, distance %d", Distance(original, other))
+	}
+
+	if fp := Fingerprint("Post 1", "Short"); fp != 0 {
+		t.Errorf("want zero fingerprint for short text, got %x", fp)
+	}
+	if Similar(0, 0) {
+		t.Error("want zero fingerprints not similar")
+	}
+}
@@ -0,0 +1,245 @@
+// Package discover finds the feeds of a website given the address of any of
+// its pages.
+package discover
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// maxPageSize limits the size of a downloaded page or feed.
+	maxPageSize = 5 << 20
+	// requestTimeout limits each request made by Discover.
+	requestTimeout = 15 * time.Second
+)
+
+var ErrInvalidURL = fmt.Errorf("invalid page url")
+
+// ErrNotPublic is returned for the addresses a PublicTransport refuses to
+// connect to: loopback, private, link-local and other non-public addresses.
+var ErrNotPublic = fmt.Errorf("address is not public")
+
+// feedTypes are the MIME types of the feeds announced with <link rel="alternate">.
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+// commonPaths are the paths where sites that don't announce their feeds usually
+// publish them, tried relative to the site root.
+var commonPaths = []string{
+	"/feed",
+	"/rss",
+	"/feed.xml",
+	"/rss.xml",
+	"/atom.xml",
+	"/index.xml",
+	"/feed.json",
+}
+
+// Candidate is a feed found on a site.
+type Candidate struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Type  string `json:"type"`  // rss, atom or json.
+	Items int    `json:"items"` // Number of items in the feed.
+}
+
+// Discover returns the feeds of the site the page belongs to: the feeds the
+// page announces with <link rel="alternate"> tags, followed by the feeds found
+// at the common paths. A page that is a feed itself is the only candidate.
+// Addresses that don't serve a valid feed are skipped, so the result may be
+// empty. The addresses are checked one by one, so a site is not requested
+// more than once at a time. If client is nil, http.DefaultClient is used;
+// addresses taken from users should be requested with a PublicTransport.
+func Discover(ctx context.Context, client *http.Client, pageURL string) ([]Candidate, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u, err := url.ParseRequestURI(pageURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidURL, pageURL)
+	}
+
+	body, base, err := get(ctx, client, u.String())
+	if err != nil {
+		return nil, err
+	}
+	if c, err := parseFeed(body, base.String()); err == nil {
+		return []Candidate{c}, nil
+	}
+
+	addrs := alternates(body, base)
+	for _, p := range commonPaths {
+		root := url.URL{Scheme: base.Scheme, Host: base.Host, Path: p}
+		addrs = append(addrs, root.String())
+	}
+
+	return check(ctx, client, dedupe(addrs)), nil
+}
+
+// check downloads the addresses in turn and returns the candidates for those
+// that serve a valid feed, in the order of the addresses.
+func check(ctx context.Context, client *http.Client, addrs []string) []Candidate {
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	for _, addr := range addrs {
+		body, final, err := get(ctx, client, addr)
+		if err != nil {
+			continue
+		}
+		// A common path may redirect to a feed announced by the page.
+		c, err := parseFeed(body, final.String())
+		if err == nil && !seen[c.URL] {
+			seen[c.URL] = true
+			candidates = append(candidates, c)
+		}
+	}
+
+	return candidates
+}
+
+// PublicTransport returns a transport that only connects to public addresses,
+// so the pages a user asks for can't reach the services of the host and its
+// network. The address is checked once the host name is resolved, right
+// before dialing, which covers redirects and names resolving to private
+// addresses. Proxies are not used, as the address of the proxy is the one
+// the transport would dial.
+func PublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !public(addr.Addr()) {
+				return fmt.Errorf("%w: %s", ErrNotPublic, addr.Addr())
+			}
+			return nil
+		},
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// public reports whether the address is a public unicast one.
+func public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// get downloads the document at addr and returns its body along with the URL
+// it was downloaded from after redirects.
+func get(ctx context.Context, client *http.Client, addr string) ([]byte, *url.URL, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("unexpected status of %s: %s", addr, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return body, resp.Request.URL, nil
+}
+
+// parseFeed returns the candidate for the feed document downloaded from addr.
+func parseFeed(body []byte, addr string) (Candidate, error) {
+	f, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return Candidate{}, err
+	}
+
+	return Candidate{
+		URL:   addr,
+		Title: strings.TrimSpace(f.Title),
+		Type:  f.FeedType,
+		Items: len(f.Items),
+	}, nil
+}
+
+// alternates returns the absolute addresses of the feeds the HTML page
+// announces with <link rel="alternate" type="..."> tags, in document order.
+func alternates(page []byte, base *url.URL) []string {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil
+	}
+
+	var addrs []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Link {
+			var rel, typ, href string
+			for _, a := range n.Attr {
+				switch strings.ToLower(a.Key) {
+				case "rel":
+					rel = strings.ToLower(a.Val)
+				case "type":
+					typ = strings.ToLower(strings.TrimSpace(a.Val))
+				case "href":
+					href = strings.TrimSpace(a.Val)
+				}
+			}
+			if slices.Contains(strings.Fields(rel), "alternate") && feedTypes[typ] && href != "" {
+				if u, err := base.Parse(href); err == nil {
+					addrs = append(addrs, u.String())
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return addrs
+}
+
+// dedupe returns the addresses without repetitions, keeping the first occurrence.
+func dedupe(addrs []string) []string {
+	seen := make(map[string]bool, len(addrs))
+	unique := addrs[:0]
+	for _, a := range addrs {
+		if !seen[a] {
+			seen[a] = true
+			unique = append(unique, a)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,150 @@
+package discover
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+	<title>Example News</title>
+	<item><title>Post 1</title><link>https://example.com/1</link></item>
+	<item><title>Post 2</title><link>https://example.com/2</link></item>
+</channel>
+</rss>`
+
+const testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title> Example Comments </title>
+	<entry><title>Comment</title><link href="https://example.com/c/1"/></entry>
+</feed>`
+
+const testPage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>Example</title>
+	<link rel="stylesheet" href="/style.css">
+	<link rel="alternate" type="application/rss+xml" title="News" href="/news.rss">
+	<link rel="alternate" type="application/atom+xml" href="comments/atom">
+	<link rel="alternate" type="application/rss+xml" href="/broken.rss">
+	<link rel="alternate" hreflang="en" href="/en/">
+</head>
+<body><a href="/feed">Feed</a></body>
+</html>`
+
+// newSiteServer returns a server of a site that announces two valid feeds and
+// a broken one, serves the RSS feed at /feed as well by redirect and an Atom
+// feed at /atom.xml.
+func newSiteServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blog/post", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(testPage))
+	})
+	mux.HandleFunc("/news.rss", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testRSS))
+	})
+	mux.HandleFunc("/blog/comments/atom", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testAtom))
+	})
+	mux.HandleFunc("/broken.rss", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>Not a feed</html>"))
+	})
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/news.rss", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/atom.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testAtom))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestDiscover(t *testing.T) {
+	srv := newSiteServer(t)
+
+	got, err := Discover(context.Background(), srv.Client(), srv.URL+"/blog/post")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Candidate{
+		{URL: srv.URL + "/news.rss", Title: "Example News", Type: "rss", Items: 2},
+		{URL: srv.URL + "/blog/comments/atom", Title: "Example Comments", Type: "atom", Items: 1},
+		{URL: srv.URL + "/atom.xml", Title: "Example Comments", Type: "atom", Items: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want candidates\n%+v\ngot\n%+v", want, got)
+	}
+}
+
+func TestDiscover_FeedURL(t *testing.T) {
+	srv := newSiteServer(t)
+
+	got, err := Discover(context.Background(), srv.Client(), srv.URL+"/feed")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Candidate{{URL: srv.URL + "/news.rss", Title: "Example News", Type: "rss", Items: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want the feed itself %+v, got %+v", want, got)
+	}
+}
+
+func TestDiscover_Errors(t *testing.T) {
+	srv := newSiteServer(t)
+
+	for _, addr := range []string{"", "example.com", "ftp://example.com/", "/blog/post"} {
+		if _, err := Discover(context.Background(), srv.Client(), addr); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("want error %v for %q, got %v", ErrInvalidURL, addr, err)
+		}
+	}
+
+	if _, err := Discover(context.Background(), srv.Client(), srv.URL+"/missing"); err == nil {
+		t.Error("want error for missing page, got nil")
+	}
+}
+
+func TestPublicTransport(t *testing.T) {
+	srv := newSiteServer(t)
+
+	client := &http.Client{Transport: PublicTransport()}
+	if _, err := Discover(context.Background(), client, srv.URL+"/blog/post"); !errors.Is(err, ErrNotPublic) {
+		t.Errorf("want error %v for loopback server, got %v", ErrNotPublic, err)
+	}
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:4700::6810:84e5", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := public(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("want public(%s) %v, got %v", tt.addr, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package extract finds the main content of an article page and drops the
+// boilerplate around it: navigation, sidebars, comments, ads and the like.
+//
+// The algorithm follows the readability approach: every paragraph adds a score
+// to its parent and grandparent elements, weighted by the amount of text and
+// commas in it. The scores are adjusted by class and id names that hint at
+// content or boilerplate and by the share of link text, and the element with
+// the best score is taken as the article.
+package extract
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// minTextLength is the least number of characters of text the extracted
+// content must have, shorter content is likely not the article.
+const minTextLength = 250
+
+var ErrNoContent = fmt.Errorf("no article content found")
+
+var (
+	positiveHints = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|story|blog`)
+	negativeHints = regexp.MustCompile(`(?i)comment|footer|footnote|\bnav|menu|sidebar|share|social|related|promo|banner|sponsor|popup|modal|cookie|subscribe|widget|breadcrumb|advert|(^|[\s_-])ads?([\s_-]|$)`)
+)
+
+// boilerplateElements never hold the article and are removed before scoring.
+var boilerplateElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Select:   true,
+	atom.Textarea: true,
+}
+
+// paragraphElements are the text blocks that are scored.
+var paragraphElements = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Blockquote: true,
+	atom.Td:         true,
+}
+
+// Extract returns the HTML of the main content of the page. The result is not
+// sanitized and links in it are not resolved. Returns ErrNoContent if the
+// page has no block of text long enough to be an article.
+func Extract(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+
+	body := find(doc, atom.Body)
+	if body == nil {
+		return "", ErrNoContent
+	}
+	removeBoilerplate(body)
+
+	scores := make(map[*html.Node]float64)
+	walk(body, func(n *html.Node) {
+		if !paragraphElements[n.DataAtom] || n.Parent == nil {
+			return
+		}
+		text := textOf(n)
+		length := utf8.RuneCountInString(text)
+		if length < 25 {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(length)/100, 3)
+		addScore(scores, n.Parent, score)
+		if n.Parent.Parent != nil {
+			addScore(scores, n.Parent.Parent, score/2)
+		}
+	})
+
+	var best *html.Node
+	var bestScore float64
+	for n, score := range scores {
+		score *= 1 - linkDensity(n)
+		if best == nil || score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil || utf8.RuneCountInString(textOf(best)) < minTextLength {
+		return "", ErrNoContent
+	}
+
+	var b strings.Builder
+	for c := best.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
+
+// addScore adds the paragraph score to the element, giving a new candidate
+// the initial score of its tag and class hints.
+func addScore(scores map[*html.Node]float64, n *html.Node, score float64) {
+	if _, ok := scores[n]; !ok {
+		scores[n] = initialScore(n)
+	}
+	scores[n] += score
+}
+
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		score += 10
+	case atom.Div, atom.Section:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+
+	return score + hintScore(n)
+}
+
+// hintScore weighs the class and id of the element.
+func hintScore(n *html.Node) float64 {
+	var score float64
+	for _, name := range []string{attr(n, "class"), attr(n, "id")} {
+		if name == "" {
+			continue
+		}
+		if negativeHints.MatchString(name) {
+			score -= 25
+		}
+		if positiveHints.MatchString(name) {
+			score += 25
+		}
+	}
+
+	return score
+}
+
+// linkDensity returns the share of the element text that is link text.
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(textOf(n))
+	if total == 0 {
+		return 0
+	}
+
+	var links int
+	walk(n, func(c *html.Node) {
+		if c.DataAtom == atom.A {
+			links += utf8.RuneCountInString(textOf(c))
+		}
+	})
+
+	return float64(links) / float64(total)
+}
+
+// removeBoilerplate removes the boilerplate elements and the elements whose
+// class or id marks them as boilerplate, unless they also look like content.
+func removeBoilerplate(n *html.Node) {
+	var next *html.Node
+	for c := n.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type == html.CommentNode {
+			n.RemoveChild(c)
+			continue
+		}
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if boilerplateElements[c.DataAtom] || hintScore(c) < 0 {
+			n.RemoveChild(c)
+			continue
+		}
+		removeBoilerplate(c)
+	}
+}
+
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(c *html.Node) {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+			b.WriteByte(' ')
+		}
+	})
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// walk calls fn for n and every node below it.
+func walk(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
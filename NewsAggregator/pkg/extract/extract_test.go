@@ -0,0 +1,48 @@
+package extract
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	f, err := os.Open("testdata/article.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := Extract(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"Today the Go team is happy to release Go 1.23",
+		"Thanks to everyone who contributed",
+		`<img src="/images/gopher.png" alt="Gopher"/>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in extracted content, got\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"Popular posts", "Great news", "Buy our product", "Copyright", "analytics", "About"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("want boilerplate %q removed, got\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestExtract_NoContent(t *testing.T) {
+	f, err := os.Open("testdata/no_content.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := Extract(f); !errors.Is(err, ErrNoContent) {
+		t.Errorf("want error %v, got %v", ErrNoContent, err)
+	}
+}
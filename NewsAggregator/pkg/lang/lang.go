@@ -0,0 +1,174 @@
+// Package lang detects the language of post texts.
+//
+// The detector compares the character trigram frequency profile of a text with
+// the profiles of the supported languages built from the sample texts in the
+// profiles directory (Cavnar and Trenkle, N-Gram-Based Text Categorization).
+// A language is supported by adding a sample text named after its ISO 639-1
+// code; a few dozen kilobytes of news prose are needed to tell short titles apart.
+package lang
+
+import (
+	"embed"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// profileSize is the number of the most frequent trigrams compared. The
+	// profiles are large enough for the rarer trigrams that tell close
+	// languages such as Russian and Ukrainian apart in short titles.
+	profileSize = 1000
+	// minLetters is the least number of letters a text must have to be detected,
+	// shorter texts have too few trigrams to tell the language.
+	minLetters = 20
+	// maxDistanceRatio is the share of the largest possible distance a detected
+	// language may have. Texts farther from every profile are in none of the
+	// supported languages.
+	maxDistanceRatio = 0.9
+)
+
+//go:embed profiles/*.txt
+var samples embed.FS
+
+// profiles are the trigram ranks of the supported languages by language code.
+var profiles = loadProfiles()
+
+func loadProfiles() map[string]map[string]int {
+	files, err := samples.ReadDir("profiles")
+	if err != nil {
+		panic(err)
+	}
+
+	profiles := make(map[string]map[string]int, len(files))
+	for _, f := range files {
+		text, err := samples.ReadFile(path.Join("profiles", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		profiles[strings.TrimSuffix(f.Name(), ".txt")] = ranks(string(text))
+	}
+
+	return profiles
+}
+
+// Supported returns the codes of the languages Detect recognizes, sorted.
+func Supported() []string {
+	codes := make([]string, 0, len(profiles))
+	for code := range profiles {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// Detect returns the ISO 639-1 code of the language of the plain text, or an
+// empty string if the text is too short or in none of the supported languages.
+func Detect(text string) string {
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < minLetters {
+		return ""
+	}
+
+	textRanks := ranks(text)
+	maxDistance := len(textRanks) * profileSize
+
+	best, bestDistance := "", maxDistance
+	for _, code := range Supported() {
+		profile := profiles[code]
+
+		distance := 0
+		for trigram, rank := range textRanks {
+			if r, ok := profile[trigram]; ok {
+				distance += abs(r - rank)
+			} else {
+				distance += profileSize
+			}
+		}
+		if distance < bestDistance {
+			best, bestDistance = code, distance
+		}
+	}
+
+	if float64(bestDistance) > maxDistanceRatio*float64(maxDistance) {
+		return ""
+	}
+	return best
+}
+
+// Normalize reduces a language tag such as "en-US" or "ru_RU" to its lower-case
+// primary language subtag. It returns an empty string if the tag is not a language.
+func Normalize(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	tag = strings.ToLower(tag)
+
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+
+	return tag
+}
+
+// ranks returns the ranks of the profileSize most frequent trigrams of the text,
+// starting from zero. Words are lower-cased and padded with spaces, everything
+// but letters separates words.
+func ranks(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		padded := " " + word + " "
+		for i := 0; i < len(padded); {
+			_, n1 := utf8.DecodeRuneInString(padded[i:])
+			_, n2 := utf8.DecodeRuneInString(padded[i+n1:])
+			_, n3 := utf8.DecodeRuneInString(padded[i+n1+n2:])
+			if n3 == 0 {
+				break
+			}
+			counts[padded[i:i+n1+n2+n3]]++
+			i += n1
+		}
+	}
+
+	trigrams := make([]string, 0, len(counts))
+	for t := range counts {
+		trigrams = append(trigrams, t)
+	}
+	sort.Slice(trigrams, func(i, j int) bool {
+		if counts[trigrams[i]] != counts[trigrams[j]] {
+			return counts[trigrams[i]] > counts[trigrams[j]]
+		}
+		return trigrams[i] < trigrams[j]
+	})
+	if len(trigrams) > profileSize {
+		trigrams = trigrams[:profileSize]
+	}
+
+	r := make(map[string]int, len(trigrams))
+	for i, t := range trigrams {
+		r[t] = i
+	}
+
+	return r
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,88 @@
+package lang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"english", "Go 1.23 adds range over function iterators and a new package for working with them", "en"},
+		{"russian", "Вышел Go 1.23: в языке появились итераторы по функциям и новый пакет для работы с ними", "ru"},
+		{"ukrainian", "Вийшов Go 1.23: у мові з'явилися ітератори за функціями та новий пакет для роботи з ними", "uk"},
+		{"german", "Die neue Version der Programmiersprache bringt Iteratoren über Funktionen und ein neues Paket", "de"},
+		{"french", "La nouvelle version du langage apporte des itérateurs sur les fonctions et un nouveau paquet", "fr"},
+		{"spanish", "La nueva versión del lenguaje trae iteradores sobre funciones y un nuevo paquete para usarlos", "es"},
+		{"mixed, mostly russian", "Релиз Kubernetes 1.31: что нового в планировщике и почему стоит обновить кластер", "ru"},
+		{"too short", "Go 1.23", ""},
+		{"unsupported script", "新版本的编程语言增加了对函数迭代器的支持以及许多性能改进", ""},
+		{"no letters", "1234567890 !!! ??? ... 2024-08-13 12:00:00 +0000", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.text); got != tt.want {
+				t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetect_Titles(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Правительство продлило карантин до конца мая", "ru"},
+		{"Суд арестовал бывшего мэра города", "ru"},
+		{"Цены на бензин выросли третью неделю подряд", "ru"},
+		{"Водителей предупредили о гололёде на дорогах", "ru"},
+		{"Уряд продовжив карантин до кінця травня", "uk"},
+		{"Суд заарештував колишнього мера міста", "uk"},
+		{"Ціни на пальне зросли третій тиждень поспіль", "uk"},
+		{"Президент підписав закон про бюджет", "uk"},
+		{"Водіїв попередили про ожеледицю на дорогах", "uk"},
+		{"El paro baja por tercer mes consecutivo", "es"},
+		{"Un incendio obliga a desalojar a cien vecinos", "es"},
+		{"El Congreso aprueba los presupuestos", "es"},
+		{"Huelga de controladores: cientos de vuelos cancelados", "es"},
+		{"Le chômage recule pour le troisième mois consécutif", "fr"},
+		{"Un incendie oblige à évacuer cent habitants", "fr"},
+		{"Les députés adoptent le budget", "fr"},
+		{"Grève des contrôleurs : des centaines de vols annulés", "fr"},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"en":      "en",
+		"en-US":   "en",
+		"ru_RU":   "ru",
+		" RU ":    "ru",
+		"ukr":     "ukr",
+		"":        "",
+		"e":       "",
+		"english": "",
+		"12":      "",
+	}
+	for tag, want := range tests {
+		if got := Normalize(tag); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	want := []string{"de", "en", "es", "fr", "ru", "uk"}
+	if got := Supported(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want supported languages %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,152 @@
+// Package opml reads and writes feed subscription lists in the OPML format
+// used by feed readers.
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"news/pkg/storage"
+)
+
+var ErrInvalidOPML = fmt.Errorf("invalid OPML document")
+
+type document struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    head     `xml:"head"`
+	Body    body     `xml:"body"`
+}
+
+type body struct {
+	Outlines []outline `xml:"outline"`
+}
+
+type head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+// outline is either a feed, when XMLURL is set, or a folder of nested outlines.
+type outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Category string    `xml:"category,attr,omitempty"`
+	Outlines []outline `xml:"outline"`
+}
+
+func (o outline) name() string {
+	if o.Title != "" {
+		return o.Title
+	}
+	return o.Text
+}
+
+// Parse reads the feeds of an OPML document. The category of a feed is taken
+// from its category attribute or, if it has none, from the folder outline it
+// is nested in. A feed titled with its own URL gets no name.
+// Parsed feeds are enabled; their URLs are not validated.
+func Parse(r io.Reader) ([]storage.Feed, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOPML, err)
+	}
+
+	var feeds []storage.Feed
+	var walk func(outlines []outline, folder string)
+	walk = func(outlines []outline, folder string) {
+		for _, o := range outlines {
+			if o.XMLURL == "" {
+				walk(o.Outlines, o.name())
+				continue
+			}
+
+			name := o.name()
+			if name == o.XMLURL {
+				name = ""
+			}
+			category := folder
+			if c := firstCategory(o.Category); c != "" {
+				category = c
+			}
+			feeds = append(feeds, storage.Feed{
+				URL:      strings.TrimSpace(o.XMLURL),
+				Name:     name,
+				Category: category,
+				Enabled:  true,
+			})
+		}
+	}
+	walk(doc.Body.Outlines, "")
+
+	return feeds, nil
+}
+
+// firstCategory returns the first category of an OPML category attribute,
+// which is a comma-separated list of slash-delimited paths, e.g. "/golang,/news".
+func firstCategory(attr string) string {
+	first, _, _ := strings.Cut(attr, ",")
+	return strings.Trim(strings.TrimSpace(first), "/")
+}
+
+// Write encodes the feeds as an OPML 2.0 document with the given title.
+// Feeds with a category are grouped into a folder outline per category.
+// Scraped sites are left out, since they have no feed to subscribe to.
+func Write(w io.Writer, title string, feeds []storage.Feed) error {
+	doc := document{
+		Version: "2.0",
+		Head: head{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	folders := make(map[string][]outline)
+	for _, f := range feeds {
+		if f.Scraper != nil {
+			continue
+		}
+		o := outline{
+			Text:   f.Name,
+			Title:  f.Name,
+			Type:   "rss",
+			XMLURL: f.URL,
+		}
+		if o.Text == "" {
+			o.Text = f.URL
+		}
+		if f.Category == "" {
+			doc.Body.Outlines = append(doc.Body.Outlines, o)
+			continue
+		}
+		folders[f.Category] = append(folders[f.Category], o)
+	}
+
+	categories := make([]string, 0, len(folders))
+	for c := range folders {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+	for _, c := range categories {
+		doc.Body.Outlines = append(doc.Body.Outlines, outline{Text: c, Title: c, Outlines: folders[c]})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,87 @@
+package opml
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"news/pkg/storage"
+)
+
+const testOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="golang">
+      <outline type="rss" text="Habr Go" xmlUrl="https://habr.com/ru/rss/hub/go/all/"/>
+      <outline type="rss" text="Go Blog" title="The Go Blog" xmlUrl="https://go.dev/blog/feed.atom" category="/news,/misc"/>
+    </outline>
+    <outline type="rss" text="https://example.com/rss" xmlUrl="https://example.com/rss"/>
+  </body>
+</opml>`
+
+func TestParse(t *testing.T) {
+	got, err := Parse(strings.NewReader(testOPML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []storage.Feed{
+		{URL: "https://habr.com/ru/rss/hub/go/all/", Name: "Habr Go", Category: "golang", Enabled: true},
+		{URL: "https://go.dev/blog/feed.atom", Name: "The Go Blog", Category: "news", Enabled: true},
+		{URL: "https://example.com/rss", Enabled: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want feeds\n%+v\ngot feeds\n%+v", want, got)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse(strings.NewReader("<rss></rss>"))
+	if !errors.Is(err, ErrInvalidOPML) {
+		t.Errorf("want error %v, got %v", ErrInvalidOPML, err)
+	}
+}
+
+func TestWrite_RoundTrip(t *testing.T) {
+	feeds := []storage.Feed{
+		{URL: "https://example.com/rss", Enabled: true},
+		{URL: "https://habr.com/ru/rss/hub/go/all/", Name: "Habr Go", Category: "golang", Enabled: true},
+		{URL: "https://go.dev/blog/feed.atom", Name: "Go Blog", Category: "golang", Enabled: true},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Test", feeds); err != nil {
+		t.Fatalf("unexpected error while writing: %v", err)
+	}
+
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error while parsing written document: %v", err)
+	}
+	if !reflect.DeepEqual(got, feeds) {
+		t.Errorf("want feeds\n%+v\ngot feeds\n%+v", feeds, got)
+	}
+}
+
+func TestWrite_SkipsScrapedSites(t *testing.T) {
+	feeds := []storage.Feed{
+		{URL: "https://example.com/rss", Enabled: true},
+		{URL: "https://example.com/news/", Enabled: true, Scraper: &storage.Scraper{Item: "article", Title: "h2", Link: "a"}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Test", feeds); err != nil {
+		t.Fatalf("unexpected error while writing: %v", err)
+	}
+
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error while parsing written document: %v", err)
+	}
+	if !reflect.DeepEqual(got, feeds[:1]) {
+		t.Errorf("want only the feed written, got %+v", got)
+	}
+}
@@ -0,0 +1,53 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// CommentsClient asks the comments service whether posts have comments.
+type CommentsClient struct {
+	URL    string // Address of the comments service.
+	client *http.Client
+}
+
+// NewCommentsClient returns a client of the comments service at the given
+// address. If client is nil, a client with a 10 second timeout is used.
+func NewCommentsClient(addr string, client *http.Client) *CommentsClient {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &CommentsClient{URL: strings.TrimRight(addr, "/"), client: client}
+}
+
+// HasComments reports whether the post has comments. The comments service
+// answers 404 Not Found for a post without comments.
+func (c *CommentsClient) HasComments(ctx context.Context, postID uuid.UUID) (bool, error) {
+	target := c.URL + "/comments?" + url.Values{"post_id": {postID.String()}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("X-Request-Id", uuid.Must(uuid.NewV4()).String())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+}
@@ -0,0 +1,222 @@
+// Package retention removes old posts from the storage according to
+// a retention policy.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"news/pkg/storage"
+)
+
+const (
+	// defaultInterval is the time between purges in minutes used when interval is not set.
+	defaultInterval = 60
+	// defaultBatchSize is the number of posts removed at once when batchSize is not set.
+	defaultBatchSize = 500
+	// commentChecks is the number of posts whose comments are checked at once.
+	commentChecks = 8
+)
+
+var ErrInvalidConfig = fmt.Errorf("invalid retention config")
+
+// Config is the retention policy. Posts are kept forever unless MaxAgeDays or
+// MaxPerSource is set.
+type Config struct {
+	MaxAgeDays    int      `toml:"maxAgeDays"`    // Posts published earlier are removed.
+	MaxPerSource  int      `toml:"maxPerSource"`  // Only this many latest posts of each source are kept.
+	Pinned        []string `toml:"pinned"`        // IDs of the posts that are never removed.
+	KeepCommented bool     `toml:"keepCommented"` // Keep the posts that have comments, see CommentsURL.
+	CommentsURL   string   `toml:"commentsURL"`   // Address of the comments service.
+	Interval      int      `toml:"interval"`      // Minutes between purges.
+	BatchSize     int      `toml:"batchSize"`     // Number of posts removed at once.
+}
+
+// Enabled reports whether the policy removes any posts.
+func (c Config) Enabled() bool {
+	return c.MaxAgeDays > 0 || c.MaxPerSource > 0
+}
+
+// CommentChecker tells whether a post has comments.
+type CommentChecker interface {
+	HasComments(ctx context.Context, postID uuid.UUID) (bool, error)
+}
+
+// Janitor removes the posts expired by the retention policy in batches.
+type Janitor struct {
+	DB           storage.Storage
+	MaxAge       time.Duration  // Zero keeps posts of any age.
+	MaxPerSource int            // Zero keeps any number of posts per source.
+	Pinned       []uuid.UUID    // Posts that are never removed.
+	Comments     CommentChecker // Posts with comments are kept if set.
+	Interval     time.Duration
+	BatchSize    int
+}
+
+// New creates a janitor that enforces the policy from the config.
+func New(db storage.Storage, conf Config) (*Janitor, error) {
+	if conf.MaxAgeDays < 0 || conf.MaxPerSource < 0 || conf.Interval < 0 || conf.BatchSize < 0 {
+		return nil, fmt.Errorf("%w: negative maxAgeDays, maxPerSource, interval or batchSize", ErrInvalidConfig)
+	}
+	if conf.KeepCommented && conf.CommentsURL == "" {
+		return nil, fmt.Errorf("%w: keepCommented requires commentsURL", ErrInvalidConfig)
+	}
+	if conf.Interval == 0 {
+		conf.Interval = defaultInterval
+	}
+	if conf.BatchSize == 0 {
+		conf.BatchSize = defaultBatchSize
+	}
+
+	j := Janitor{
+		DB:           db,
+		MaxAge:       time.Duration(conf.MaxAgeDays) * 24 * time.Hour,
+		MaxPerSource: conf.MaxPerSource,
+		Interval:     time.Duration(conf.Interval) * time.Minute,
+		BatchSize:    conf.BatchSize,
+	}
+	for _, s := range conf.Pinned {
+		id, err := uuid.FromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: pinned post %q: %w", ErrInvalidConfig, s, err)
+		}
+		j.Pinned = append(j.Pinned, id)
+	}
+	if conf.KeepCommented {
+		j.Comments = NewCommentsClient(conf.CommentsURL, nil)
+	}
+
+	return &j, nil
+}
+
+// Run purges the expired posts right away and then once per Interval until
+// ctx is cancelled. Errors are logged and the purge is retried on the next run.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := j.Purge(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warnf("[janitor] purge stopped after removing %d posts: %v", removed, err)
+		} else if removed > 0 {
+			log.Infof("[janitor] removed %d expired posts", removed)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Purge removes the expired posts in batches of BatchSize and returns the number
+// of removed posts. The expired posts found to have comments are kept till the
+// end of the purge, the next one checks them again.
+func (j *Janitor) Purge(ctx context.Context) (removed int, err error) {
+	policy := j.policy()
+	for {
+		ids, err := j.DB.ExpiredPosts(ctx, policy, j.BatchSize)
+		if err != nil {
+			return removed, err
+		}
+		if len(ids) == 0 {
+			return removed, nil
+		}
+
+		batch := ids
+		if j.Comments != nil {
+			commented, err := j.commented(ctx, ids)
+			if err != nil {
+				return removed, err
+			}
+			batch = nil
+			for i, id := range ids {
+				if commented[i] {
+					policy.Keep = append(policy.Keep, id)
+					continue
+				}
+				batch = append(batch, id)
+			}
+		}
+
+		n, err := j.DB.DeletePosts(ctx, batch)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+		log.Debugf("[janitor] removed %d of %d expired posts in batch", n, len(ids))
+
+		// Nothing was removed or kept, the next batch would be the same.
+		if n == 0 && len(batch) == len(ids) {
+			return removed, nil
+		}
+		if len(ids) < j.BatchSize {
+			return removed, nil
+		}
+	}
+}
+
+// policy returns the storage retention policy as of now.
+func (j *Janitor) policy() storage.Retention {
+	p := storage.Retention{
+		MaxPerSource: j.MaxPerSource,
+		Keep:         append([]uuid.UUID(nil), j.Pinned...),
+	}
+	if j.MaxAge > 0 {
+		p.Before = time.Now().Add(-j.MaxAge)
+	}
+
+	return p
+}
+
+// commented reports which of the posts have comments. At most commentChecks
+// posts are checked at once; the first failed check cancels the rest.
+func (j *Janitor) commented(ctx context.Context, ids []uuid.UUID) ([]bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	commented := make([]bool, len(ids))
+	sem := make(chan struct{}, commentChecks)
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		failed   error
+	)
+	for i, id := range ids {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			has, err := j.Comments.HasComments(ctx, id)
+			if err != nil {
+				failOnce.Do(func() {
+					failed = fmt.Errorf("unable to check comments of post %s: %w", id, err)
+					cancel()
+				})
+				return
+			}
+			commented[i] = has
+		}()
+	}
+	wg.Wait()
+
+	if failed != nil {
+		return nil, failed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return commented, nil
+}
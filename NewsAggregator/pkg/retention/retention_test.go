@@ -0,0 +1,198 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"news/pkg/storage"
+	"news/pkg/storage/memdb"
+)
+
+// fakeComments reports the posts from the set as commented, fails the checks
+// of the broken ones and counts the checks.
+type fakeComments struct {
+	commented map[uuid.UUID]bool
+	broken    map[uuid.UUID]bool
+
+	mu     sync.Mutex
+	checks int
+}
+
+func (f *fakeComments) HasComments(_ context.Context, id uuid.UUID) (bool, error) {
+	f.mu.Lock()
+	f.checks++
+	f.mu.Unlock()
+
+	if f.broken[id] {
+		return false, errors.New("comments service unavailable")
+	}
+	return f.commented[id], nil
+}
+
+// addTestPosts adds n posts published an hour apart, newest first, and returns their IDs.
+func addTestPosts(t *testing.T, db storage.Storage, n int) []uuid.UUID {
+	t.Helper()
+
+	now := time.Now().UTC()
+	var ids []uuid.UUID
+	for i := range n {
+		post := storage.Post{
+			Title:     fmt.Sprintf("Post %d", i),
+			Link:      fmt.Sprintf("https://example.com/%d", i),
+			Published: now.Add(-time.Duration(i) * time.Hour),
+			Source:    storage.Source{URL: "https://example.com/rss", Name: "Example"},
+		}
+		id, err := db.AddPost(context.Background(), post)
+		if err != nil {
+			t.Fatalf("unexpected error while adding post: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func TestJanitor_Purge(t *testing.T) {
+	db := memdb.New()
+	ids := addTestPosts(t, db, 10)
+
+	comments := &fakeComments{commented: map[uuid.UUID]bool{ids[8]: true}}
+	j := Janitor{
+		DB:           db,
+		MaxPerSource: 3,
+		Pinned:       []uuid.UUID{ids[9]},
+		Comments:     comments,
+		BatchSize:    2,
+	}
+
+	removed, err := j.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error while purging: %v", err)
+	}
+	if removed != 5 {
+		t.Errorf("want 5 posts removed, got %d", removed)
+	}
+	for i, id := range ids {
+		_, err := db.Post(context.Background(), id)
+		kept := i < 3 || i >= 8
+		if kept && err != nil {
+			t.Errorf("want post #%d kept, got error %v", i, err)
+		}
+		if !kept && !errors.Is(err, storage.ErrPostNotFound) {
+			t.Errorf("want post #%d removed, got error %v", i, err)
+		}
+	}
+
+	// The commented post is checked again on the next purge only.
+	checks := comments.checks
+	if removed, err := j.Purge(context.Background()); err != nil || removed != 0 {
+		t.Errorf("want nothing removed on the second purge, got %d removed and error %v", removed, err)
+	}
+	if comments.checks != checks+1 {
+		t.Errorf("want 1 comment check on the second purge, got %d", comments.checks-checks)
+	}
+}
+
+func TestJanitor_PurgeCommentsError(t *testing.T) {
+	db := memdb.New()
+	ids := addTestPosts(t, db, 30)
+
+	comments := &fakeComments{broken: map[uuid.UUID]bool{ids[25]: true}}
+	j := Janitor{DB: db, MaxPerSource: 5, Comments: comments, BatchSize: 100}
+
+	removed, err := j.Purge(context.Background())
+	if err == nil {
+		t.Fatal("want error for failed comment check, got nil")
+	}
+	if removed != 0 {
+		t.Errorf("want no posts removed, got %d", removed)
+	}
+	if _, err := db.Post(context.Background(), ids[29]); err != nil {
+		t.Errorf("want expired posts kept, got error %v", err)
+	}
+}
+
+func TestJanitor_PurgeMaxAge(t *testing.T) {
+	db := memdb.New()
+	ids := addTestPosts(t, db, 5)
+
+	j := Janitor{DB: db, MaxAge: 150 * time.Minute, BatchSize: 10}
+	removed, err := j.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error while purging: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("want 2 posts removed, got %d", removed)
+	}
+	if _, err := db.Post(context.Background(), ids[2]); err != nil {
+		t.Errorf("want post #2 kept, got error %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	pinned := uuid.Must(uuid.NewV4())
+	j, err := New(memdb.New(), Config{MaxAgeDays: 30, Pinned: []string{pinned.String()}, KeepCommented: true, CommentsURL: "http://comments:8077/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if j.MaxAge != 30*24*time.Hour || j.Interval != defaultInterval*time.Minute || j.BatchSize != defaultBatchSize {
+		t.Errorf("want max age, default interval and batch size set, got %+v", j)
+	}
+	if len(j.Pinned) != 1 || j.Pinned[0] != pinned {
+		t.Errorf("want pinned %v, got %v", pinned, j.Pinned)
+	}
+	if c, ok := j.Comments.(*CommentsClient); !ok || c.URL != "http://comments:8077" {
+		t.Errorf("want comments client for http://comments:8077, got %+v", j.Comments)
+	}
+
+	for _, conf := range []Config{
+		{MaxAgeDays: -1},
+		{MaxPerSource: 10, Pinned: []string{"not a uuid"}},
+		{MaxPerSource: 10, KeepCommented: true},
+	} {
+		if _, err := New(memdb.New(), conf); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("want error %v for %+v, got %v", ErrInvalidConfig, conf, err)
+		}
+	}
+}
+
+func TestCommentsClient_HasComments(t *testing.T) {
+	commented := uuid.Must(uuid.NewV4())
+	broken := uuid.Must(uuid.NewV4())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/comments" || r.Header.Get("X-Request-Id") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("post_id") {
+		case commented.String():
+			w.Write([]byte(`[{"id":1,"content":"First!"}]`))
+		case broken.String():
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewCommentsClient(srv.URL, srv.Client())
+	ctx := context.Background()
+
+	if has, err := c.HasComments(ctx, commented); err != nil || !has {
+		t.Errorf("want comments found, got %v and error %v", has, err)
+	}
+	if has, err := c.HasComments(ctx, uuid.Must(uuid.NewV4())); err != nil || has {
+		t.Errorf("want no comments, got %v and error %v", has, err)
+	}
+	if _, err := c.HasComments(ctx, broken); err == nil {
+		t.Error("want error for unexpected status, got nil")
+	}
+}
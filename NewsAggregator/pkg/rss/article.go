@@ -0,0 +1,185 @@
+package rss
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"news/pkg/extract"
+	"news/pkg/storage"
+)
+
+const (
+	// defaultFullTextConcurrency limits the number of articles downloaded at once
+	// when full_text_concurrency is not set.
+	defaultFullTextConcurrency = 4
+	// articleCacheSize is the number of extracted articles kept in memory.
+	articleCacheSize = 1000
+	// maxArticleSize limits the size of a downloaded article page.
+	maxArticleSize = 5 << 20
+)
+
+// articleFetcher downloads the articles of full-text feeds and extracts their
+// main content. Extracted articles are cached by link, so an article is not
+// downloaded again while the feed item it comes from stays the same.
+type articleFetcher struct {
+	client *http.Client
+	sem    chan struct{} // Limits the number of concurrent downloads.
+
+	mu    sync.Mutex
+	cache map[string]*list.Element // Elements of order by article link.
+	order *list.List               // Cached articles, most recently used first.
+}
+
+type cachedArticle struct {
+	link    string
+	teaser  uint64 // Hash of the feed item content the article was fetched for.
+	content string // Empty if the page has no article content.
+}
+
+// newArticleFetcher returns an article fetcher that downloads at most
+// concurrency articles at once. If client is nil, http.DefaultClient is used.
+func newArticleFetcher(client *http.Client, concurrency int) *articleFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if concurrency <= 0 {
+		concurrency = defaultFullTextConcurrency
+	}
+
+	return &articleFetcher{
+		client: client,
+		sem:    make(chan struct{}, concurrency),
+		cache:  make(map[string]*list.Element),
+		order:  list.New(),
+	}
+}
+
+// fill replaces the content of the posts with the articles downloaded from
+// their links. Posts whose article can't be downloaded or has no content
+// that can be extracted keep the content from the feed.
+func (a *articleFetcher) fill(ctx context.Context, feed storage.Feed, posts []storage.Post) {
+	var wg sync.WaitGroup
+	for i := range posts {
+		if posts[i].Link == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(post *storage.Post) {
+			defer wg.Done()
+
+			content, err := a.article(ctx, feed, post.Link, post.Content)
+			if err != nil {
+				log.Debugf("[rss] full text of %s not extracted: %v", post.Link, err)
+				return
+			}
+			if content != "" {
+				post.Content = content
+			}
+		}(&posts[i])
+	}
+	wg.Wait()
+}
+
+// article returns the main content of the article at link, from the cache if
+// the feed item content is the same as when it was cached.
+func (a *articleFetcher) article(ctx context.Context, feed storage.Feed, link, teaser string) (string, error) {
+	h := fnv.New64a()
+	h.Write([]byte(teaser))
+	sum := h.Sum64()
+
+	if content, ok := a.cached(link, sum); ok {
+		return content, nil
+	}
+
+	select {
+	case a.sem <- struct{}{}:
+		defer func() { <-a.sem }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	content, err := a.download(ctx, feed, link)
+	if err != nil && !errors.Is(err, extract.ErrNoContent) {
+		return "", err
+	}
+	// Pages without article content are cached too, so they are not downloaded on every poll.
+	a.store(cachedArticle{link: link, teaser: sum, content: content})
+
+	return content, err
+}
+
+func (a *articleFetcher) download(ctx context.Context, feed storage.Feed, link string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout(feed, defaultFetchTimeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", err
+	}
+	if feed.UserAgent != "" {
+		req.Header.Set("User-Agent", feed.UserAgent)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		if mt, _, _ := mime.ParseMediaType(ct); mt != "text/html" && mt != "application/xhtml+xml" {
+			return "", fmt.Errorf("unexpected content type: %s", ct)
+		}
+	}
+
+	return extract.Extract(io.LimitReader(resp.Body, maxArticleSize))
+}
+
+func (a *articleFetcher) cached(link string, teaser uint64) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	el, ok := a.cache[link]
+	if !ok {
+		return "", false
+	}
+	article := el.Value.(cachedArticle)
+	if article.teaser != teaser {
+		return "", false
+	}
+	a.order.MoveToFront(el)
+
+	return article.content, true
+}
+
+// store caches the article, evicting the least recently used one if the cache is full.
+func (a *articleFetcher) store(article cachedArticle) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if el, ok := a.cache[article.link]; ok {
+		el.Value = article
+		a.order.MoveToFront(el)
+		return
+	}
+
+	a.cache[article.link] = a.order.PushFront(article)
+	if a.order.Len() > articleCacheSize {
+		oldest := a.order.Back()
+		a.order.Remove(oldest)
+		delete(a.cache, oldest.Value.(cachedArticle).link)
+	}
+}
@@ -0,0 +1,169 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"news/pkg/storage"
+)
+
+// newArticleServer serves a feed of n teaser items at /feed and the article
+// fixture at /articles/{i}. The number of article requests is counted in served.
+// There is no robots.txt.
+func newArticleServer(t *testing.T, n int, served *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	article, err := os.ReadFile("../extract/testdata/article.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/feed" {
+			var items strings.Builder
+			for i := 0; i < n; i++ {
+				fmt.Fprintf(&items, "<item><title>Post %d</title><description>Teaser</description><link>%s/articles/%d</link></item>", i, srv.URL, i)
+			}
+			fmt.Fprintf(w, `<rss version="2.0"><channel><title>Teasers</title>%s</channel></rss>`, items.String())
+			return
+		}
+		served.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(article)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestParser_FullText(t *testing.T) {
+	var served atomic.Int32
+	srv := newArticleServer(t, 1, &served)
+
+	parser := NewParser(config{HostRate: 1000}, nil)
+	feed := storage.Feed{URL: srv.URL + "/feed", Enabled: true, FullText: true}
+
+	msg := parser.parse(context.Background(), feed)
+	if msg.Err != nil {
+		t.Fatalf("unexpected error: %v", msg.Err)
+	}
+	if len(msg.Data) != 1 {
+		t.Fatalf("want 1 post, got %d", len(msg.Data))
+	}
+
+	content := msg.Data[0].Content
+	if !strings.Contains(content, "Thanks to everyone who contributed") {
+		t.Errorf("want full article content, got %q", content)
+	}
+	if want := `<img src="` + srv.URL + `/images/gopher.png"`; !strings.Contains(content, want) {
+		t.Errorf("want image with resolved link %s, got %q", want, content)
+	}
+	if strings.Contains(content, "Popular posts") {
+		t.Errorf("want boilerplate removed, got %q", content)
+	}
+
+	// The fetcher state is dropped to get the feed in full again.
+	parser.fetcher = NewFetcher(nil, nil)
+	if msg := parser.parse(context.Background(), feed); msg.Err != nil {
+		t.Fatalf("unexpected error on second parse: %v", msg.Err)
+	}
+	if n := served.Load(); n != 1 {
+		t.Errorf("want unchanged article downloaded once, got %d downloads", n)
+	}
+}
+
+func TestParser_FullTextRules(t *testing.T) {
+	var served atomic.Int32
+	srv := newArticleServer(t, 3, &served)
+
+	parser := NewParser(config{HostRate: 1000}, nil)
+	feed := storage.Feed{
+		URL:      srv.URL + "/feed",
+		Enabled:  true,
+		FullText: true,
+		Rules: storage.Rules{
+			Include: []storage.Rule{{Field: storage.RuleContent, Pattern: "Thanks to everyone"}},
+			Exclude: []storage.Rule{{Field: storage.RuleTitle, Pattern: "^Post 1$"}},
+		},
+	}
+
+	msg := parser.parse(context.Background(), feed)
+	if msg.Err != nil {
+		t.Fatalf("unexpected error: %v", msg.Err)
+	}
+	// The include rule for the content matches the full text only.
+	if len(msg.Data) != 2 {
+		t.Errorf("want 2 posts, got %d", len(msg.Data))
+	}
+	// The article of the post excluded by its title is not downloaded.
+	if n := served.Load(); n != 2 {
+		t.Errorf("want 2 article downloads, got %d", n)
+	}
+}
+
+func TestParser_FullTextDisabled(t *testing.T) {
+	var served atomic.Int32
+	srv := newArticleServer(t, 1, &served)
+
+	parser := NewParser(config{}, nil)
+	msg := parser.parse(context.Background(), storage.Feed{URL: srv.URL + "/feed", Enabled: true})
+	if msg.Err != nil {
+		t.Fatalf("unexpected error: %v", msg.Err)
+	}
+	if msg.Data[0].Content != "Teaser" {
+		t.Errorf("want teaser content, got %q", msg.Data[0].Content)
+	}
+	if n := served.Load(); n != 0 {
+		t.Errorf("want no article downloads, got %d", n)
+	}
+}
+
+func TestArticleFetcher_Concurrency(t *testing.T) {
+	const limit = 2
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	posts := make([]storage.Post, 8)
+	for i := range posts {
+		posts[i] = storage.Post{Link: fmt.Sprintf("%s/articles/%d", srv.URL, i), Content: "Teaser"}
+	}
+
+	newArticleFetcher(nil, limit).fill(context.Background(), storage.Feed{}, posts)
+
+	if maxInFlight > limit {
+		t.Errorf("want at most %d concurrent downloads, got %d", limit, maxInFlight)
+	}
+	for _, p := range posts {
+		if p.Content != "Teaser" {
+			t.Errorf("want teaser kept for failed download, got %q", p.Content)
+		}
+	}
+}
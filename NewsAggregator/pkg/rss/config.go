@@ -0,0 +1,108 @@
+package rss
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"news/pkg/storage"
+)
+
+const (
+	// defaultRequestPeriod is the polling interval in minutes used when request_period is not set.
+	defaultRequestPeriod = 5
+	// defaultWorkers is the number of feeds fetched at once when workers is not set.
+	defaultWorkers = 10
+)
+
+var ErrInvalidConfig = fmt.Errorf("invalid RSS parser config")
+
+// pollInterval returns the polling interval of the feed, or def if it is not set.
+func pollInterval(f storage.Feed, def time.Duration) time.Duration {
+	if f.Interval > 0 {
+		return time.Duration(f.Interval) * time.Minute
+	}
+	return def
+}
+
+// fetchTimeout returns the request timeout of the feed, or def if it is not set.
+func fetchTimeout(f storage.Feed, def time.Duration) time.Duration {
+	if f.Timeout > 0 {
+		return time.Duration(f.Timeout) * time.Second
+	}
+	return def
+}
+
+type config struct {
+	RSS                 []storage.Feed `json:"rss"`
+	RequestPeriod       int            `json:"request_period"`
+	MaxBackoff          int            `json:"max_backoff"` // Cap of the polling interval of a failing feed in minutes.
+	StateFile           string         `json:"state_file"`
+	FullTextConcurrency int            `json:"full_text_concurrency"` // Limit of articles of full-text feeds downloaded at once.
+	Workers             int            `json:"workers"`               // Number of feeds fetched at once.
+	UserAgent           string         `json:"user_agent"`            // User-Agent of the requests of feeds without one of their own.
+	HostRate            float64        `json:"host_rate"`             // Requests per second to a host.
+	HostBurst           int            `json:"host_burst"`            // Requests to a host allowed back to back.
+	// Timezones maps timezone abbreviations to UTC offsets like "+0300" or "+03:00".
+	// It extends and overrides the table used to parse item dates.
+	Timezones map[string]string `json:"timezones"`
+}
+
+// LoadConf loads the parser config from the JSON file at the given path.
+// The rss list may contain feed objects as well as plain URL strings.
+// Feeds without an ID get a UUIDv5 based on their URL.
+func LoadConf(path string) (*config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var conf config
+	err = json.Unmarshal(b, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+
+	for i, f := range conf.RSS {
+		if f.ID == uuid.Nil {
+			conf.RSS[i].ID = uuid.NewV5(uuid.NamespaceURL, f.URL)
+		}
+	}
+
+	return &conf, nil
+}
+
+func (c *config) validate() error {
+	if c.RequestPeriod < 0 || c.MaxBackoff < 0 || c.FullTextConcurrency < 0 || c.Workers < 0 {
+		return fmt.Errorf("%w: negative request_period, max_backoff, full_text_concurrency or workers", ErrInvalidConfig)
+	}
+	if c.HostRate < 0 || c.HostBurst < 0 {
+		return fmt.Errorf("%w: negative host_rate or host_burst", ErrInvalidConfig)
+	}
+
+	for abbr, offset := range c.Timezones {
+		if _, err := parseOffset(offset); err != nil {
+			return fmt.Errorf("%w: timezone %s: %w", ErrInvalidConfig, abbr, err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, f := range c.RSS {
+		if err := f.Validate(); err != nil {
+			return fmt.Errorf("%w: feed #%d: %w", ErrInvalidConfig, i, err)
+		}
+		if seen[f.URL] {
+			return fmt.Errorf("%w: duplicate feed %s", ErrInvalidConfig, f.URL)
+		}
+		seen[f.URL] = true
+	}
+
+	return nil
+}
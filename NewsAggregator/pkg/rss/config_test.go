@@ -0,0 +1,111 @@
+package rss
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gofrs/uuid"
+
+	"news/pkg/storage"
+)
+
+func writeConf(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("unable to write test config: %v", err)
+	}
+
+	return path
+}
+
+func TestLoadConf(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantFeeds []storage.Feed
+		wantErr   error
+	}{
+		{
+			name: "legacy URL list",
+			data: `{"rss": ["https://example.com/rss", "https://example.org/feed"], "request_period": 5}`,
+			wantFeeds: []storage.Feed{
+				{URL: "https://example.com/rss", Enabled: true},
+				{URL: "https://example.org/feed", Enabled: true},
+			},
+		},
+		{
+			name: "feed objects",
+			data: `{"rss": [
+				{
+					"url": "https://example.com/rss",
+					"name": "Example",
+					"category": "golang",
+					"interval": 15,
+					"user_agent": "FeedFusion/1.0",
+					"timeout": 10,
+					"full_text": true
+				},
+				{"url": "https://example.org/feed", "enabled": false}
+			]}`,
+			wantFeeds: []storage.Feed{
+				{
+					URL:       "https://example.com/rss",
+					Name:      "Example",
+					Category:  "golang",
+					Interval:  15,
+					Enabled:   true,
+					UserAgent: "FeedFusion/1.0",
+					Timeout:   10,
+					FullText:  true,
+				},
+				{URL: "https://example.org/feed", Enabled: false},
+			},
+		},
+		{
+			name: "mixed list",
+			data: `{"rss": ["https://example.com/rss", {"url": "https://example.org/feed", "name": "Org"}]}`,
+			wantFeeds: []storage.Feed{
+				{URL: "https://example.com/rss", Enabled: true},
+				{URL: "https://example.org/feed", Name: "Org", Enabled: true},
+			},
+		},
+		{
+			name:    "feed without url",
+			data:    `{"rss": [{"name": "Nameless"}]}`,
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "invalid timezone offset",
+			data:    `{"rss": ["https://example.com/rss"], "timezones": {"MSK": "3 hours"}}`,
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "duplicate feed",
+			data:    `{"rss": ["https://example.com/rss", {"url": "https://example.com/rss"}]}`,
+			wantErr: ErrInvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := LoadConf(writeConf(t, tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			for i, f := range tt.wantFeeds {
+				tt.wantFeeds[i].ID = uuid.NewV5(uuid.NamespaceURL, f.URL)
+			}
+			if !reflect.DeepEqual(conf.RSS, tt.wantFeeds) {
+				t.Errorf("want feeds\n%+v\ngot feeds\n%+v", tt.wantFeeds, conf.RSS)
+			}
+		})
+	}
+}
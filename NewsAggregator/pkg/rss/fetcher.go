@@ -0,0 +1,218 @@
+package rss
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"news/pkg/storage"
+)
+
+const (
+	// defaultFetchTimeout limits a fetch of a feed that has no timeout of its own.
+	defaultFetchTimeout = 30 * time.Second
+	// maxFeedSize limits the size of a downloaded feed.
+	maxFeedSize = 20 << 20
+)
+
+// ErrNotModified is returned by Fetcher.Fetch when the server responds with
+// 304 Not Modified, i.e. the feed has not changed since it was last fetched.
+var ErrNotModified = fmt.Errorf("feed not modified")
+
+// FeedState holds the HTTP cache validators received with the last
+// successfully parsed copy of a feed and the WebSub hub the copy announced.
+type FeedState struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Hub          string `json:"hub,omitempty"`   // Address of the WebSub hub of the feed.
+	Topic        string `json:"topic,omitempty"` // Address the hub knows the feed by.
+}
+
+// StateStore keeps the FeedState of every feed, keyed by feed URL, and
+// persists it to a JSON file so the validators survive restarts.
+// A store with an empty path is kept in memory only.
+type StateStore struct {
+	mu     sync.Mutex
+	path   string
+	states map[string]FeedState
+}
+
+// LoadState reads the feed states from the JSON file at the given path.
+// A missing file is not an error, it results in an empty store.
+func LoadState(path string) (*StateStore, error) {
+	s := StateStore{
+		path:   path,
+		states: make(map[string]FeedState),
+	}
+	if path == "" {
+		return &s, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &s.states); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal feed state %s: %w", path, err)
+	}
+
+	return &s, nil
+}
+
+// Get returns the stored state of the feed.
+func (s *StateStore) Get(url string) FeedState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.states[url]
+}
+
+// Set updates the state of the feed and writes the whole store to disk.
+func (s *StateStore) Set(url string, state FeedState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[url] = state
+	if s.path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(s.states, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state file.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Fetcher downloads feeds using conditional HTTP requests. It sends the
+// validators stored for the feed as If-None-Match and If-Modified-Since
+// headers and reports ErrNotModified when the server answers 304.
+type Fetcher struct {
+	client *http.Client
+	state  *StateStore
+}
+
+// NewFetcher returns a Fetcher that keeps validators in the given store.
+// If client is nil, http.DefaultClient is used; if state is nil, validators
+// are kept in memory only.
+func NewFetcher(client *http.Client, state *StateStore) *Fetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if state == nil {
+		state, _ = LoadState("")
+	}
+
+	return &Fetcher{
+		client: client,
+		state:  state,
+	}
+}
+
+// Fetch downloads and parses the feed, or scrapes the list page of a site
+// without a feed, applying its User-Agent and timeout settings. Along with the
+// feed it returns the state of the copy: the validators of the response and
+// the hub the feed announces, known even if the feed has not been modified.
+// The state is not stored until it is committed, so the copy is requested in
+// full again until its posts have been saved, see Commit.
+func (f *Fetcher) Fetch(ctx context.Context, feed storage.Feed) (*gofeed.Feed, FeedState, error) {
+	url := feed.URL
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout(feed, defaultFetchTimeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, FeedState{}, err
+	}
+	if feed.UserAgent != "" {
+		req.Header.Set("User-Agent", feed.UserAgent)
+	}
+
+	state := f.state.Get(url)
+	if state.ETag != "" {
+		req.Header.Set("If-None-Match", state.ETag)
+	}
+	if state.LastModified != "" {
+		req.Header.Set("If-Modified-Since", state.LastModified)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, FeedState{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, state, ErrNotModified
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, FeedState{}, gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	newState := FeedState{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	var parsed *gofeed.Feed
+	if feed.Scraper != nil {
+		parsed, err = scrape(resp.Body, resp.Request.URL, *feed.Scraper)
+	} else {
+		var body []byte
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+		if err != nil {
+			return nil, FeedState{}, err
+		}
+		parsed, err = gofeed.NewParser().Parse(bytes.NewReader(body))
+		newState.Hub, newState.Topic = hubLinks(body, resp.Header, resp.Request.URL)
+	}
+	if err != nil {
+		return nil, FeedState{}, err
+	}
+
+	return parsed, newState, nil
+}
+
+// Commit stores the state of the copy of the feed returned by Fetch once the
+// posts of the copy have been saved. Until then the previous validators are
+// sent, so a copy whose posts were lost is not reported as not modified.
+func (f *Fetcher) Commit(url string, state FeedState) error {
+	if f.state.Get(url) == state {
+		return nil
+	}
+	return f.state.Set(url, state)
+}
@@ -0,0 +1,154 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"news/pkg/storage"
+)
+
+const testFeedXML = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+	<title>Test Feed</title>
+	<link>https://example.com</link>
+	<item>
+		<title>Test Post</title>
+		<description>Test Content</description>
+		<link>https://example.com/1</link>
+		<pubDate>Wed, 01 May 2024 12:00:00 GMT</pubDate>
+	</item>
+</channel>
+</rss>`
+
+const (
+	testETag         = `"abc123"`
+	testLastModified = "Wed, 01 May 2024 12:00:00 GMT"
+)
+
+// newConditionalServer returns a server that serves testFeedXML and answers
+// 304 Not Modified to requests carrying the matching validators.
+// The number of full responses is counted in served.
+func newConditionalServer(t *testing.T, served *int) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == testETag || r.Header.Get("If-Modified-Since") == testLastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		*served++
+		w.Header().Set("ETag", testETag)
+		w.Header().Set("Last-Modified", testLastModified)
+		w.Write([]byte(testFeedXML))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestFetcher_FetchNotModified(t *testing.T) {
+	var served int
+	srv := newConditionalServer(t, &served)
+
+	fetcher := NewFetcher(nil, nil)
+
+	feed, state, err := fetcher.Fetch(context.Background(), storage.Feed{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error on first fetch: %v", err)
+	}
+	if len(feed.Items) != 1 {
+		t.Errorf("want 1 item, got %d items", len(feed.Items))
+	}
+	if err := fetcher.Commit(srv.URL, state); err != nil {
+		t.Fatalf("unexpected error on commit: %v", err)
+	}
+
+	feed, _, err = fetcher.Fetch(context.Background(), storage.Feed{URL: srv.URL})
+	if !errors.Is(err, ErrNotModified) {
+		t.Errorf("want error %v on second fetch, got %v", ErrNotModified, err)
+	}
+	if feed != nil {
+		t.Errorf("want nil feed on second fetch, got %+v", feed)
+	}
+	if served != 1 {
+		t.Errorf("want feed served in full once, got %d times", served)
+	}
+}
+
+func TestFetcher_FetchUncommitted(t *testing.T) {
+	var served int
+	srv := newConditionalServer(t, &served)
+
+	fetcher := NewFetcher(nil, nil)
+
+	// Until the state of a copy is committed, the posts of the copy may not be
+	// saved yet, so the feed is requested in full again.
+	for range 2 {
+		if _, _, err := fetcher.Fetch(context.Background(), storage.Feed{URL: srv.URL}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if served != 2 {
+		t.Errorf("want feed served in full twice, got %d times", served)
+	}
+	if got := fetcher.state.Get(srv.URL); got != (FeedState{}) {
+		t.Errorf("want no validators stored before commit, got %+v", got)
+	}
+}
+
+func TestFetcher_FetchHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", testETag)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	fetcher := NewFetcher(nil, nil)
+	_, _, err := fetcher.Fetch(context.Background(), storage.Feed{URL: srv.URL})
+	if err == nil {
+		t.Fatal("want error, got nil")
+	}
+
+	if got := fetcher.state.Get(srv.URL); got != (FeedState{}) {
+		t.Errorf("want no validators stored for failed fetch, got %+v", got)
+	}
+}
+
+func TestStateStore_Persistence(t *testing.T) {
+	var served int
+	srv := newConditionalServer(t, &served)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading missing state file: %v", err)
+	}
+	fetcher := NewFetcher(nil, state)
+	_, fetched, err := fetcher.Fetch(context.Background(), storage.Feed{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error on first fetch: %v", err)
+	}
+	if err := fetcher.Commit(srv.URL, fetched); err != nil {
+		t.Fatalf("unexpected error on commit: %v", err)
+	}
+
+	// Simulate a restart.
+	state, err = LoadState(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading state file: %v", err)
+	}
+	want := FeedState{ETag: testETag, LastModified: testLastModified}
+	if got := state.Get(srv.URL); got != want {
+		t.Errorf("want state %+v, got %+v", want, got)
+	}
+
+	_, _, err = NewFetcher(nil, state).Fetch(context.Background(), storage.Feed{URL: srv.URL})
+	if !errors.Is(err, ErrNotModified) {
+		t.Errorf("want error %v after restart, got %v", ErrNotModified, err)
+	}
+}
@@ -0,0 +1,164 @@
+package rss
+
+import (
+	"errors"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"news/pkg/storage"
+)
+
+// defaultMaxBackoff is the cap of the retry delay in minutes used when max_backoff is not set.
+const defaultMaxBackoff = 60
+
+// FeedStatus describes the health of a polled feed.
+type FeedStatus struct {
+	URL                 string         `json:"url"`
+	Name                string         `json:"name"`
+	LastSuccess         time.Time      `json:"last_success"`
+	LastError           string         `json:"last_error,omitempty"`
+	LastErrorAt         time.Time      `json:"last_error_at"`
+	ConsecutiveFailures int            `json:"consecutive_failures"`
+	ItemsFetched        int            `json:"items_fetched"`      // Total items fetched since start.
+	Rejected            map[string]int `json:"rejected,omitempty"` // Total items rejected by the feed rules since start, by reason.
+	Latency             float64        `json:"latency_sec"`        // Duration of the last fetch.
+	NextFetch           time.Time      `json:"next_fetch"`
+}
+
+// healthTracker collects the status of every polled feed.
+type healthTracker struct {
+	mu       sync.Mutex
+	statuses map[string]*FeedStatus
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		statuses: make(map[string]*FeedStatus),
+	}
+}
+
+// record updates the status of the feed with the result of a fetch.
+// A feed that has not been modified counts as a successful fetch.
+func (h *healthTracker) record(feed storage.Feed, items int, latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st := h.status(feed)
+	st.Latency = latency.Seconds()
+
+	if err != nil && !errors.Is(err, ErrNotModified) {
+		st.LastError = err.Error()
+		st.LastErrorAt = time.Now().UTC()
+		st.ConsecutiveFailures++
+		return
+	}
+
+	st.LastSuccess = time.Now().UTC()
+	st.ConsecutiveFailures = 0
+	st.ItemsFetched += items
+}
+
+// rejected adds the numbers of items rejected by the feed rules to the status of the feed.
+func (h *healthTracker) rejected(feed storage.Feed, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st := h.status(feed)
+	if st.Rejected == nil {
+		st.Rejected = make(map[string]int)
+	}
+	for reason, n := range counts {
+		st.Rejected[reason] += n
+	}
+}
+
+// scheduled stores the time of the next fetch of the feed.
+func (h *healthTracker) scheduled(feed storage.Feed, next time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.status(feed).NextFetch = next.UTC()
+}
+
+// failures returns the number of consecutive failed fetches of the feed.
+func (h *healthTracker) failures(url string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if st, ok := h.statuses[url]; ok {
+		return st.ConsecutiveFailures
+	}
+	return 0
+}
+
+// retain drops the statuses of the feeds that are not in the given list.
+func (h *healthTracker) retain(feeds []storage.Feed) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keep := make(map[string]bool, len(feeds))
+	for _, f := range feeds {
+		keep[f.URL] = true
+	}
+	for url := range h.statuses {
+		if !keep[url] {
+			delete(h.statuses, url)
+		}
+	}
+}
+
+// all returns a copy of every tracked status sorted by feed URL.
+func (h *healthTracker) all() []FeedStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	statuses := make([]FeedStatus, 0, len(h.statuses))
+	for _, st := range h.statuses {
+		c := *st
+		c.Rejected = maps.Clone(st.Rejected)
+		statuses = append(statuses, c)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].URL < statuses[j].URL
+	})
+
+	return statuses
+}
+
+// status returns the status of the feed, creating it if needed. Must be called with h.mu held.
+func (h *healthTracker) status(feed storage.Feed) *FeedStatus {
+	st, ok := h.statuses[feed.URL]
+	if !ok {
+		st = &FeedStatus{URL: feed.URL}
+		h.statuses[feed.URL] = st
+	}
+	st.Name = feed.Name
+
+	return st
+}
+
+// backoff returns the delay before the next fetch of a feed that has failed
+// the given number of times in a row: the interval doubles with every failure
+// but never exceeds max. A max shorter than the interval is ignored.
+func backoff(interval, max time.Duration, failures int) time.Duration {
+	if max < interval {
+		max = interval
+	}
+
+	d := interval
+	for i := 0; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	return d
+}
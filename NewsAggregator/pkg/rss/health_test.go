@@ -0,0 +1,88 @@
+package rss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news/pkg/storage"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		max      time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{name: "no failures", interval: 5 * time.Minute, max: time.Hour, failures: 0, want: 5 * time.Minute},
+		{name: "one failure", interval: 5 * time.Minute, max: time.Hour, failures: 1, want: 10 * time.Minute},
+		{name: "three failures", interval: 5 * time.Minute, max: time.Hour, failures: 3, want: 40 * time.Minute},
+		{name: "capped", interval: 5 * time.Minute, max: time.Hour, failures: 4, want: time.Hour},
+		{name: "many failures", interval: 5 * time.Minute, max: time.Hour, failures: 100, want: time.Hour},
+		{name: "cap below interval", interval: 2 * time.Hour, max: time.Hour, failures: 3, want: 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoff(tt.interval, tt.max, tt.failures); got != tt.want {
+				t.Errorf("backoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_Status(t *testing.T) {
+	failing := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(testFeedXML))
+	}))
+	defer srv.Close()
+
+	feed := storage.Feed{URL: srv.URL, Name: "Test", Enabled: true}
+	parser := NewParser(config{RSS: []storage.Feed{feed}, HostRate: 1000}, nil)
+
+	for i := 0; i < 2; i++ {
+		if msg := parser.parse(context.Background(), feed); msg.Err == nil {
+			t.Fatal("want error from failing feed, got nil")
+		}
+	}
+
+	statuses := parser.Status()
+	if len(statuses) != 1 {
+		t.Fatalf("want 1 feed status, got %d", len(statuses))
+	}
+	st := statuses[0]
+	if st.ConsecutiveFailures != 2 {
+		t.Errorf("want 2 consecutive failures, got %d", st.ConsecutiveFailures)
+	}
+	if st.LastError == "" || st.LastErrorAt.IsZero() {
+		t.Errorf("want last error recorded, got %+v", st)
+	}
+	if !st.LastSuccess.IsZero() {
+		t.Errorf("want no last success, got %v", st.LastSuccess)
+	}
+
+	failing = false
+	if msg := parser.parse(context.Background(), feed); msg.Err != nil {
+		t.Fatalf("unexpected error from recovered feed: %v", msg.Err)
+	}
+
+	st = parser.Status()[0]
+	if st.ConsecutiveFailures != 0 {
+		t.Errorf("want failures reset after success, got %d", st.ConsecutiveFailures)
+	}
+	if st.LastSuccess.IsZero() {
+		t.Error("want last success recorded")
+	}
+	if st.ItemsFetched != 1 {
+		t.Errorf("want 1 item fetched, got %d", st.ItemsFetched)
+	}
+}
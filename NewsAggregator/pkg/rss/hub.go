@@ -0,0 +1,111 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hubLinks returns the address of the WebSub hub the feed announces and the
+// address the hub knows the feed by, its topic. They are taken from the Link
+// headers of the response or else from the <link rel="hub"> and
+// <link rel="self"> elements of the feed, which precede its items. Relative
+// addresses are resolved against base, the URL the feed was downloaded from,
+// which is also the topic of a feed without a self link. The hub is empty if
+// the feed doesn't announce one.
+func hubLinks(body []byte, header http.Header, base *url.URL) (hub, topic string) {
+	hub, topic = headerLinks(header)
+	if hub == "" {
+		hub, topic = feedLinks(body)
+	}
+	if hub == "" {
+		return "", ""
+	}
+
+	resolve := func(ref string) string {
+		u, err := base.Parse(ref)
+		if err != nil {
+			return ""
+		}
+		return u.String()
+	}
+	hub = resolve(hub)
+	if topic == "" {
+		return hub, base.String()
+	}
+	return hub, resolve(topic)
+}
+
+// headerLinks returns the hub and self links of the Link headers, as in
+// Link: <https://hub.example.com/>; rel="hub".
+func headerLinks(header http.Header) (hub, self string) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			ref, params, _ := strings.Cut(link, ";")
+			ref = strings.Trim(strings.TrimSpace(ref), "<>")
+			for _, param := range strings.Split(params, ";") {
+				key, rel, _ := strings.Cut(param, "=")
+				if strings.TrimSpace(strings.ToLower(key)) != "rel" {
+					continue
+				}
+				for _, r := range strings.Fields(strings.ToLower(strings.Trim(strings.TrimSpace(rel), `"`))) {
+					switch {
+					case r == "hub" && hub == "":
+						hub = ref
+					case r == "self" && self == "":
+						self = ref
+					}
+				}
+			}
+		}
+	}
+	return hub, self
+}
+
+// feedLinks returns the hub and self links of the feed document. Only the
+// elements before the first item are read.
+func feedLinks(body []byte) (hub, self string) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+	// The links are ASCII, so the document is read as is whatever its encoding.
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return hub, self
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch el.Name.Local {
+		case "item", "entry":
+			return hub, self
+		case "link":
+			var rel, href string
+			for _, a := range el.Attr {
+				switch a.Name.Local {
+				case "rel":
+					rel = strings.ToLower(a.Value)
+				case "href":
+					href = strings.TrimSpace(a.Value)
+				}
+			}
+			if href == "" {
+				continue
+			}
+			for _, r := range strings.Fields(rel) {
+				switch {
+				case r == "hub" && hub == "":
+					hub = href
+				case r == "self" && self == "":
+					self = href
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package rss
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHubLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/feed")
+
+	tests := []struct {
+		name      string
+		body      string
+		header    http.Header
+		wantHub   string
+		wantTopic string
+	}{
+		{
+			name: "rss with atom links",
+			body: `<?xml version="1.0" encoding="windows-1251"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+	<title>Test</title>
+	<link>https://example.com/</link>
+	<atom:link rel="hub" href="https://hub.example.com/"/>
+	<atom:link rel="self" type="application/rss+xml" href="https://example.com/feed.xml"/>
+	<item><title>Post</title></item>
+</channel>
+</rss>`,
+			wantHub:   "https://hub.example.com/",
+			wantTopic: "https://example.com/feed.xml",
+		},
+		{
+			name: "atom without self link",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+	<link rel="alternate" href="https://example.com/"/>
+	<link rel="hub" href="/hub"/>
+</feed>`,
+			wantHub:   "https://example.com/hub",
+			wantTopic: "https://example.com/feed",
+		},
+		{
+			name: "link headers",
+			body: testFeedXML,
+			header: http.Header{"Link": {
+				`<https://hub.example.com/>; rel="hub", <https://example.com/feed.xml>; rel="self"`,
+			}},
+			wantHub:   "https://hub.example.com/",
+			wantTopic: "https://example.com/feed.xml",
+		},
+		{
+			name: "hub link of an item",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+	<entry><link rel="hub" href="https://hub.example.com/"/></entry>
+</feed>`,
+		},
+		{
+			name: "no hub",
+			body: testFeedXML,
+		},
+	}
+	for _, tt := range tests {
+		hub, topic := hubLinks([]byte(tt.body), tt.header, base)
+		if hub != tt.wantHub || topic != tt.wantTopic {
+			t.Errorf("%s: want hub %q and topic %q, got %q and %q", tt.name, tt.wantHub, tt.wantTopic, hub, topic)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package rss
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+
+	"news/pkg/lang"
+	"news/pkg/sanitize"
+	"news/pkg/storage"
+)
+
+// maxSummaryLength is the maximum number of characters in a post summary.
+const maxSummaryLength = 300
+
+// sanitizePosts reduces the content of the posts to safe HTML and fills in
+// their plain-text summaries. Relative links, including the addresses of the
+// lead image and the enclosures, are resolved against the post link or, if the
+// post has none, against feedLink. Media files that are not served over http
+// or https are dropped.
+func sanitizePosts(posts []storage.Post, feedLink string) {
+	for i, post := range posts {
+		base := post.Link
+		if base == "" {
+			base = feedLink
+		}
+		posts[i].Content = sanitize.HTML(post.Content, base)
+		posts[i].Summary = sanitize.Summary(posts[i].Content, maxSummaryLength)
+
+		var enclosures []storage.Enclosure
+		for _, e := range post.Enclosures {
+			if e.URL = sanitize.URL(e.URL, base); e.URL != "" {
+				enclosures = append(enclosures, e)
+			}
+		}
+		posts[i].Enclosures = enclosures
+		posts[i].ImageURL = sanitize.URL(post.ImageURL, base)
+		if posts[i].ImageURL == "" {
+			posts[i].ImageURL = imageEnclosure(enclosures)
+		}
+	}
+}
+
+// itemLanguage returns the language the item declares with dc:language or,
+// failing that, the language of the feed. It is empty if neither declares one.
+func itemLanguage(item *gofeed.Item, feed *gofeed.Feed) string {
+	if item.DublinCoreExt != nil {
+		for _, l := range item.DublinCoreExt.Language {
+			if code := lang.Normalize(l); code != "" {
+				return code
+			}
+		}
+	}
+	return lang.Normalize(feed.Language)
+}
+
+// detectLanguages sets the language of the posts that don't declare one to
+// the language detected from their title and text content.
+func detectLanguages(posts []storage.Post) {
+	for i, post := range posts {
+		if post.Lang == "" {
+			posts[i].Lang = lang.Detect(post.Title + "\n" + sanitize.Text(post.Content))
+		}
+	}
+}
+
+// itemAuthors returns the author names of the item. Authors without a name
+// are listed by email.
+func itemAuthors(item *gofeed.Item) []string {
+	people := item.Authors
+	if len(people) == 0 && item.Author != nil {
+		people = []*gofeed.Person{item.Author}
+	}
+
+	var authors []string
+	for _, p := range people {
+		if p == nil {
+			continue
+		}
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			name = strings.TrimSpace(p.Email)
+		}
+		if name != "" && !slices.Contains(authors, name) {
+			authors = append(authors, name)
+		}
+	}
+
+	return authors
+}
+
+// itemCategories returns the non-empty category tags of the item.
+func itemCategories(item *gofeed.Item) []string {
+	var categories []string
+	for _, c := range item.Categories {
+		c = strings.TrimSpace(c)
+		if c != "" && !slices.Contains(categories, c) {
+			categories = append(categories, c)
+		}
+	}
+
+	return categories
+}
+
+// itemEnclosures returns the media files attached to the item. A length that
+// is not a number is left unset.
+func itemEnclosures(item *gofeed.Item) []storage.Enclosure {
+	var enclosures []storage.Enclosure
+	for _, e := range item.Enclosures {
+		if e == nil || e.URL == "" {
+			continue
+		}
+		length, _ := strconv.ParseInt(strings.TrimSpace(e.Length), 10, 64)
+		enclosures = append(enclosures, storage.Enclosure{
+			URL:    e.URL,
+			Type:   e.Type,
+			Length: max(length, 0),
+		})
+	}
+
+	return enclosures
+}
+
+// itemImage returns the URL of the lead image of the item: its own image or,
+// failing that, the first image enclosure.
+func itemImage(item *gofeed.Item, enclosures []storage.Enclosure) string {
+	if item.Image != nil && item.Image.URL != "" {
+		return item.Image.URL
+	}
+	return imageEnclosure(enclosures)
+}
+
+// imageEnclosure returns the URL of the first image enclosure, if any.
+func imageEnclosure(enclosures []storage.Enclosure) string {
+	for _, e := range enclosures {
+		if strings.HasPrefix(e.Type, "image/") {
+			return e.URL
+		}
+	}
+
+	return ""
+}
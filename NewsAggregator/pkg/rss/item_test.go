@@ -0,0 +1,177 @@
+package rss
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+
+	"news/pkg/storage"
+)
+
+const testPodcastXML = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>Test Podcast</title>
+	<item>
+		<title>Episode 1</title>
+		<link>https://example.com/ep1</link>
+		<dc:creator>Jane Doe</dc:creator>
+		<category>golang</category>
+		<category> concurrency </category>
+		<category>golang</category>
+		<enclosure url="https://example.com/ep1.jpg" type="image/jpeg" length="2048"/>
+		<enclosure url="https://example.com/ep1.mp3" type="audio/mpeg" length="unknown"/>
+		<content:encoded xmlns:content="http://purl.org/rss/1.0/modules/content/"><![CDATA[<p>Full episode notes</p>]]></content:encoded>
+	</item>
+</channel>
+</rss>`
+
+func TestHandleFeed_ItemMetadata(t *testing.T) {
+	feed, err := gofeed.NewParser().ParseString(testPodcastXML)
+	if err != nil {
+		t.Fatalf("unexpected error while parsing feed: %v", err)
+	}
+
+	posts, err := (&Parser{}).handleFeed(feed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("want 1 post, got %d", len(posts))
+	}
+	post := posts[0]
+
+	if want := []string{"Jane Doe"}; !reflect.DeepEqual(post.Authors, want) {
+		t.Errorf("want authors %v, got %v", want, post.Authors)
+	}
+	if want := []string{"golang", "concurrency"}; !reflect.DeepEqual(post.Categories, want) {
+		t.Errorf("want categories %v, got %v", want, post.Categories)
+	}
+	wantEnclosures := []storage.Enclosure{
+		{URL: "https://example.com/ep1.jpg", Type: "image/jpeg", Length: 2048},
+		{URL: "https://example.com/ep1.mp3", Type: "audio/mpeg"},
+	}
+	if !reflect.DeepEqual(post.Enclosures, wantEnclosures) {
+		t.Errorf("want enclosures %+v, got %+v", wantEnclosures, post.Enclosures)
+	}
+	if want := "https://example.com/ep1.jpg"; post.ImageURL != want {
+		t.Errorf("want image URL %s, got %s", want, post.ImageURL)
+	}
+	if want := "<p>Full episode notes</p>"; post.Content != want {
+		t.Errorf("want content %q for item without description, got %q", want, post.Content)
+	}
+}
+
+func TestItemImage(t *testing.T) {
+	item := &gofeed.Item{Image: &gofeed.Image{URL: "https://example.com/lead.png"}}
+	enclosures := []storage.Enclosure{{URL: "https://example.com/other.png", Type: "image/png"}}
+
+	if got := itemImage(item, enclosures); got != item.Image.URL {
+		t.Errorf("want item image %s, got %s", item.Image.URL, got)
+	}
+	if got := itemImage(&gofeed.Item{}, nil); got != "" {
+		t.Errorf("want no image, got %s", got)
+	}
+}
+
+func TestSanitizePosts(t *testing.T) {
+	posts := []storage.Post{
+		{
+			Content: `<p onclick="steal()">Read <a href="/more">more</a></p><script>alert(1)</script>`,
+			Link:    "https://example.com/blog/1",
+		},
+		{
+			Content:    `<img src="cover.png" alt="">`,
+			ImageURL:   "javascript:alert(1)",
+			Enclosures: []storage.Enclosure{{URL: "data:image/png;base64,AAAA", Type: "image/png"}, {URL: "cover.png", Type: "image/png"}},
+		},
+		{Link: "https://example.com/blog/2", ImageURL: "/img/lead.png", Enclosures: []storage.Enclosure{{URL: "episode.mp3", Type: "audio/mpeg"}}},
+	}
+
+	sanitizePosts(posts, "https://example.org/feed/")
+
+	want := `<p>Read <a href="https://example.com/more" rel="nofollow noopener noreferrer">more</a></p>`
+	if posts[0].Content != want {
+		t.Errorf("want content\n%s\ngot\n%s", want, posts[0].Content)
+	}
+	if posts[0].Summary != "Read more" {
+		t.Errorf("want summary %q, got %q", "Read more", posts[0].Summary)
+	}
+
+	want = `<img src="https://example.org/feed/cover.png" alt="">`
+	if posts[1].Content != want {
+		t.Errorf("want links of post without link resolved against the feed, got %s", posts[1].Content)
+	}
+
+	// Media files that are not served over http or https are dropped.
+	wantEnclosures := []storage.Enclosure{{URL: "https://example.org/feed/cover.png", Type: "image/png"}}
+	if !reflect.DeepEqual(posts[1].Enclosures, wantEnclosures) {
+		t.Errorf("want enclosures %+v, got %+v", wantEnclosures, posts[1].Enclosures)
+	}
+	if posts[1].ImageURL != "https://example.org/feed/cover.png" {
+		t.Errorf("want image enclosure as lead image instead of unsafe one, got %q", posts[1].ImageURL)
+	}
+
+	if posts[2].ImageURL != "https://example.com/img/lead.png" {
+		t.Errorf("want image resolved against the post link, got %q", posts[2].ImageURL)
+	}
+	if len(posts[2].Enclosures) != 1 || posts[2].Enclosures[0].URL != "https://example.com/blog/episode.mp3" {
+		t.Errorf("want enclosure resolved against the post link, got %+v", posts[2].Enclosures)
+	}
+}
+
+const testMixedLanguageXML = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>Test Feed</title>
+	<language>ru-RU</language>
+	<item>
+		<title>Go 1.23 is released</title>
+		<link>https://example.com/1</link>
+		<dc:language>en-us</dc:language>
+	</item>
+	<item>
+		<title>Вышел Go 1.23</title>
+		<link>https://example.com/2</link>
+	</item>
+</channel>
+</rss>`
+
+func TestHandleFeed_Language(t *testing.T) {
+	feed, err := gofeed.NewParser().ParseString(testMixedLanguageXML)
+	if err != nil {
+		t.Fatalf("unexpected error while parsing feed: %v", err)
+	}
+
+	posts, err := (&Parser{}).handleFeed(feed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("want 2 posts, got %d", len(posts))
+	}
+	if posts[0].Lang != "en" {
+		t.Errorf("want language declared by the item %q, got %q", "en", posts[0].Lang)
+	}
+	if posts[1].Lang != "ru" {
+		t.Errorf("want language declared by the feed %q, got %q", "ru", posts[1].Lang)
+	}
+}
+
+func TestDetectLanguages(t *testing.T) {
+	posts := []storage.Post{
+		{Title: "Вышел Go 1.23", Content: "<p>В языке появились итераторы по функциям и новый пакет для работы с ними.</p>"},
+		{Title: "Go 1.23 is released", Content: "<p>The language now supports range over function iterators.</p>"},
+		{Title: "Go 1.23", Content: "<p>Go.</p>"},
+		{Title: "Go 1.23 is released", Lang: "de"},
+	}
+
+	detectLanguages(posts)
+
+	for i, want := range []string{"ru", "en", "", "de"} {
+		if posts[i].Lang != want {
+			t.Errorf("want post #%d language %q, got %q", i, want, posts[i].Lang)
+		}
+	}
+}
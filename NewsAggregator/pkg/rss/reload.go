@@ -0,0 +1,175 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"news/pkg/storage"
+)
+
+// defaultWatchInterval is how often the config file is checked for changes
+// when the watcher has no interval of its own.
+const defaultWatchInterval = 5 * time.Second
+
+// ConfigWatcher reloads the parser config when the file changes or on request.
+// The feed list of the config only seeds the storage, the stored feeds are
+// managed through the API: feeds added to the config since it was last loaded
+// are added to the storage unless a feed with the same URL is stored already,
+// while feeds changed in or removed from the config are left as they are
+// stored. The parser then gets the stored feeds, so added feeds are fetched
+// right away. Other parser settings take effect on restart.
+type ConfigWatcher struct {
+	Path     string
+	DB       storage.Storage
+	Parser   *Parser
+	Interval time.Duration // How often the file is checked for changes.
+
+	mu    sync.Mutex
+	feeds []storage.Feed // Feeds of the last loaded config.
+	stat  os.FileInfo    // File info of the last loaded config, nil if unknown.
+}
+
+// NewConfigWatcher returns a watcher of the config file at path, whose feeds
+// as of now are the given ones.
+func NewConfigWatcher(path string, feeds []storage.Feed, db storage.Storage, parser *Parser) *ConfigWatcher {
+	w := ConfigWatcher{
+		Path:     path,
+		DB:       db,
+		Parser:   parser,
+		Interval: defaultWatchInterval,
+		feeds:    feeds,
+	}
+	w.stat, _ = os.Stat(path)
+
+	return &w
+}
+
+// Run checks the config file for changes once per Interval and reloads it when
+// it changes or a value is received from reload, until ctx is cancelled.
+// Errors are logged and the previous config stays in effect.
+func (w *ConfigWatcher) Run(ctx context.Context, reload <-chan os.Signal) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+			log.Infof("[rss] config %s changed, reloading", w.Path)
+		case <-reload:
+			log.Infof("[rss] reloading config %s", w.Path)
+		case <-ctx.Done():
+			return
+		}
+
+		if err := w.Reload(ctx); err != nil {
+			log.Errorf("[rss] unable to reload config %s: %v", w.Path, err)
+		}
+	}
+}
+
+// changed reports whether the modification time or the size of the file
+// differ from those of the last loaded config.
+func (w *ConfigWatcher) changed() bool {
+	stat, err := os.Stat(w.Path)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.stat == nil || !stat.ModTime().Equal(w.stat.ModTime()) || stat.Size() != w.stat.Size()
+}
+
+// Reload loads the config file and adds the feeds added to it to the storage.
+// An invalid config is not applied. If some of the feeds fail to be added,
+// the rest are added and the config is loaded again on the next Reload.
+func (w *ConfigWatcher) Reload(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stat, _ := os.Stat(w.Path)
+	conf, err := LoadConf(w.Path)
+	if err != nil {
+		// The file is not read again until it changes.
+		w.stat = stat
+		return err
+	}
+
+	stored, err := w.DB.Feeds(ctx)
+	if err != nil {
+		w.stat = nil
+		return fmt.Errorf("unable to retrieve stored feeds: %w", err)
+	}
+	storedURLs := make(map[string]bool, len(stored))
+	for _, f := range stored {
+		storedURLs[f.URL] = true
+	}
+
+	var added int
+	var errs []error
+	for _, f := range newFeeds(w.feeds, conf.RSS) {
+		if storedURLs[f.URL] {
+			continue
+		}
+		_, err := w.DB.AddFeed(ctx, f)
+		switch {
+		case errors.Is(err, storage.ErrFeedExists):
+		case err != nil:
+			errs = append(errs, fmt.Errorf("unable to add feed %s: %w", f.URL, err))
+		default:
+			added++
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		w.stat = nil
+		w.sync(ctx)
+		return err
+	}
+
+	w.feeds, w.stat = conf.RSS, stat
+	w.sync(ctx)
+	log.Infof("[rss] config %s reloaded: %d feeds added", w.Path, added)
+
+	return nil
+}
+
+// sync passes the stored feeds to the parser.
+func (w *ConfigWatcher) sync(ctx context.Context) {
+	if w.Parser == nil {
+		return
+	}
+
+	feeds, err := w.DB.Feeds(ctx)
+	if err != nil {
+		log.Errorf("[rss] unable to reload feeds for the parser: %v", err)
+		return
+	}
+	w.Parser.SetFeeds(feeds)
+}
+
+// newFeeds returns the feeds of next whose URLs are not in prev.
+func newFeeds(prev, next []storage.Feed) []storage.Feed {
+	old := make(map[string]bool, len(prev))
+	for _, f := range prev {
+		old[f.URL] = true
+	}
+
+	var added []storage.Feed
+	for _, f := range next {
+		if !old[f.URL] {
+			added = append(added, f)
+		}
+	}
+
+	return added
+}
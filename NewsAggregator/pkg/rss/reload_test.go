@@ -0,0 +1,154 @@
+package rss
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"news/pkg/storage"
+	"news/pkg/storage/memdb"
+)
+
+// writeFeedsConf writes a parser config with the feeds to the file at path.
+func writeFeedsConf(t *testing.T, path, feeds string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(`{"rss": [`+feeds+`]}`), 0o644); err != nil {
+		t.Fatalf("unable to write config: %v", err)
+	}
+}
+
+// storedURLs returns the sorted URLs of the stored feeds.
+func storedURLs(t *testing.T, db storage.Storage) []string {
+	t.Helper()
+
+	feeds, err := db.Feeds(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error while retrieving feeds: %v", err)
+	}
+	var urls []string
+	for _, f := range feeds {
+		urls = append(urls, f.URL)
+	}
+	slices.Sort(urls)
+
+	return urls
+}
+
+func TestConfigWatcher_Reload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFeedsConf(t, path, `"https://example.com/a", {"url": "https://example.com/b", "interval": 5}, "https://example.com/d"`)
+
+	conf, err := LoadConf(path)
+	if err != nil {
+		t.Fatalf("unexpected error while loading config: %v", err)
+	}
+	db := memdb.New()
+	stored := slices.Clone(conf.RSS)
+	for i, f := range stored {
+		if stored[i].ID, err = db.AddFeed(ctx, f); err != nil {
+			t.Fatalf("unexpected error while adding feed: %v", err)
+		}
+	}
+	// Feeds managed through the API: one added, a seed feed changed and another one deleted.
+	if _, err := db.AddFeed(ctx, storage.Feed{URL: "https://example.com/api", Enabled: true}); err != nil {
+		t.Fatalf("unexpected error while adding feed: %v", err)
+	}
+	b := stored[1]
+	b.Interval = 20
+	if err := db.UpdateFeed(ctx, b); err != nil {
+		t.Fatalf("unexpected error while updating feed: %v", err)
+	}
+	if err := db.DeleteFeed(ctx, stored[2].ID); err != nil {
+		t.Fatalf("unexpected error while deleting feed: %v", err)
+	}
+	parser := NewParser(*conf, nil)
+
+	// The config drops a, changes b, keeps d and adds c.
+	w := NewConfigWatcher(path, conf.RSS, db, parser)
+	writeFeedsConf(t, path, `{"url": "https://example.com/b", "interval": 10}, "https://example.com/c", "https://example.com/d"`)
+	if err := w.Reload(ctx); err != nil {
+		t.Fatalf("unexpected error while reloading: %v", err)
+	}
+
+	want := []string{"https://example.com/a", "https://example.com/api", "https://example.com/b", "https://example.com/c"}
+	if got := storedURLs(t, db); !slices.Equal(got, want) {
+		t.Errorf("want stored feeds %v, got %v", want, got)
+	}
+	feeds := parser.Feeds()
+	if len(feeds) != len(want) {
+		t.Fatalf("want parser feeds %v, got %+v", want, feeds)
+	}
+	for _, f := range feeds {
+		if f.URL == "https://example.com/b" && f.Interval != 20 {
+			t.Errorf("want interval of the stored feed kept, got %+v", f)
+		}
+	}
+
+	// An invalid config is not applied.
+	writeFeedsConf(t, path, `{"url": "not a url"}`)
+	if err := w.Reload(ctx); err == nil {
+		t.Error("want error for invalid config, got nil")
+	}
+	if got := storedURLs(t, db); !slices.Equal(got, want) {
+		t.Errorf("want stored feeds %v kept, got %v", want, got)
+	}
+}
+
+func TestConfigWatcher_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFeedsConf(t, path, `"https://example.com/a"`)
+
+	db := memdb.New()
+	w := NewConfigWatcher(path, nil, db, nil)
+	w.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reload := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, reload)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor := func(want []string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !slices.Equal(storedURLs(t, db), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("want stored feeds %v, got %v", want, storedURLs(t, db))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// The config file is unchanged, the feeds are loaded on SIGHUP.
+	reload <- os.Interrupt
+	waitFor([]string{"https://example.com/a"})
+
+	// A rewrite of the file is picked up without a signal. The size changes,
+	// so the change is seen even if the modification time stays the same.
+	writeFeedsConf(t, path, `"https://example.com/a", "https://example.com/bb"`)
+	waitFor([]string{"https://example.com/a", "https://example.com/bb"})
+}
+
+func TestNewFeeds(t *testing.T) {
+	a := storage.Feed{URL: "https://example.com/a", Enabled: true}
+	b := storage.Feed{URL: "https://example.com/b", Enabled: true}
+	c := storage.Feed{URL: "https://example.com/c", Enabled: true}
+	b2 := b
+	b2.Enabled = false
+
+	added := newFeeds([]storage.Feed{a, b}, []storage.Feed{b2, c})
+	if len(added) != 1 || added[0].URL != c.URL {
+		t.Errorf("want only %s added, got %+v", c.URL, added)
+	}
+}
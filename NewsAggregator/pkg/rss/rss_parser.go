@@ -1,58 +1,98 @@
 package rss
 
 import (
-	"encoding/json"
-	"os"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/mmcdole/gofeed"
+	log "github.com/sirupsen/logrus"
 
+	"news/pkg/crawl"
+	"news/pkg/discover"
 	"news/pkg/storage"
 )
 
+// subscribedPollInterval is the polling interval of the feeds whose updates
+// are pushed by a WebSub hub, unless their own interval is longer.
+const subscribedPollInterval = time.Hour
+
+// ErrUnknownFeed is returned by Parser.Push for the feeds that are not polled.
+var ErrUnknownFeed = fmt.Errorf("unknown or disabled feed")
+
 type ParserMsg struct {
 	Source string
 	Data   []storage.Post
 	Err    error
-}
+	Hub    string // Address of the WebSub hub the feed announces, if any.
+	Topic  string // Address the hub knows the feed by.
 
-type config struct {
-	RSS           []string `json:"rss"`
-	RequestPeriod int      `json:"request_period"`
+	state *FeedState // State of the fetched copy of the feed, stored by Parser.Commit.
 }
 
-func LoadConf(path string) (*config, error) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	var conf config
-	err = json.Unmarshal(b, &conf)
-	if err != nil {
-		return nil, err
-	}
-
-	return &conf, nil
+// Subscriptions tells which feeds get their updates pushed by a WebSub hub,
+// see websub.Subscriber.
+type Subscriptions interface {
+	Subscribed(feedURL string) bool
 }
 
 type Parser struct {
-	Urls  []string
-	Delay time.Duration
+	Delay      time.Duration // Default polling interval.
+	MaxBackoff time.Duration // Cap of the polling interval of a failing feed.
+	Workers    int           // Number of feeds fetched at once.
+	// Subscriptions, if set, tells which feeds get their updates pushed. They
+	// are still polled, but only once per subscribedPollInterval, in case a push is lost.
+	Subscriptions Subscriptions
+
+	fetcher   *Fetcher
+	articles  *articleFetcher
+	discovery *http.Client // Client of the feed discovery, see DiscoveryClient.
+	health    *healthTracker
+	dates     *dateResolver
+
+	mu       sync.Mutex
+	feeds    []storage.Feed
+	rules    map[string]ruleSet      // Compiled rules of the feeds by URL.
+	schedule map[string]time.Time    // Next fetch time of idle enabled feeds by URL, nil unless the parser is running.
+	inFlight map[string]storage.Feed // Feeds being fetched by URL.
+	wake     chan struct{}           // Signals the scheduler that the schedule has changed.
 }
 
 // handleFeed processes a single RSS feed, converting its items to storage.Post
-// objects and returning them as a slice. Converts the post's Published field to UTC.
+// objects and returning them as a slice. The post's Published field is resolved
+// to UTC, see dateResolver.published. Items without a description fall back to
+// their full content.
 func (p *Parser) handleFeed(feed *gofeed.Feed) ([]storage.Post, error) {
 	var posts []storage.Post
 
+	dates := p.dates
+	if dates == nil {
+		dates = newDateResolver(nil)
+	}
+	now := time.Now()
+
 	for _, item := range feed.Items {
-		publishedUTC, _ := ConvertToUTC(item.Published)
+		publishedUTC := dates.published(item, now)
+		content := item.Description
+		if content == "" {
+			content = item.Content
+		}
+		enclosures := itemEnclosures(item)
 		post := storage.Post{
-			Title:     item.Title,
-			Content:   item.Description,
-			Published: publishedUTC,
-			Link:      item.Link,
+			Title:      item.Title,
+			Content:    content,
+			Published:  publishedUTC,
+			Link:       item.Link,
+			Authors:    itemAuthors(item),
+			Categories: itemCategories(item),
+			ImageURL:   itemImage(item, enclosures),
+			Enclosures: enclosures,
+			Lang:       itemLanguage(item, feed),
 		}
 		posts = append(posts, post)
 	}
@@ -60,39 +100,429 @@ func (p *Parser) handleFeed(feed *gofeed.Feed) ([]storage.Post, error) {
 	return posts, nil
 }
 
-// Run runs the parser for each URL in a separate goroutine.
-func (p *Parser) Run(msgChan chan<- ParserMsg) {
-	for _, url := range p.Urls {
-		go func(url string) {
-			msg := ParserMsg{Source: url}
-			fp := gofeed.NewParser()
+// Run fetches the enabled feeds until ctx is cancelled. Each feed is fetched
+// immediately and then once per its own interval by a pool of p.Workers
+// workers, so no more than p.Workers feeds are fetched at once. Feeds that have
+// not changed since the last committed fetch are reported with ErrNotModified,
+// see Commit.
+// Cancelling ctx aborts the fetches in progress; their results are not sent.
+// Run returns once all the workers have stopped, so out can be closed afterwards.
+func (p *Parser) Run(ctx context.Context, out chan<- ParserMsg) {
+	jobs := make(chan storage.Feed)
 
-			feed, err := fp.ParseURL(url)
-			if err != nil {
-				msg.Err = err
-				msgChan <- msg
-				return
+	var wg sync.WaitGroup
+	for range max(p.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feed := range jobs {
+				p.work(ctx, feed, out)
 			}
+		}()
+	}
 
-			posts, err := p.handleFeed(feed)
-			if err != nil {
-				msg.Err = err
-				msgChan <- msg
+	p.mu.Lock()
+	p.schedule = make(map[string]time.Time)
+	p.inFlight = make(map[string]storage.Feed)
+	p.syncSchedule()
+	p.mu.Unlock()
+
+	p.dispatch(ctx, jobs)
+
+	close(jobs)
+	wg.Wait()
+
+	p.mu.Lock()
+	p.schedule = nil
+	p.inFlight = nil
+	p.mu.Unlock()
+}
+
+// dispatch sends the feeds to the workers as they become due until ctx is cancelled.
+// A due feed waits for a free worker, so a slow feed delays the others but never
+// makes the parser exceed its worker limit.
+func (p *Parser) dispatch(ctx context.Context, jobs chan<- storage.Feed) {
+	for {
+		feed, wait, ok := p.next()
+		if ok && wait <= 0 {
+			select {
+			case jobs <- feed:
+				continue
+			case <-ctx.Done():
 				return
 			}
+		}
+		if !ok {
+			// Nothing is scheduled, wait for SetFeeds or a worker to finish.
+			wait = time.Hour
+		}
 
-			msg.Data = posts
-			msgChan <- msg
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-p.wake:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// next returns the feed with the earliest scheduled fetch and the time left until it.
+// A feed that is due is taken off the schedule and marked in flight.
+func (p *Parser) next() (storage.Feed, time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var url string
+	var at time.Time
+	for u, t := range p.schedule {
+		if url == "" || t.Before(at) {
+			url, at = u, t
+		}
+	}
+	if url == "" {
+		return storage.Feed{}, 0, false
+	}
 
-		}(url)
+	wait := time.Until(at)
+	if wait > 0 {
+		return storage.Feed{}, wait, true
 	}
+
+	feed, _ := p.feed(url)
+	delete(p.schedule, url)
+	p.inFlight[url] = feed
+
+	return feed, 0, true
 }
 
-func NewParser(conf config) *Parser {
+// work fetches the feed, schedules its next fetch and sends the result to out.
+func (p *Parser) work(ctx context.Context, feed storage.Feed, out chan<- ParserMsg) {
+	msg := p.parse(ctx, feed)
+	p.finished(feed.URL, msg.Err)
+
+	if ctx.Err() != nil {
+		return
+	}
+	select {
+	case out <- msg:
+	case <-ctx.Done():
+	}
+}
+
+// finished schedules the next fetch of a feed once its fetch is over.
+// The interval of a failing feed grows exponentially up to MaxBackoff, but
+// the feed is not fetched before the time its host asked to retry after.
+// Feeds with push subscriptions are fetched once per subscribedPollInterval.
+// Feeds removed or disabled during the fetch are not scheduled again and
+// feeds changed during the fetch are fetched again right away.
+func (p *Parser) finished(url string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.schedule == nil {
+		return
+	}
+	fetched := p.inFlight[url]
+	delete(p.inFlight, url)
+
+	feed, ok := p.feed(url)
+	if !ok || !feed.Enabled {
+		return
+	}
+	if !feed.Equal(fetched) {
+		p.scheduleAt(feed, time.Now())
+		return
+	}
+	at := time.Now().Add(backoff(pollInterval(feed, p.Delay), p.MaxBackoff, p.health.failures(url)))
+	var retry *crawl.RetryAfterError
+	if errors.As(err, &retry) && retry.Until.After(at) {
+		at = retry.Until
+	}
+	if p.Subscriptions != nil && p.Subscriptions.Subscribed(url) {
+		if pushed := time.Now().Add(subscribedPollInterval); pushed.After(at) {
+			at = pushed
+		}
+	}
+	p.scheduleAt(feed, at)
+}
+
+// DiscoveryClient returns the client to look for the feeds of the sites users
+// ask for with. It follows the crawling policy of the parser along with the
+// feed fetches, but only connects to public addresses, see discover.PublicTransport.
+func (p *Parser) DiscoveryClient() *http.Client {
+	return p.discovery
+}
+
+// Feeds returns the feeds known to the parser, including disabled ones.
+func (p *Parser) Feeds() []storage.Feed {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Clone(p.feeds)
+}
+
+// SetFeeds replaces the feeds known to the parser. If the parser is running,
+// removed and disabled feeds are no longer fetched and added or changed feeds
+// are fetched as soon as a worker is free. Unchanged feeds keep their schedule.
+func (p *Parser) SetFeeds(feeds []storage.Feed) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old := make(map[string]storage.Feed, len(p.feeds))
+	for _, f := range p.feeds {
+		old[f.URL] = f
+	}
+
+	p.feeds = slices.Clone(feeds)
+	p.health.retain(feeds)
+	p.compileRules()
+	if p.schedule == nil {
+		return
+	}
+
+	for _, f := range p.feeds {
+		if prev, ok := old[f.URL]; ok && !prev.Equal(f) {
+			delete(p.schedule, f.URL)
+		}
+	}
+	p.syncSchedule()
+}
+
+// compileRules compiles the rules of the feeds, but for those whose compiled
+// rules are up to date. Must be called with p.mu held.
+func (p *Parser) compileRules() {
+	rules := make(map[string]ruleSet, len(p.feeds))
+	for _, f := range p.feeds {
+		if rs, ok := p.rules[f.URL]; ok && rs.feed.Equal(f) {
+			rules[f.URL] = rs
+			continue
+		}
+		rules[f.URL] = compileRules(f)
+	}
+	p.rules = rules
+}
+
+// feedRules returns the compiled rules of the feed. The rules of a feed that
+// has been changed or removed since it was fetched are compiled anew.
+func (p *Parser) feedRules(feed storage.Feed) ruleSet {
+	p.mu.Lock()
+	rs, ok := p.rules[feed.URL]
+	p.mu.Unlock()
+	if ok && rs.feed.Equal(feed) {
+		return rs
+	}
+	return compileRules(feed)
+}
+
+// syncSchedule drops the feeds that are no longer enabled from the schedule
+// and schedules the enabled feeds that are neither scheduled nor in flight
+// for an immediate fetch. Must be called with p.mu held.
+func (p *Parser) syncSchedule() {
+	enabled := make(map[string]bool)
+	for _, f := range p.feeds {
+		if f.Enabled {
+			enabled[f.URL] = true
+		}
+	}
+
+	for url := range p.schedule {
+		if !enabled[url] {
+			delete(p.schedule, url)
+		}
+	}
+
+	for _, f := range p.feeds {
+		if !f.Enabled {
+			continue
+		}
+		_, scheduled := p.schedule[f.URL]
+		_, fetching := p.inFlight[f.URL]
+		if !scheduled && !fetching {
+			p.scheduleAt(f, time.Now())
+		}
+	}
+}
+
+// scheduleAt sets the time of the next fetch of the feed and wakes up the
+// scheduler. Must be called with p.mu held.
+func (p *Parser) scheduleAt(feed storage.Feed, at time.Time) {
+	p.schedule[feed.URL] = at
+	p.health.scheduled(feed, at)
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// feed returns the known feed with the given URL. Must be called with p.mu held.
+func (p *Parser) feed(url string) (storage.Feed, bool) {
+	i := slices.IndexFunc(p.feeds, func(f storage.Feed) bool { return f.URL == url })
+	if i < 0 {
+		return storage.Feed{}, false
+	}
+	return p.feeds[i], true
+}
+
+// Status returns the health of every feed polled so far.
+func (p *Parser) Status() []FeedStatus {
+	return p.health.all()
+}
+
+// parse fetches a single feed and converts its items to posts.
+// The result of the fetch is recorded in the feed status unless ctx is cancelled.
+func (p *Parser) parse(ctx context.Context, feed storage.Feed) (msg ParserMsg) {
+	msg = ParserMsg{Source: feed.URL}
+
+	start := time.Now()
+	defer func() {
+		if ctx.Err() == nil {
+			p.health.record(feed, len(msg.Data), time.Since(start), msg.Err)
+		}
+	}()
+
+	f, state, err := p.fetcher.Fetch(ctx, feed)
+	msg.Hub, msg.Topic = state.Hub, state.Topic
+	if err != nil {
+		msg.Err = err
+		return msg
+	}
+
+	msg.Data, msg.Err = p.process(ctx, feed, f)
+	if msg.Err == nil {
+		msg.state = &state
+	}
+	return msg
+}
+
+// Commit stores the cache validators of the copy of the feed the message was
+// made from. It must be called once the posts of the message have been saved:
+// until then the copy is fetched in full again rather than reported as not
+// modified. Messages of pushed copies and failed fetches carry no validators.
+func (p *Parser) Commit(msg ParserMsg) {
+	if msg.state == nil {
+		return
+	}
+	if err := p.fetcher.Commit(msg.Source, *msg.state); err != nil {
+		log.Warnf("[rss] unable to save state of feed %s: %v", msg.Source, err)
+	}
+}
+
+// Push converts the copy of the feed pushed by its WebSub hub to posts the
+// same way as a fetched copy. The feed must be known to the parser and enabled.
+func (p *Parser) Push(ctx context.Context, feedURL string, body []byte) ParserMsg {
+	msg := ParserMsg{Source: feedURL}
+
+	p.mu.Lock()
+	feed, ok := p.feed(feedURL)
+	p.mu.Unlock()
+	if !ok || !feed.Enabled || feed.Scraper != nil {
+		msg.Err = fmt.Errorf("%w: %s", ErrUnknownFeed, feedURL)
+		return msg
+	}
+
+	f, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		msg.Err = err
+		return msg
+	}
+
+	msg.Data, msg.Err = p.process(ctx, feed, f)
+	return msg
+}
+
+// process converts the items of a copy of the feed to posts, downloads their
+// full text if the feed asks for it, sanitizes them and filters them with the
+// feed rules. The posts rejected by the rules that don't depend on the content
+// are dropped before the download.
+func (p *Parser) process(ctx context.Context, feed storage.Feed, f *gofeed.Feed) ([]storage.Post, error) {
+	posts, err := p.handleFeed(f)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(posts)
+	rules := p.feedRules(feed)
+	posts, rejected := rules.prefilter(posts)
+	if feed.FullText {
+		p.articles.fill(ctx, feed, posts)
+	}
+	sanitizePosts(posts, f.Link)
+	detectLanguages(posts)
+
+	posts, rejectedLater := rules.filter(posts)
+	for reason, n := range rejectedLater {
+		rejected[reason] += n
+	}
+	if len(rejected) > 0 {
+		log.Infof("[rss] %d of %d items of %s rejected by the feed rules: %v", total-len(posts), total, feed.URL, rejected)
+		p.health.rejected(feed, rejected)
+	}
+
+	source := storage.Source{URL: feed.URL, Name: feed.Name}
+	if source.Name == "" {
+		source.Name = f.Title
+	}
+	for i := range posts {
+		posts[i].Source = source
+	}
+
+	return posts, nil
+}
+
+// NewParser creates a parser for the feeds from the config. The cache validators
+// of the feeds are kept in the given state store, see LoadState.
+func NewParser(conf config, state *StateStore) *Parser {
+	if conf.RequestPeriod <= 0 {
+		conf.RequestPeriod = defaultRequestPeriod
+	}
+	if conf.MaxBackoff <= 0 {
+		conf.MaxBackoff = defaultMaxBackoff
+	}
+
+	if conf.Workers <= 0 {
+		conf.Workers = defaultWorkers
+	}
+	if conf.FullTextConcurrency <= 0 {
+		conf.FullTextConcurrency = defaultFullTextConcurrency
+	}
+
+	transport := newTransport(conf.Workers+conf.FullTextConcurrency, crawl.Config{
+		UserAgent: conf.UserAgent,
+		HostRate:  conf.HostRate,
+		HostBurst: conf.HostBurst,
+	})
+	client := &http.Client{Transport: transport}
+
 	p := Parser{
-		Urls:  conf.RSS,
-		Delay: time.Minute * time.Duration(conf.RequestPeriod),
+		feeds:      slices.Clone(conf.RSS),
+		Delay:      time.Minute * time.Duration(conf.RequestPeriod),
+		MaxBackoff: time.Minute * time.Duration(conf.MaxBackoff),
+		Workers:    conf.Workers,
+		fetcher:    NewFetcher(client, state),
+		articles:   newArticleFetcher(client, conf.FullTextConcurrency),
+		discovery:  &http.Client{Transport: transport.WithBase(discover.PublicTransport())},
+		health:     newHealthTracker(),
+		dates:      newDateResolver(conf.Timezones),
+		wake:       make(chan struct{}, 1),
 	}
+	p.compileRules()
 
 	return &p
 }
+
+// newTransport returns the transport shared by the feed and article fetchers.
+// At most maxConns connections are requested at once, so no more than that
+// many idle connections are kept for reuse. The requests follow the crawling
+// policy, see crawl.Transport.
+func newTransport(maxConns int, policy crawl.Config) *crawl.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = maxConns
+	t.MaxIdleConnsPerHost = maxConns
+	t.IdleConnTimeout = 30 * time.Second
+
+	return crawl.NewTransport(t, policy)
+}
@@ -1,27 +1,38 @@
 package rss
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/mmcdole/gofeed"
+
+	"news/pkg/crawl"
+	"news/pkg/storage"
 )
 
 func TestParser_Run(t *testing.T) {
 	testConf := config{
-		RSS: []string{"https://3dnews.ru/news/rss/"},
+		RSS: []storage.Feed{{URL: "https://3dnews.ru/news/rss/", Enabled: true}},
 	}
 
 	msgChan := make(chan ParserMsg)
-	parser := NewParser(testConf)
-	parser.Run(msgChan)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parser := NewParser(testConf, nil)
+	go parser.Run(ctx, msgChan)
 
 	if msg, ok := <-msgChan; ok {
 		if msg.Err != nil {
 			t.Errorf("got unexpected error from parser")
 		}
-		if msg.Source != testConf.RSS[0] {
-			t.Errorf("want source %s, got source %s", testConf.RSS[0], msg.Source)
+		if msg.Source != testConf.RSS[0].URL {
+			t.Errorf("want source %s, got source %s", testConf.RSS[0].URL, msg.Source)
 		}
 		if len(msg.Data) == 0 {
 			t.Errorf("want posts > 0, got %d posts", len(msg.Data))
@@ -31,19 +42,21 @@ func TestParser_Run(t *testing.T) {
 
 func TestParser_RunBrokenSource(t *testing.T) {
 	testConf := config{
-		RSS: []string{"https://example.xyz/invalid/rss/"},
+		RSS: []storage.Feed{{URL: "https://example.xyz/invalid/rss/", Enabled: true}},
 	}
 
 	msgChan := make(chan ParserMsg)
-	parser := NewParser(testConf)
-	parser.Run(msgChan)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parser := NewParser(testConf, nil)
+	go parser.Run(ctx, msgChan)
 
 	if msg, ok := <-msgChan; ok {
 		if msg.Err == nil {
 			t.Errorf("expected error, got %v", msg.Err)
 		}
-		if msg.Source != testConf.RSS[0] {
-			t.Errorf("want source %s, got source %s", testConf.RSS[0], msg.Source)
+		if msg.Source != testConf.RSS[0].URL {
+			t.Errorf("want source %s, got source %s", testConf.RSS[0].URL, msg.Source)
 		}
 		if len(msg.Data) != 0 {
 			t.Errorf("expected empty data, got %d posts", len(msg.Data))
@@ -112,10 +125,312 @@ func TestHandleFeed(t *testing.T) {
 		}
 	})
 
+	// Test RFC3339 date handling
+	t.Run("RFC3339DateHandling", func(t *testing.T) {
+		want := time.Date(2024, time.May, 1, 15, 0, 0, 0, time.UTC)
+		if !posts[1].Published.Equal(want) {
+			t.Errorf("want post published %v, got post published %v", want, posts[1].Published)
+		}
+	})
+
 	// Test invalid date handling
 	t.Run("InvalidDateHandling", func(t *testing.T) {
-		if !posts[2].Published.IsZero() {
-			t.Error("Expected zero time for invalid date format")
+		if time.Since(posts[2].Published) > time.Minute {
+			t.Errorf("want first-seen time for invalid date format, got %v", posts[2].Published)
 		}
 	})
 }
+
+func TestParser_RunFeedSettings(t *testing.T) {
+	var gotUserAgent string
+	enabled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.Header.Get("User-Agent")
+		w.Write([]byte(testFeedXML))
+	}))
+	defer enabled.Close()
+
+	var disabledHits int
+	disabled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		disabledHits++
+		w.Write([]byte(testFeedXML))
+	}))
+	defer disabled.Close()
+
+	testConf := config{
+		RSS: []storage.Feed{
+			{URL: disabled.URL, Enabled: false},
+			{URL: enabled.URL, Enabled: true, UserAgent: "FeedFusion-Test"},
+		},
+	}
+
+	msgChan := make(chan ParserMsg)
+	ctx, cancel := context.WithCancel(context.Background())
+	parser := NewParser(testConf, nil)
+
+	stopped := make(chan struct{})
+	go func() {
+		parser.Run(ctx, msgChan)
+		close(stopped)
+	}()
+
+	msg := <-msgChan
+	if msg.Err != nil {
+		t.Fatalf("unexpected error from parser: %v", msg.Err)
+	}
+	if msg.Source != enabled.URL {
+		t.Errorf("want source %s, got source %s", enabled.URL, msg.Source)
+	}
+	wantSource := storage.Source{URL: enabled.URL, Name: "Test Feed"}
+	if len(msg.Data) == 0 || msg.Data[0].Source != wantSource {
+		t.Errorf("want posts with source %+v, got %+v", wantSource, msg.Data)
+	}
+	if gotUserAgent != "FeedFusion-Test" {
+		t.Errorf("want User-Agent %q, got %q", "FeedFusion-Test", gotUserAgent)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("parser did not stop after the context was cancelled")
+	}
+
+	if disabledHits != 0 {
+		t.Errorf("want disabled feed not fetched, got %d requests", disabledHits)
+	}
+}
+
+func TestParser_SetFeeds(t *testing.T) {
+	newServer := func() *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(testFeedXML))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	first, second := newServer(), newServer()
+
+	parser := NewParser(config{RSS: []storage.Feed{{URL: first.URL, Enabled: true}}}, nil)
+	msgChan := make(chan ParserMsg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go parser.Run(ctx, msgChan)
+
+	if msg := <-msgChan; msg.Source != first.URL {
+		t.Fatalf("want source %s, got source %s", first.URL, msg.Source)
+	}
+
+	parser.SetFeeds([]storage.Feed{{URL: second.URL, Enabled: true}})
+
+	select {
+	case msg := <-msgChan:
+		if msg.Source != second.URL {
+			t.Errorf("want source %s after SetFeeds, got source %s", second.URL, msg.Source)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("added feed was not polled")
+	}
+
+	statuses := parser.Status()
+	if len(statuses) != 1 || statuses[0].URL != second.URL {
+		t.Errorf("want status of %s only, got %+v", second.URL, statuses)
+	}
+}
+
+func TestParser_RunWorkers(t *testing.T) {
+	var mu sync.Mutex
+	var running, maxRunning int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(testFeedXML))
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	var testConf config
+	for i := range 6 {
+		testConf.RSS = append(testConf.RSS, storage.Feed{URL: fmt.Sprintf("%s/%d", srv.URL, i), Enabled: true})
+	}
+	testConf.Workers = 2
+	testConf.HostRate = 1000
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgChan := make(chan ParserMsg)
+	parser := NewParser(testConf, nil)
+	go parser.Run(ctx, msgChan)
+
+	seen := make(map[string]bool)
+	for range testConf.RSS {
+		select {
+		case msg := <-msgChan:
+			if msg.Err != nil {
+				t.Fatalf("unexpected error from parser: %v", msg.Err)
+			}
+			seen[msg.Source] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("want %d feeds fetched, got %d", len(testConf.RSS), len(seen))
+		}
+	}
+	if len(seen) != len(testConf.RSS) {
+		t.Errorf("want %d different feeds fetched, got %d", len(testConf.RSS), len(seen))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxRunning > testConf.Workers {
+		t.Errorf("want at most %d feeds fetched at once, got %d", testConf.Workers, maxRunning)
+	}
+}
+
+func TestParser_RunWaitsForFetches(t *testing.T) {
+	started := make(chan struct{})
+	aborted := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		close(started)
+		<-r.Context().Done()
+		close(aborted)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	msgChan := make(chan ParserMsg)
+	parser := NewParser(config{RSS: []storage.Feed{{URL: srv.URL, Enabled: true}}}, nil)
+
+	stopped := make(chan struct{})
+	go func() {
+		parser.Run(ctx, msgChan)
+		// Sending to msgChan after Run has returned would panic.
+		close(msgChan)
+		close(stopped)
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("parser did not stop after the context was cancelled")
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("in-flight fetch was not aborted")
+	}
+	if _, ok := <-msgChan; ok {
+		t.Error("want no result of the aborted fetch")
+	}
+	if st := parser.Status(); len(st) != 1 || st[0].ConsecutiveFailures != 0 {
+		t.Errorf("want aborted fetch not counted as failure, got %+v", st)
+	}
+}
+
+func TestParser_RunPoliteness(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+		case "/feed":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(testFeedXML))
+		}
+	}))
+	defer srv.Close()
+
+	testConf := config{
+		RSS: []storage.Feed{
+			{URL: srv.URL + "/feed", Enabled: true},
+			{URL: srv.URL + "/private/feed", Enabled: true},
+		},
+		HostRate: 1000,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgChan := make(chan ParserMsg)
+	parser := NewParser(testConf, nil)
+	go parser.Run(ctx, msgChan)
+
+	errs := make(map[string]error)
+	for range testConf.RSS {
+		select {
+		case msg := <-msgChan:
+			errs[msg.Source] = msg.Err
+		case <-time.After(2 * time.Second):
+			t.Fatalf("want %d feeds fetched, got %d", len(testConf.RSS), len(errs))
+		}
+	}
+
+	var retry *crawl.RetryAfterError
+	if err := errs[srv.URL+"/feed"]; !errors.As(err, &retry) {
+		t.Errorf("want retry after error, got %v", err)
+	}
+	if err := errs[srv.URL+"/private/feed"]; !errors.Is(err, crawl.ErrDisallowed) {
+		t.Errorf("want error %v, got %v", crawl.ErrDisallowed, err)
+	}
+
+	// The feed is not fetched again before the time its host asked for.
+	for _, st := range parser.Status() {
+		if st.URL == srv.URL+"/feed" && time.Until(st.NextFetch) < 59*time.Minute {
+			t.Errorf("want next fetch in an hour, got %v", st.NextFetch)
+		}
+	}
+}
+
+func TestParser_Push(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == testETag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", testETag)
+		w.Header().Set("Link", `<https://hub.example.com/>; rel="hub"`)
+		w.Write([]byte(testFeedXML))
+	}))
+	defer srv.Close()
+
+	feed := storage.Feed{URL: srv.URL, Name: "Pushed", Enabled: true}
+	parser := NewParser(config{RSS: []storage.Feed{feed}, HostRate: 1000}, nil)
+
+	// The hub is reported by every fetch, including those of unmodified copies.
+	for i := range 2 {
+		msg := parser.parse(context.Background(), feed)
+		if msg.Hub != "https://hub.example.com/" || msg.Topic != srv.URL {
+			t.Errorf("want hub and topic of the feed, got %q and %q", msg.Hub, msg.Topic)
+		}
+		if i == 1 && !errors.Is(msg.Err, ErrNotModified) {
+			t.Errorf("want error %v on second fetch, got %v", ErrNotModified, msg.Err)
+		}
+		parser.Commit(msg)
+	}
+
+	msg := parser.Push(context.Background(), feed.URL, []byte(testFeedXML))
+	if msg.Err != nil {
+		t.Fatalf("unexpected error: %v", msg.Err)
+	}
+	if len(msg.Data) != 1 || msg.Data[0].Source.Name != "Pushed" || msg.Data[0].Link != "https://example.com/1" {
+		t.Errorf("want pushed item converted to a post, got %+v", msg.Data)
+	}
+
+	if msg := parser.Push(context.Background(), "https://example.com/unknown", []byte(testFeedXML)); !errors.Is(msg.Err, ErrUnknownFeed) {
+		t.Errorf("want error %v, got %v", ErrUnknownFeed, msg.Err)
+	}
+	if msg := parser.Push(context.Background(), feed.URL, []byte("not a feed")); msg.Err == nil {
+		t.Error("want error for invalid content, got nil")
+	}
+}
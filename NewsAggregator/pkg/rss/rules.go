@@ -0,0 +1,170 @@
+package rss
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+
+	"news/pkg/sanitize"
+	"news/pkg/storage"
+)
+
+// Reasons of rejection that are not rules of the feed.
+const (
+	rejectNoTitle   = "no title"
+	rejectNoLink    = "no link"
+	rejectNoContent = "no content"
+	rejectInclude   = "no include rule matched"
+	rejectMinLength = "min_length"
+)
+
+type compiledRule struct {
+	storage.Rule
+	re *regexp.Regexp
+}
+
+// ruleSet is the compiled form of the rules of a feed.
+type ruleSet struct {
+	feed      storage.Feed // Feed the rules are compiled from.
+	include   []compiledRule
+	exclude   []compiledRule
+	minLength int
+	// The items must have content. The summary of the posts of scraped
+	// sites is optional, see storage.Scraper, so they are not required to.
+	requireContent bool
+}
+
+// compileRules compiles the rules of the feed. Rules of the feeds are validated
+// when they are added, so a rule that doesn't compile is only logged and skipped.
+func compileRules(feed storage.Feed) ruleSet {
+	compile := func(rules []storage.Rule) []compiledRule {
+		var compiled []compiledRule
+		for _, r := range rules {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				log.Warnf("[rss] rule %q of %s skipped: %v", r, feed.URL, err)
+				continue
+			}
+			compiled = append(compiled, compiledRule{Rule: r, re: re})
+		}
+		return compiled
+	}
+
+	return ruleSet{
+		feed:           feed,
+		include:        compile(feed.Rules.Include),
+		exclude:        compile(feed.Rules.Exclude),
+		minLength:      feed.Rules.MinLength,
+		requireContent: feed.Scraper == nil,
+	}
+}
+
+// filter returns the posts of the feed that pass its rules, see storage.Rules,
+// and the number of rejected posts by the reason of rejection. Posts without
+// a title or an absolute link are always rejected, as are feed items without
+// content.
+func (rs ruleSet) filter(posts []storage.Post) ([]storage.Post, map[string]int) {
+	return rs.apply(posts, rs.reject)
+}
+
+// prefilter is filter with only the rules that don't depend on the content of
+// the posts, so the posts they reject are not downloaded in full. The posts it
+// keeps must still pass filter once their content is known.
+func (rs ruleSet) prefilter(posts []storage.Post) ([]storage.Post, map[string]int) {
+	return rs.apply(posts, rs.rejectEarly)
+}
+
+// apply returns the posts reject finds no reason to reject and the number of
+// rejected posts by the reason of rejection.
+func (rs ruleSet) apply(posts []storage.Post, reject func(storage.Post) string) ([]storage.Post, map[string]int) {
+	rejected := make(map[string]int)
+
+	kept := posts[:0]
+	for _, post := range posts {
+		reason := reject(post)
+		if reason == "" {
+			kept = append(kept, post)
+			continue
+		}
+
+		rejected[reason]++
+		log.Debugf("[rss] %q from %s rejected: %s", post.Title, rs.feed.URL, reason)
+	}
+
+	return kept, rejected
+}
+
+// rejectEarly returns the reason the post is rejected for by the rules that
+// don't depend on its content, or an empty string if it passes them. An include
+// rule for the content may still match, so the post passes the include rules
+// if there is one.
+func (rs ruleSet) rejectEarly(post storage.Post) string {
+	if post.Title == "" {
+		return rejectNoTitle
+	}
+	if u, err := url.ParseRequestURI(post.Link); err != nil || u.Host == "" {
+		return rejectNoLink
+	}
+
+	for _, r := range rs.exclude {
+		if r.Field != storage.RuleContent && r.match(post, "") {
+			return "exclude " + r.String()
+		}
+	}
+	if len(rs.include) > 0 && !slices.ContainsFunc(rs.include, func(r compiledRule) bool {
+		return r.Field == storage.RuleContent || r.match(post, "")
+	}) {
+		return rejectInclude
+	}
+
+	return ""
+}
+
+// reject returns the reason the post is rejected for, or an empty string if it passes.
+func (rs ruleSet) reject(post storage.Post) string {
+	if post.Title == "" {
+		return rejectNoTitle
+	}
+	if u, err := url.ParseRequestURI(post.Link); err != nil || u.Host == "" {
+		return rejectNoLink
+	}
+	if rs.requireContent && strings.TrimSpace(post.Content) == "" {
+		return rejectNoContent
+	}
+
+	text := sanitize.Text(post.Content)
+	if utf8.RuneCountInString(text) < rs.minLength {
+		return rejectMinLength
+	}
+
+	for _, r := range rs.exclude {
+		if r.match(post, text) {
+			return "exclude " + r.String()
+		}
+	}
+	if len(rs.include) > 0 && !slices.ContainsFunc(rs.include, func(r compiledRule) bool { return r.match(post, text) }) {
+		return rejectInclude
+	}
+
+	return ""
+}
+
+// match reports whether the field of the post matches the rule. text is the
+// plain text of the post content.
+func (r compiledRule) match(post storage.Post, text string) bool {
+	switch r.Field {
+	case storage.RuleTitle:
+		return r.re.MatchString(post.Title)
+	case storage.RuleContent:
+		return r.re.MatchString(text)
+	case storage.RuleCategory:
+		return slices.ContainsFunc(post.Categories, r.re.MatchString)
+	case storage.RuleAuthor:
+		return slices.ContainsFunc(post.Authors, r.re.MatchString)
+	}
+	return false
+}
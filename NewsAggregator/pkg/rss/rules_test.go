@@ -0,0 +1,168 @@
+package rss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"news/pkg/storage"
+)
+
+func TestFilterPosts(t *testing.T) {
+	posts := []storage.Post{
+		{Title: "Go 1.23 released", Content: "<p>Iterators over functions are here.</p>", Link: "https://example.com/1", Categories: []string{"Go"}},
+		{Title: "Rust 1.80 released", Content: "<p>LazyCell and LazyLock are stable.</p>", Link: "https://example.com/2", Categories: []string{"Rust"}},
+		{Title: "Generics in Go", Content: "<p>Sponsored post.</p>", Link: "https://example.com/3", Authors: []string{"Ad Bot"}},
+		{Title: "Short Go note", Content: "<p>Go.</p>", Link: "https://example.com/4"},
+		{Title: "", Content: "<p>Go without a title.</p>", Link: "https://example.com/5"},
+		{Title: "Go without a link", Content: "<p>Content.</p>", Link: "/6"},
+		{Title: "Go without content", Content: " ", Link: "https://example.com/7"},
+	}
+	feed := storage.Feed{
+		URL: "https://example.com/rss",
+		Rules: storage.Rules{
+			Include: []storage.Rule{
+				{Field: storage.RuleTitle, Pattern: `(?i)\bgo\b`},
+				{Field: storage.RuleCategory, Pattern: "^Go$"},
+			},
+			Exclude:   []storage.Rule{{Field: storage.RuleAuthor, Pattern: "(?i)bot"}},
+			MinLength: 10,
+		},
+	}
+
+	kept, rejected := compileRules(feed).filter(posts)
+
+	if len(kept) != 1 || kept[0].Link != "https://example.com/1" {
+		t.Errorf("want only the first post kept, got %+v", kept)
+	}
+	want := map[string]int{
+		rejectInclude:              1,
+		"exclude author ~ (?i)bot": 1,
+		rejectMinLength:            1,
+		rejectNoTitle:              1,
+		rejectNoLink:               1,
+		rejectNoContent:            1,
+	}
+	if !reflect.DeepEqual(rejected, want) {
+		t.Errorf("want rejected %v, got %v", want, rejected)
+	}
+}
+
+func TestPrefilterPosts(t *testing.T) {
+	posts := []storage.Post{
+		{Title: "Go 1.23 released", Link: "https://example.com/1"},
+		{Title: "Rust 1.80 released", Link: "https://example.com/2"},
+		{Title: "Generics in Go", Link: "https://example.com/3", Authors: []string{"Ad Bot"}},
+		{Title: "Go teaser", Link: "https://example.com/4"},
+	}
+	feed := storage.Feed{
+		URL: "https://example.com/rss",
+		Rules: storage.Rules{
+			Include: []storage.Rule{{Field: storage.RuleTitle, Pattern: `(?i)\bgo\b`}},
+			Exclude: []storage.Rule{
+				{Field: storage.RuleAuthor, Pattern: "(?i)bot"},
+				{Field: storage.RuleContent, Pattern: "(?i)sponsored"},
+			},
+			MinLength: 10,
+		},
+	}
+
+	// The content rules and min_length wait for the content.
+	kept, rejected := compileRules(feed).prefilter(posts)
+	if len(kept) != 2 || kept[0].Link != "https://example.com/1" || kept[1].Link != "https://example.com/4" {
+		t.Errorf("want the first and the last posts kept, got %+v", kept)
+	}
+	want := map[string]int{rejectInclude: 1, "exclude author ~ (?i)bot": 1}
+	if !reflect.DeepEqual(rejected, want) {
+		t.Errorf("want rejected %v, got %v", want, rejected)
+	}
+
+	// An include rule for the content may match any post.
+	feed.Rules.Include = []storage.Rule{{Field: storage.RuleContent, Pattern: "Go"}}
+	if kept, _ := compileRules(feed).prefilter(posts); len(kept) != 3 {
+		t.Errorf("want 3 posts kept, got %d", len(kept))
+	}
+}
+
+func TestFilterPosts_NoRules(t *testing.T) {
+	posts := []storage.Post{
+		{Title: "Title", Content: "<img src=\"https://example.com/1.png\">", Link: "https://example.com/1"},
+		{Title: "Title", Content: "Content", Link: "https://example.com/2"},
+	}
+
+	kept, rejected := compileRules(storage.Feed{URL: "https://example.com/rss"}).filter(posts)
+	if len(kept) != 2 || len(rejected) != 0 {
+		t.Errorf("want all posts kept, got %d kept and %v rejected", len(kept), rejected)
+	}
+}
+
+func TestFilterPosts_Scraper(t *testing.T) {
+	posts := []storage.Post{{Title: "Title", Link: "https://example.com/1"}}
+	feed := storage.Feed{URL: "https://example.com/news", Scraper: &testScraper}
+
+	// The summary of a scraped post is optional, so it may have no content.
+	kept, rejected := compileRules(feed).filter(posts)
+	if len(kept) != 1 || len(rejected) != 0 {
+		t.Errorf("want post kept, got %d kept and %v rejected", len(kept), rejected)
+	}
+
+	feed.Scraper = nil
+	if _, rejected := compileRules(feed).filter(posts); rejected[rejectNoContent] != 1 {
+		t.Errorf("want feed item without content rejected, got %v rejected", rejected)
+	}
+}
+
+func TestParser_RulesCompiledOnce(t *testing.T) {
+	feed := storage.Feed{
+		URL:   "https://example.com/rss",
+		Rules: storage.Rules{Exclude: []storage.Rule{{Field: storage.RuleTitle, Pattern: "ad"}}},
+	}
+	parser := NewParser(config{RSS: []storage.Feed{feed}}, nil)
+
+	first := parser.feedRules(feed)
+	if again := parser.feedRules(feed); again.exclude[0].re != first.exclude[0].re {
+		t.Error("want rules of an unchanged feed compiled once")
+	}
+
+	// Reloading the feeds keeps the rules of the unchanged ones.
+	parser.SetFeeds([]storage.Feed{feed})
+	if again := parser.feedRules(feed); again.exclude[0].re != first.exclude[0].re {
+		t.Error("want rules of an unchanged feed kept on reload")
+	}
+
+	feed.Rules.Exclude = []storage.Rule{{Field: storage.RuleTitle, Pattern: "promo"}}
+	parser.SetFeeds([]storage.Feed{feed})
+	if got := parser.feedRules(feed); got.exclude[0].Pattern != "promo" {
+		t.Errorf("want rules of a changed feed compiled anew, got %v", got.exclude)
+	}
+}
+
+func TestParser_Rules(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testFeedXML))
+	}))
+	defer srv.Close()
+
+	feed := storage.Feed{
+		URL:     srv.URL,
+		Enabled: true,
+		Rules:   storage.Rules{Exclude: []storage.Rule{{Field: storage.RuleContent, Pattern: "Test"}}},
+	}
+	parser := NewParser(config{RSS: []storage.Feed{feed}, HostRate: 1000}, nil)
+
+	for range 2 {
+		if msg := parser.parse(context.Background(), feed); msg.Err != nil || len(msg.Data) != 0 {
+			t.Fatalf("want no posts and no error, got %d posts and error %v", len(msg.Data), msg.Err)
+		}
+	}
+
+	statuses := parser.Status()
+	if len(statuses) != 1 {
+		t.Fatalf("want 1 feed status, got %d", len(statuses))
+	}
+	if got := statuses[0].Rejected["exclude content ~ Test"]; got != 2 {
+		t.Errorf("want 2 rejected items counted, got %v", statuses[0].Rejected)
+	}
+}
@@ -0,0 +1,103 @@
+package rss
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+
+	"news/pkg/storage"
+)
+
+// maxListPageSize limits the size of a scraped list page.
+const maxListPageSize = 5 << 20
+
+// ErrNoItems is returned when no element of a scraped page matches the item
+// selector, which usually means the site layout has changed.
+var ErrNoItems = fmt.Errorf("no items matched the scraper selectors")
+
+// scrape extracts the items of a list page with the scraper selectors. The
+// items are returned as a feed, so they are converted to posts and filtered
+// the same way as the items of RSS feeds. Relative links are resolved against
+// base, the URL the page was downloaded from.
+func scrape(r io.Reader, base *url.URL, s storage.Scraper) (*gofeed.Feed, error) {
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(r, maxListPageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &gofeed.Feed{
+		Title: collapseSpace(doc.Find("title").First().Text()),
+		Link:  base.String(),
+	}
+	doc.Find(s.Item).Each(func(_ int, sel *goquery.Selection) {
+		item := &gofeed.Item{
+			Title: collapseSpace(sel.Find(s.Title).First().Text()),
+			Link:  scrapeLink(sel, s.Link, base),
+		}
+		if s.Date != "" {
+			scrapeDate(item, sel.Find(s.Date).First(), s.DateLayout)
+		}
+		if s.Summary != "" {
+			item.Description, _ = sel.Find(s.Summary).First().Html()
+		}
+		feed.Items = append(feed.Items, item)
+	})
+	if len(feed.Items) == 0 {
+		return nil, ErrNoItems
+	}
+
+	return feed, nil
+}
+
+// scrapeLink returns the absolute URL of the link matched by the selector
+// within the item: the element itself if it is a link, or the first link within it.
+func scrapeLink(item *goquery.Selection, selector string, base *url.URL) string {
+	sel := item.Find(selector).First()
+	if !sel.Is("a") {
+		sel = sel.Find("a[href]").First()
+	}
+
+	href, ok := sel.Attr("href")
+	if !ok {
+		return ""
+	}
+	u, err := base.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+
+	return u.String()
+}
+
+// scrapeDate sets the publication date of the item from the element: the
+// datetime attribute of the element or of an element within it, as in
+// <time datetime="...">, or else the element text. The date is parsed with
+// the layout if it is set; otherwise, or if it doesn't match, the date is
+// left for the date resolver, see dateResolver.published.
+func scrapeDate(item *gofeed.Item, sel *goquery.Selection, layout string) {
+	date, ok := sel.Attr("datetime")
+	if !ok {
+		date, ok = sel.Find("[datetime]").First().Attr("datetime")
+	}
+	if !ok {
+		date = collapseSpace(sel.Text())
+	}
+	item.Published = date
+
+	if layout == "" {
+		return
+	}
+	if t, err := time.Parse(layout, date); err == nil {
+		item.PublishedParsed = &t
+	}
+}
+
+// collapseSpace trims the text and replaces runs of whitespace with single spaces.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
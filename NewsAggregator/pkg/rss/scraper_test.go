@@ -0,0 +1,132 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"news/pkg/storage"
+)
+
+var testScraper = storage.Scraper{
+	Item:       "article.news-item",
+	Title:      ".news-item__title",
+	Link:       ".news-item__title a, a.news-item__more",
+	Date:       ".news-item__date",
+	DateLayout: "02.01.2006 15:04",
+	Summary:    ".news-item__lead",
+}
+
+// newListPageServer returns a server that serves the HTML fixture at /news/.
+func newListPageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/news/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "testdata/news_list.html")
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestParser_Scraper(t *testing.T) {
+	srv := newListPageServer(t)
+
+	feed := storage.Feed{URL: srv.URL + "/news/", Enabled: true, Scraper: &testScraper}
+	parser := NewParser(config{RSS: []storage.Feed{feed}}, nil)
+
+	start := time.Now()
+	msg := parser.parse(context.Background(), feed)
+	if msg.Err != nil {
+		t.Fatalf("unexpected error: %v", msg.Err)
+	}
+	if len(msg.Data) != 3 {
+		t.Fatalf("want 3 posts, got %d", len(msg.Data))
+	}
+
+	want := []storage.Post{
+		{
+			Title:     "Вышел Go 1.23",
+			Link:      srv.URL + "/news/go-1-23",
+			Published: time.Date(2024, 8, 13, 12, 0, 0, 0, time.UTC),
+			Content:   "<p>В языке появились <b>итераторы</b> по функциям.</p>",
+		},
+		{
+			Title:     "Конференция GopherCon",
+			Link:      "https://conf.example.com/gophercon",
+			Published: time.Date(2024, 7, 1, 6, 30, 0, 0, time.UTC),
+			Content:   "<p>Открыта регистрация на конференцию.</p>",
+		},
+		{
+			Title: "Митап в офисе",
+			Link:  srv.URL + "/news/news/meetup",
+		},
+	}
+	for i, post := range msg.Data {
+		if post.Title != want[i].Title || post.Link != want[i].Link || post.Content != want[i].Content {
+			t.Errorf("want post #%d %q at %s with content %q, got %q at %s with content %q",
+				i, want[i].Title, want[i].Link, want[i].Content, post.Title, post.Link, post.Content)
+		}
+		if post.Source.Name != "Новости компании" || post.Source.URL != feed.URL {
+			t.Errorf("want post #%d source named after the page title, got %+v", i, post.Source)
+		}
+		if !want[i].Published.IsZero() && !post.Published.Equal(want[i].Published) {
+			t.Errorf("want post #%d published at %v, got %v", i, want[i].Published, post.Published)
+		}
+	}
+	// The date of the last item can't be parsed, so it is dated when it was first seen.
+	if got := msg.Data[2].Published; got.Before(start) {
+		t.Errorf("want post without a date published at the time it was first seen, got %v", got)
+	}
+}
+
+func TestParser_ScraperNoItems(t *testing.T) {
+	srv := newListPageServer(t)
+
+	scraper := testScraper
+	scraper.Item = "div.post"
+	feed := storage.Feed{URL: srv.URL + "/news/", Enabled: true, Scraper: &scraper}
+	parser := NewParser(config{RSS: []storage.Feed{feed}}, nil)
+
+	msg := parser.parse(context.Background(), feed)
+	if !errors.Is(msg.Err, ErrNoItems) {
+		t.Errorf("want error %v, got %v", ErrNoItems, msg.Err)
+	}
+}
+
+func TestScrape_DateWithoutLayout(t *testing.T) {
+	page := `<ul><li><a href="/1">Post</a> <i>Tue, 13 Aug 2024 12:00:00 MSK</i></li></ul>`
+	scraper := storage.Scraper{Item: "li", Title: "a", Link: "a", Date: "i"}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	f, _, err := NewFetcher(nil, nil).Fetch(context.Background(), storage.Feed{URL: srv.URL, Scraper: &scraper})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.Items) != 1 {
+		t.Fatalf("want 1 item, got %d", len(f.Items))
+	}
+
+	posts, err := (&Parser{}).handleFeed(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2024, 8, 13, 9, 0, 0, 0, time.UTC); !posts[0].Published.Equal(want) {
+		t.Errorf("want date parsed with the feed layouts %v, got %v", want, posts[0].Published)
+	}
+	if !strings.HasPrefix(posts[0].Link, srv.URL) {
+		t.Errorf("want link resolved against the page URL, got %s", posts[0].Link)
+	}
+}
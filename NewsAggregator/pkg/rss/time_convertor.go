@@ -1,28 +1,232 @@
 package rss
 
 import (
+	"container/list"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/mmcdole/gofeed"
 )
 
+// firstSeenCacheSize is the number of items whose first-seen time is kept in memory.
+const firstSeenCacheSize = 10000
+
 var ErrInvalidTimeFormat = fmt.Errorf("invalid time format")
 
-// Note: Formats using timezone abbreviations (RFC1123/RFC822) may exhibit
-// varying behavior depending on the host environment's timezone database.
+// defaultTimezones resolves the timezone abbreviations found in feeds to UTC offsets.
+// Abbreviations shared by several zones resolve to the most common one: IST is
+// India Standard Time and CST is US Central Standard Time. The table can be
+// extended or overridden with the timezones field of the parser config.
+var defaultTimezones = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"IST":  "+0530",
+	"HKT":  "+0800",
+	"SGT":  "+0800",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+// rssTimeFormats are tried in order after the weekday is dropped and timezone
+// abbreviations are replaced with numeric offsets, see dateResolver.parse.
+// Layouts without a timezone are parsed as UTC.
 var rssTimeFormats = []string{
-	time.RFC822Z,                     // "02 Jan 06 15:04 -0700"
-	time.RFC1123Z,                    // "Mon, 02 Jan 2006 15:04:05 -0700"
-	time.RFC1123,                     // "Mon, 02 Jan 2006 15:04:05 MST"
-	time.RFC822,                      // "02 Jan 06 15:04 MST"
-	"Mon, 2 Jan 2006 15:04:05 +0000", // Custom for single digit date.
+	// RFC822, RFC1123 and their variants: two-digit years, no seconds, full month names.
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"02-Jan-06 15:04:05 -0700", // RFC850.
+	// Unknown abbreviations are parsed as a zone with zero offset.
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 06 15:04 MST",
+	// ISO 8601 and RFC3339.
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	// Regional variants.
+	"02.01.2006 15:04:05 -0700",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	time.ANSIC,
+}
+
+// ConvertToUTC parses a feed date with the default timezone abbreviations.
+func ConvertToUTC(rssTime string) (time.Time, error) {
+	return newDateResolver(nil).parse(rssTime)
+}
+
+// dateResolver finds the publication date of feed items.
+type dateResolver struct {
+	zones map[string]string // UTC offsets by upper-case timezone abbreviation.
+
+	mu    sync.Mutex
+	seen  map[string]*list.Element // Elements of order by item key.
+	order *list.List               // First-seen times of items, most recently seen first.
+}
+
+type seenItem struct {
+	key string
+	at  time.Time
+}
+
+// newDateResolver returns a resolver that uses the default timezone table
+// extended with the given abbreviations. The offsets must be valid, see
+// parseOffset.
+func newDateResolver(zones map[string]string) *dateResolver {
+	r := &dateResolver{
+		zones: make(map[string]string, len(defaultTimezones)+len(zones)),
+		seen:  make(map[string]*list.Element),
+		order: list.New(),
+	}
+	for abbr, offset := range defaultTimezones {
+		r.zones[abbr] = offset
+	}
+	for abbr, offset := range zones {
+		if off, err := parseOffset(offset); err == nil {
+			r.zones[strings.ToUpper(abbr)] = off
+		}
+	}
+
+	return r
+}
+
+// published returns the publication date of the item in UTC: the date parsed
+// by gofeed, then the updated date, then the published and updated strings
+// parsed with the extended layouts. Items without a valid date get the time
+// they were first seen at, as do items dated after now, so a date in the
+// future doesn't keep the post on top of the list.
+func (r *dateResolver) published(item *gofeed.Item, now time.Time) time.Time {
+	var t time.Time
+	switch {
+	case item.PublishedParsed != nil:
+		t = *item.PublishedParsed
+	case item.UpdatedParsed != nil:
+		t = *item.UpdatedParsed
+	default:
+		for _, s := range []string{item.Published, item.Updated} {
+			if parsed, err := r.parse(s); err == nil {
+				t = parsed
+				break
+			}
+		}
+	}
+
+	if t.IsZero() || t.After(now) {
+		return r.firstSeen(itemKey(item), now)
+	}
+	return t.UTC()
 }
 
-func ConvertToUTC(rssTime string) (t time.Time, err error) {
-	for _, format := range rssTimeFormats {
-		t, err = time.Parse(format, rssTime)
+// parse parses a date with the known layouts. A leading weekday is dropped,
+// since feeds often get it wrong, and timezone abbreviations from the table
+// are replaced with their offsets.
+func (r *dateResolver) parse(s string) (time.Time, error) {
+	fields := strings.Fields(s)
+	if len(fields) > 0 && strings.HasSuffix(fields[0], ",") {
+		fields = fields[1:]
+	}
+	for i, f := range fields {
+		if offset, ok := r.zones[strings.ToUpper(f)]; ok && i > 0 {
+			fields[i] = offset
+		}
+	}
+	normalized := strings.Join(fields, " ")
+
+	var err error
+	for _, layout := range rssTimeFormats {
+		var t time.Time
+		t, err = time.Parse(layout, normalized)
 		if err == nil {
 			return t.UTC(), nil
 		}
 	}
-	return t, fmt.Errorf("%w: %w", ErrInvalidTimeFormat, err)
+	return time.Time{}, fmt.Errorf("%w: %w", ErrInvalidTimeFormat, err)
+}
+
+// firstSeen returns the time the item with the given key was first seen at,
+// remembering now for an item that has not been seen yet. The times are kept
+// in memory only, so they are reset when the service restarts; the dates of
+// the stored posts are kept by the storage, see storage.Storage.AddPost.
+func (r *dateResolver) firstSeen(key string, now time.Time) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if el, ok := r.seen[key]; ok {
+		r.order.MoveToFront(el)
+		return el.Value.(seenItem).at
+	}
+
+	now = now.UTC()
+	r.seen[key] = r.order.PushFront(seenItem{key: key, at: now})
+	if r.order.Len() > firstSeenCacheSize {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.seen, oldest.Value.(seenItem).key)
+	}
+
+	return now
+}
+
+// itemKey identifies an item across polls: by its link, GUID or, failing
+// both, its title and content.
+func itemKey(item *gofeed.Item) string {
+	switch {
+	case item.Link != "":
+		return item.Link
+	case item.GUID != "":
+		return item.GUID
+	default:
+		return item.Title + "\n" + item.Description
+	}
+}
+
+// parseOffset normalizes a UTC offset in the -0700 or -07:00 form to -0700.
+func parseOffset(offset string) (string, error) {
+	for _, layout := range []string{"-0700", "-07:00"} {
+		if t, err := time.Parse(layout, offset); err == nil {
+			return t.Format("-0700"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid UTC offset %q", offset)
 }
@@ -1,9 +1,15 @@
 package rss
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"news/pkg/storage"
+	"news/pkg/storage/memdb"
 )
 
 func TestConvertToUTC(t *testing.T) {
@@ -31,13 +37,42 @@ func TestConvertToUTC(t *testing.T) {
 			want:    time.Date(2006, time.January, 2, 22, 4, 5, 0, time.UTC),
 			wantErr: nil,
 		},
-		// Broken in Go: ambiguous timezone abbreviations. Test fails.
-		// {
-		// 	name:    "RFC822 with timezone abbreviation",
-		// 	input:   "02 Jan 06 15:04 MST",
-		// 	want:    time.Date(2006, time.January, 2, 22, 4, 0, 0, time.UTC),
-		// 	wantErr: nil,
-		// },
+		{
+			name:    "RFC822 with timezone abbreviation",
+			input:   "02 Jan 06 15:04 MST",
+			want:    time.Date(2006, time.January, 2, 22, 4, 0, 0, time.UTC),
+			wantErr: nil,
+		},
+		{
+			name:    "RFC1123 with non-US timezone abbreviation",
+			input:   "Tue, 03 Sep 2019 15:04:05 MSK",
+			want:    time.Date(2019, time.September, 3, 12, 4, 5, 0, time.UTC),
+			wantErr: nil,
+		},
+		{
+			name:    "wrong weekday and no seconds",
+			input:   "Sun, 3 Sep 2019 15:04 +0200",
+			want:    time.Date(2019, time.September, 3, 13, 4, 0, 0, time.UTC),
+			wantErr: nil,
+		},
+		{
+			name:    "RFC3339 format",
+			input:   "2019-09-03T15:04:05+03:00",
+			want:    time.Date(2019, time.September, 3, 12, 4, 5, 0, time.UTC),
+			wantErr: nil,
+		},
+		{
+			name:    "ISO 8601 without timezone",
+			input:   "2019-09-03 15:04:05",
+			want:    time.Date(2019, time.September, 3, 15, 4, 5, 0, time.UTC),
+			wantErr: nil,
+		},
+		{
+			name:    "regional dotted format",
+			input:   "03.09.2019 15:04",
+			want:    time.Date(2019, time.September, 3, 15, 4, 0, 0, time.UTC),
+			wantErr: nil,
+		},
 		{
 			name:    "RFC822Z format",
 			input:   "02 Jan 06 15:04 -0700",
@@ -55,7 +90,7 @@ func TestConvertToUTC(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := ConvertToUTC(tt.input)
-			if (err != nil) && !(errors.Is(err, ErrInvalidTimeFormat)) {
+			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("ConvertToUTC() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -65,3 +100,103 @@ func TestConvertToUTC(t *testing.T) {
 		})
 	}
 }
+
+func TestDateResolver_Timezones(t *testing.T) {
+	r := newDateResolver(map[string]string{"cst": "+08:00"})
+
+	got, err := r.parse("Tue, 03 Sep 2019 15:04:05 CST")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := time.Date(2019, time.September, 3, 7, 4, 5, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestDateResolver_Published(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	published := time.Date(2024, time.April, 30, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	updated := time.Date(2024, time.April, 30, 11, 0, 0, 0, time.UTC)
+	future := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name string
+		item gofeed.Item
+		want time.Time
+	}{
+		{
+			name: "parsed published date",
+			item: gofeed.Item{Link: "https://example.com/1", PublishedParsed: &published, UpdatedParsed: &updated},
+			want: published.UTC(),
+		},
+		{
+			name: "parsed updated date",
+			item: gofeed.Item{Link: "https://example.com/2", UpdatedParsed: &updated},
+			want: updated,
+		},
+		{
+			name: "published string",
+			item: gofeed.Item{Link: "https://example.com/3", Published: "30.04.2024 11:00"},
+			want: updated,
+		},
+		{
+			name: "updated string",
+			item: gofeed.Item{Link: "https://example.com/4", Published: "yesterday", Updated: "2024-04-30T11:00:00Z"},
+			want: updated,
+		},
+		{
+			name: "no date",
+			item: gofeed.Item{Link: "https://example.com/5"},
+			want: now,
+		},
+		{
+			name: "future date",
+			item: gofeed.Item{Link: "https://example.com/6", PublishedParsed: &future},
+			want: now,
+		},
+	}
+
+	r := newDateResolver(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.published(&tt.item, now)
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+
+			// Items without a usable date keep their first-seen time on later polls.
+			if got := r.published(&tt.item, now.Add(time.Hour)); !got.Equal(tt.want) {
+				t.Errorf("want %v on the next poll, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDateResolver_PublishedAfterRestart(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	item := gofeed.Item{Title: "Undated", Link: "https://example.com/undated"}
+	db := memdb.New()
+
+	// Every resolver is a restart of the service, which forgets the first-seen
+	// times, but the storage keeps the date of the stored post.
+	for i := range 2 {
+		post := storage.Post{
+			Title:     item.Title,
+			Link:      item.Link,
+			Published: newDateResolver(nil).published(&item, now.Add(time.Duration(i)*time.Hour)),
+		}
+		id, err := db.AddPost(context.Background(), post)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := db.Post(context.Background(), id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !got.Published.Equal(now) {
+			t.Errorf("want date %v of the first poll after %d restarts, got %v", now, i, got.Published)
+		}
+	}
+}
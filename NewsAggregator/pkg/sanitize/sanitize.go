@@ -0,0 +1,331 @@
+// Package sanitize cleans up the HTML content of feed items before it is
+// stored and served to clients.
+package sanitize
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedAttrs lists the allowed elements with their allowed attributes.
+// Elements that are not listed are unwrapped: their children are kept.
+var allowedAttrs = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.B:          nil,
+	atom.Blockquote: nil,
+	atom.Br:         nil,
+	atom.Code:       nil,
+	atom.Del:        nil,
+	atom.Div:        nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Li:         nil,
+	atom.Ol:         nil,
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.S:          nil,
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      nil,
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// droppedElements are removed together with their content.
+var droppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Form:     true,
+	atom.Head:     true,
+	atom.Title:    true,
+	atom.Svg:      true,
+	atom.Math:     true,
+}
+
+// voidElements have no closing tag.
+var voidElements = map[atom.Atom]bool{
+	atom.Br:  true,
+	atom.Hr:  true,
+	atom.Img: true,
+}
+
+// blockElements separate words when the content is reduced to plain text.
+var blockElements = map[atom.Atom]bool{
+	atom.Blockquote: true,
+	atom.Br:         true,
+	atom.Div:        true,
+	atom.Figcaption: true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Hr:         true,
+	atom.Li:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Td:         true,
+	atom.Th:         true,
+	atom.Tr:         true,
+}
+
+// trackerPatterns match the image URLs of known tracking pixels.
+var trackerPatterns = []string{
+	"feeds.feedburner.com/~r/",
+	"feedproxy.google.com/~r/",
+	"pixel.wp.com",
+	"stats.wordpress.com",
+	"google-analytics.com",
+	"doubleclick.net",
+	"/pixel.gif",
+	"/tracking/",
+}
+
+// HTML reduces content to the allowlisted subset of HTML. Scripts, styles,
+// embedded frames, event handlers and tracking pixels are removed, links are
+// resolved against base and only http, https and mailto links are kept.
+func HTML(content, base string) string {
+	nodes, err := parse(content)
+	if err != nil {
+		return html.EscapeString(content)
+	}
+
+	baseURL, _ := url.Parse(base)
+
+	var b strings.Builder
+	for _, n := range nodes {
+		render(&b, n, baseURL)
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// URL resolves the address of a media file against base and returns it if it
+// is an http or https URL, an empty string otherwise.
+func URL(ref, base string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	baseURL, _ := url.Parse(base)
+	return resolveURL(ref, baseURL, false)
+}
+
+// Text returns the text of the HTML content with whitespace collapsed.
+// The content of dropped elements such as scripts is left out.
+func Text(content string) string {
+	nodes, err := parse(content)
+	if err != nil {
+		return strings.Join(strings.Fields(content), " ")
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		text(&b, n)
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Summary returns the plain text of the HTML content cut to at most max
+// characters. A cut text ends with an ellipsis and, where possible, is cut
+// at a word boundary.
+func Summary(content string, max int) string {
+	s := Text(content)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	if max < 1 {
+		return ""
+	}
+
+	// Keep room for the ellipsis.
+	runes := []rune(s)
+	cut := string(runes[:max-1])
+	if !unicode.IsSpace(runes[max-1]) {
+		if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > len(cut)/2 {
+			cut = cut[:i]
+		}
+	}
+
+	return strings.TrimRightFunc(cut, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
+
+func parse(content string) ([]*html.Node, error) {
+	return html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+}
+
+func render(b *strings.Builder, n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		// Comments and doctypes are dropped.
+		return
+	}
+
+	if droppedElements[n.DataAtom] {
+		return
+	}
+
+	allowed, ok := allowedAttrs[n.DataAtom]
+	if !ok {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			render(b, c, base)
+		}
+		return
+	}
+
+	attrs := filterAttrs(n, allowed, base)
+	if n.DataAtom == atom.Img && (attrs["src"] == "" || isTrackingPixel(attrs)) {
+		return
+	}
+
+	b.WriteByte('<')
+	b.WriteString(n.Data)
+	for _, name := range allowed {
+		if v, ok := attrs[name]; ok {
+			b.WriteString(" " + name + `="` + html.EscapeString(v) + `"`)
+		}
+	}
+	if n.DataAtom == atom.A && attrs["href"] != "" {
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	b.WriteByte('>')
+
+	if voidElements[n.DataAtom] {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		render(b, c, base)
+	}
+	b.WriteString("</" + n.Data + ">")
+}
+
+// filterAttrs returns the allowed attributes of the element. URL attributes
+// are resolved against base and dropped if their scheme is not allowed.
+func filterAttrs(n *html.Node, allowed []string, base *url.URL) map[string]string {
+	attrs := make(map[string]string)
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !slices.Contains(allowed, a.Key) {
+			continue
+		}
+
+		v := strings.TrimSpace(a.Val)
+		if a.Key == "href" || a.Key == "src" {
+			v = resolveURL(v, base, a.Key == "href")
+			if v == "" {
+				continue
+			}
+		}
+		attrs[a.Key] = v
+	}
+
+	return attrs
+}
+
+// resolveURL resolves ref against base and returns it if its scheme is safe.
+func resolveURL(ref string, base *url.URL, allowMailto bool) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.String()
+	case "mailto":
+		if allowMailto {
+			return u.String()
+		}
+	}
+
+	return ""
+}
+
+// isTrackingPixel reports whether the image is a tracking pixel: a 1x1 image
+// or an image served by a known tracker.
+func isTrackingPixel(attrs map[string]string) bool {
+	for _, dim := range []string{"width", "height"} {
+		if v, ok := attrs[dim]; ok {
+			if n, err := strconv.Atoi(strings.TrimSuffix(v, "px")); err == nil && n <= 1 {
+				return true
+			}
+		}
+	}
+
+	src := strings.ToLower(attrs["src"])
+	for _, p := range trackerPatterns {
+		if strings.Contains(src, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func text(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if droppedElements[n.DataAtom] {
+			return
+		}
+	default:
+		return
+	}
+
+	if blockElements[n.DataAtom] {
+		b.WriteByte(' ')
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text(b, c)
+	}
+	if blockElements[n.DataAtom] {
+		b.WriteByte(' ')
+	}
+}
@@ -0,0 +1,139 @@
+package sanitize
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestHTML(t *testing.T) {
+	const base = "https://example.com/blog/post-1"
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "plain text",
+			content: "Go 1.23 & generics",
+			want:    "Go 1.23 &amp; generics",
+		},
+		{
+			name:    "allowed markup",
+			content: `<p>Hello, <strong>world</strong>!<br></p>`,
+			want:    `<p>Hello, <strong>world</strong>!<br></p>`,
+		},
+		{
+			name:    "script and style",
+			content: `<p>Text</p><script>alert(1)</script><style>p{}</style>`,
+			want:    `<p>Text</p>`,
+		},
+		{
+			name:    "event handlers and styles",
+			content: `<p onclick="alert(1)" style="color:red" class="lead">Text</p>`,
+			want:    `<p>Text</p>`,
+		},
+		{
+			name:    "unknown elements are unwrapped",
+			content: `<section><font color="red">Text</font></section>`,
+			want:    `Text`,
+		},
+		{
+			name:    "relative links",
+			content: `<a href="../about">About</a> <img src="/img/cover.png" alt="Cover">`,
+			want:    `<a href="https://example.com/about" rel="nofollow noopener noreferrer">About</a> <img src="https://example.com/img/cover.png" alt="Cover">`,
+		},
+		{
+			name:    "javascript links",
+			content: `<a href="javascript:alert(1)">Click</a><a href="mailto:editor@example.com">Mail</a>`,
+			want:    `<a>Click</a><a href="mailto:editor@example.com" rel="nofollow noopener noreferrer">Mail</a>`,
+		},
+		{
+			name:    "tracking pixels",
+			content: `<p>Text<img src="https://example.com/t.gif" width="1" height="1"><img src="https://feeds.feedburner.com/~r/blog/~4/abc"></p>`,
+			want:    `<p>Text</p>`,
+		},
+		{
+			name:    "iframes and comments",
+			content: `<iframe src="https://evil.example.com"></iframe><!-- note -->Text`,
+			want:    `Text`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.content, base); got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	const base = "https://example.com/blog/post"
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"https://cdn.example.com/a.png", "https://cdn.example.com/a.png"},
+		{"/img/a.png", "https://example.com/img/a.png"},
+		{"a.mp3", "https://example.com/blog/a.mp3"},
+		{" //cdn.example.com/a.png ", "https://cdn.example.com/a.png"},
+		{"javascript:alert(1)", ""},
+		{"data:image/png;base64,AAAA", ""},
+		{"mailto:editor@example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := URL(tt.ref, base); got != tt.want {
+			t.Errorf("want %q for %q, got %q", tt.want, tt.ref, got)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		max     int
+		want    string
+	}{
+		{
+			name:    "short text",
+			content: "<p>Hello,</p><p>world</p><script>alert(1)</script>",
+			max:     100,
+			want:    "Hello, world",
+		},
+		{
+			name:    "cut at word boundary",
+			content: "<p>The quick brown fox jumps over the lazy dog.</p>",
+			max:     20,
+			want:    "The quick brown fox…",
+		},
+		{
+			name:    "cut long word",
+			content: "Supercalifragilisticexpialidocious",
+			max:     10,
+			want:    "Supercali…",
+		},
+		{
+			name:    "cyrillic",
+			content: "Новости мира Go",
+			max:     10,
+			want:    "Новости…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Summary(tt.content, tt.max)
+			if got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+			if n := utf8.RuneCountInString(got); n > tt.max {
+				t.Errorf("want at most %d characters, got %d", tt.max, n)
+			}
+		})
+	}
+}
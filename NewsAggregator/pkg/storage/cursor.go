@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"encoding/base64"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Cursor is a position in a list of posts ordered as by LatestPosts, the date
+// and the ID of the post next to it. Unlike a page number, it keeps pointing
+// at the same place while new posts are added to the top of the list.
+// The zero value is the start of the list.
+type Cursor struct {
+	Published time.Time
+	ID        uuid.UUID
+	Backward  bool // The page ends before the post instead of starting after it.
+}
+
+// PostsPage is a page of a list of posts fetched by a cursor.
+type PostsPage struct {
+	Posts []Post
+	Next  Cursor // Start of the following page, zero on the last page.
+	Prev  Cursor // End of the preceding page, zero on the first page.
+}
+
+// IsZero reports whether the cursor is the start of the list.
+func (c Cursor) IsZero() bool {
+	return c.Published.IsZero() && c.ID == uuid.Nil && !c.Backward
+}
+
+// After returns the cursor of the page following the post.
+func After(p Post) Cursor {
+	return Cursor{Published: p.Published, ID: p.ID}
+}
+
+// Before returns the cursor of the page preceding the post.
+func Before(p Post) Cursor {
+	return Cursor{Published: p.Published, ID: p.ID, Backward: true}
+}
+
+// String returns the cursor in the opaque form clients pass back,
+// empty for the zero cursor.
+func (c Cursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	dir := "n"
+	if c.Backward {
+		dir = "p"
+	}
+	raw := dir + "_" + c.Published.UTC().Format(time.RFC3339Nano) + "_" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor parses a cursor returned by Cursor.String. Returns ErrInvalidCursor
+// if it is malformed.
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "_")
+	if len(parts) != 3 || parts[0] != "n" && parts[0] != "p" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	published, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, err := uuid.FromString(parts[2])
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	c := Cursor{Published: published.UTC(), ID: id, Backward: parts[0] == "p"}
+	if c.IsZero() {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// CursorPage makes the page of at most limit posts the cursor points to.
+// The posts are the ones fetched for the cursor: up to limit+1 posts that
+// follow it in the list order or, for a backward cursor, that precede it in
+// the reverse order. The extra post tells whether there is a page further on.
+func CursorPage(posts []Post, cursor Cursor, limit int) PostsPage {
+	more := len(posts) > limit
+	posts = slices.Clone(posts[:min(len(posts), limit)])
+	if cursor.Backward {
+		slices.Reverse(posts)
+	}
+
+	page := PostsPage{Posts: posts}
+	if page.Posts == nil {
+		page.Posts = []Post{}
+	}
+	if len(posts) == 0 {
+		// Past either end of the list there is the way back only.
+		switch {
+		case cursor.IsZero():
+		case cursor.Backward:
+			page.Next = Cursor{Published: cursor.Published, ID: cursor.ID}
+		default:
+			page.Prev = Cursor{Published: cursor.Published, ID: cursor.ID, Backward: true}
+		}
+		return page
+	}
+
+	hasNext, hasPrev := more, !cursor.IsZero()
+	if cursor.Backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		page.Next = After(posts[len(posts)-1])
+	}
+	if hasPrev {
+		page.Prev = Before(posts[0])
+	}
+
+	return page
+}
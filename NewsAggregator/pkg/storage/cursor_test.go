@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestParseCursor(t *testing.T) {
+	id := uuid.NewV5(uuid.NamespaceURL, "https://example.com/1")
+	published := time.Date(2025, 6, 30, 12, 0, 0, 123456000, time.UTC)
+
+	for _, c := range []Cursor{
+		{Published: published, ID: id},
+		{Published: published, ID: id, Backward: true},
+	} {
+		got, err := ParseCursor(c.String())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("want cursor %+v, got %+v", c, got)
+		}
+	}
+
+	if s := (Cursor{}).String(); s != "" {
+		t.Errorf("want empty zero cursor, got %q", s)
+	}
+	for _, s := range []string{"", "!!!", "bl9ub3Rh", Cursor{Published: published, ID: id}.String() + "x"} {
+		if _, err := ParseCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("want error %v for cursor %q, got %v", ErrInvalidCursor, s, err)
+		}
+	}
+}
+
+func TestCursorPage(t *testing.T) {
+	var posts []Post
+	for i := range 5 {
+		posts = append(posts, Post{
+			ID:        uuid.NewV5(uuid.NamespaceURL, string(rune('a'+i))),
+			Published: time.Date(2025, 6, 30-i, 0, 0, 0, 0, time.UTC),
+		})
+	}
+
+	tests := []struct {
+		name      string
+		fetched   []Post
+		cursor    Cursor
+		wantPosts []Post
+		wantNext  Cursor
+		wantPrev  Cursor
+	}{
+		{"first page", posts[:3], Cursor{}, posts[:2], After(posts[1]), Cursor{}},
+		{"only page", posts[:2], Cursor{}, posts[:2], Cursor{}, Cursor{}},
+		{"middle page", posts[2:5], After(posts[1]), posts[2:4], After(posts[3]), Before(posts[2])},
+		{"last page", posts[4:], After(posts[3]), posts[4:], Cursor{}, Before(posts[4])},
+		{"previous page", []Post{posts[1], posts[0]}, Before(posts[2]), posts[:2], After(posts[1]), Cursor{}},
+		{"previous page with more", []Post{posts[3], posts[2], posts[1]}, Before(posts[4]), posts[2:4], After(posts[3]), Before(posts[2])},
+		{"past the end", nil, After(posts[4]), []Post{}, Cursor{}, Before(posts[4])},
+		{"before the start", nil, Before(posts[0]), []Post{}, After(posts[0]), Cursor{}},
+		{"empty list", nil, Cursor{}, []Post{}, Cursor{}, Cursor{}},
+	}
+	for _, tt := range tests {
+		page := CursorPage(tt.fetched, tt.cursor, 2)
+		if !reflect.DeepEqual(page.Posts, tt.wantPosts) {
+			t.Errorf("%s: want posts %v, got %v", tt.name, tt.wantPosts, page.Posts)
+		}
+		if page.Next != tt.wantNext {
+			t.Errorf("%s: want next cursor %+v, got %+v", tt.name, tt.wantNext, page.Next)
+		}
+		if page.Prev != tt.wantPrev {
+			t.Errorf("%s: want previous cursor %+v, got %+v", tt.name, tt.wantPrev, page.Prev)
+		}
+	}
+}
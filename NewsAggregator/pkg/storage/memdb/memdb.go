@@ -2,22 +2,32 @@ package memdb
 
 import (
 	"context"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"news/pkg/dedup"
 	"news/pkg/storage"
 
 	"github.com/gofrs/uuid"
 )
 
 type Store struct {
-	mu    sync.Mutex
-	posts map[uuid.UUID]storage.Post
+	mu         sync.Mutex
+	posts      map[uuid.UUID]storage.Post
+	duplicates map[uuid.UUID]uuid.UUID          // Canonical post IDs by alternate post ID.
+	revisions  map[uuid.UUID][]storage.Revision // Post revisions by post ID, oldest first.
+	feeds      map[uuid.UUID]storage.Feed
 }
 
 func New() *Store {
 	db := Store{
-		posts: make(map[uuid.UUID]storage.Post),
+		posts:      make(map[uuid.UUID]storage.Post),
+		duplicates: make(map[uuid.UUID]uuid.UUID),
+		revisions:  make(map[uuid.UUID][]storage.Revision),
+		feeds:      make(map[uuid.UUID]storage.Feed),
 	}
 
 	return &db
@@ -27,10 +37,7 @@ func (db *Store) AddPost(ctx context.Context, post storage.Post) (id uuid.UUID,
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	post.ID = uuid.NewV5(uuid.NamespaceURL, post.Link)
-	db.posts[post.ID] = post
-
-	return post.ID, nil
+	return db.addPost(post), nil
 }
 
 func (db *Store) AddPosts(ctx context.Context, posts []storage.Post) (err error) {
@@ -38,31 +45,239 @@ func (db *Store) AddPosts(ctx context.Context, posts []storage.Post) (err error)
 	defer db.mu.Unlock()
 
 	for _, post := range posts {
-		post.ID = uuid.NewV5(uuid.NamespaceURL, post.Link)
-		db.posts[post.ID] = post
+		db.addPost(post)
 	}
 
 	return
 }
 
-func (db *Store) LatestPosts(ctx context.Context, page, limit int) (posts []storage.Post, numPages int, err error) {
-	if limit <= 0 {
-		return []storage.Post{}, 0, nil
+func (db *Store) LatestPosts(ctx context.Context, filter storage.PostFilter, page, limit int) (posts []storage.Post, numPages int, err error) {
+	posts, numPages = db.listPosts(page, limit, func(p storage.Post) bool {
+		return matchFilter(p, filter)
+	}, nil)
+
+	return posts, numPages, nil
+}
+
+func (db *Store) FilterPosts(ctx context.Context, contains string, filter storage.PostFilter, page, limit int) (posts []storage.Post, numPages int, err error) {
+	if contains == "" {
+		return nil, 0, nil
 	}
 
+	contains = strings.ToLower(contains)
+	posts, numPages = db.listPosts(page, limit, func(p storage.Post) bool {
+		return matchFilter(p, filter) && strings.Contains(strings.ToLower(p.Title), contains)
+	}, nil)
+
+	return posts, numPages, nil
+}
+
+func (db *Store) LatestPostsAt(ctx context.Context, filter storage.PostFilter, cursor storage.Cursor, limit int) (page storage.PostsPage, err error) {
+	return db.postsAt(cursor, limit, func(p storage.Post) bool {
+		return matchFilter(p, filter)
+	}), nil
+}
+
+func (db *Store) FilterPostsAt(ctx context.Context, contains string, filter storage.PostFilter, cursor storage.Cursor, limit int) (page storage.PostsPage, err error) {
+	if contains == "" {
+		return storage.PostsPage{}, nil
+	}
+
+	contains = strings.ToLower(contains)
+	return db.postsAt(cursor, limit, func(p storage.Post) bool {
+		return matchFilter(p, filter) && strings.Contains(strings.ToLower(p.Title), contains)
+	}), nil
+}
+
+func (db *Store) Sources(ctx context.Context) (sources []storage.SourceStat, err error) {
 	db.mu.Lock()
-	allPosts := make([]storage.Post, 0, len(db.posts))
-	for _, v := range db.posts {
-		allPosts = append(allPosts, v)
+	counts := make(map[storage.Source]int)
+	for _, p := range db.posts {
+		counts[p.Source]++
 	}
 	db.mu.Unlock()
 
-	sort.Slice(allPosts, func(i, j int) bool {
-		return allPosts[i].Published.After(allPosts[j].Published)
+	sources = make([]storage.SourceStat, 0, len(counts))
+	for src, n := range counts {
+		sources = append(sources, storage.SourceStat{Source: src, Posts: n})
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Posts != sources[j].Posts {
+			return sources[i].Posts > sources[j].Posts
+		}
+		return sources[i].URL < sources[j].URL
 	})
 
+	return sources, nil
+}
+
+func (db *Store) ExpiredPosts(ctx context.Context, policy storage.Retention, limit int) (ids []uuid.UUID, err error) {
+	db.mu.Lock()
+	bySource := make(map[string][]storage.Post)
+	for _, p := range db.posts {
+		bySource[p.Source.URL] = append(bySource[p.Source.URL], p)
+	}
+	db.mu.Unlock()
+
+	var expired []storage.Post
+	for _, posts := range bySource {
+		sort.Slice(posts, func(i, j int) bool {
+			return posts[i].Published.After(posts[j].Published)
+		})
+		for i, p := range posts {
+			if slices.Contains(policy.Keep, p.ID) {
+				continue
+			}
+			tooOld := !policy.Before.IsZero() && p.Published.Before(policy.Before)
+			tooMany := policy.MaxPerSource > 0 && i >= policy.MaxPerSource
+			if tooOld || tooMany {
+				expired = append(expired, p)
+			}
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Published.Before(expired[j].Published)
+	})
+	for _, p := range expired[:min(max(limit, 0), len(expired))] {
+		ids = append(ids, p.ID)
+	}
+
+	return ids, nil
+}
+
+func (db *Store) DeletePosts(ctx context.Context, ids []uuid.UUID) (removed int, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := db.posts[id]; !ok {
+			continue
+		}
+		delete(db.posts, id)
+		delete(db.revisions, id)
+		delete(db.duplicates, id)
+		for alt, canonical := range db.duplicates {
+			if canonical == id {
+				delete(db.duplicates, alt)
+			}
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (db *Store) Post(ctx context.Context, id uuid.UUID) (post storage.Post, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	post, ok := db.posts[id]
+	if !ok {
+		return storage.Post{}, storage.ErrPostNotFound
+	}
+	post.Alternates = db.alternates(id)
+
+	return post, nil
+}
+
+func (db *Store) Revisions(ctx context.Context, id uuid.UUID) (revisions []storage.Revision, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.posts[id]; !ok {
+		return nil, storage.ErrPostNotFound
+	}
+
+	revisions = slices.Clone(db.revisions[id])
+	slices.Reverse(revisions)
+
+	return revisions, nil
+}
+
+func (db *Store) AddFeed(ctx context.Context, feed storage.Feed) (id uuid.UUID, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	feed.ID, err = uuid.NewV4()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if db.feedURLTaken(feed) {
+		return uuid.Nil, storage.ErrFeedExists
+	}
+	db.feeds[feed.ID] = feed
+
+	return feed.ID, nil
+}
+
+func (db *Store) Feeds(ctx context.Context) (feeds []storage.Feed, err error) {
+	db.mu.Lock()
+	feeds = make([]storage.Feed, 0, len(db.feeds))
+	for _, f := range db.feeds {
+		feeds = append(feeds, f)
+	}
+	db.mu.Unlock()
+
+	sort.Slice(feeds, func(i, j int) bool {
+		return feeds[i].URL < feeds[j].URL
+	})
+
+	return feeds, nil
+}
+
+func (db *Store) Feed(ctx context.Context, id uuid.UUID) (feed storage.Feed, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	feed, ok := db.feeds[id]
+	if !ok {
+		return storage.Feed{}, storage.ErrFeedNotFound
+	}
+
+	return feed, nil
+}
+
+func (db *Store) UpdateFeed(ctx context.Context, feed storage.Feed) (err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.feeds[feed.ID]; !ok {
+		return storage.ErrFeedNotFound
+	}
+	if db.feedURLTaken(feed) {
+		return storage.ErrFeedExists
+	}
+	db.feeds[feed.ID] = feed
+
+	return nil
+}
+
+func (db *Store) DeleteFeed(ctx context.Context, id uuid.UUID) (err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.feeds[id]; !ok {
+		return storage.ErrFeedNotFound
+	}
+	delete(db.feeds, id)
+
+	return nil
+}
+
+// listPosts returns the given page of the canonical posts that satisfy match
+// in descending order by date along with the total page count. If rank is set,
+// the posts are ordered by it in descending order first.
+func (db *Store) listPosts(page, limit int, match func(storage.Post) bool, rank func(storage.Post) float64) ([]storage.Post, int) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	allPosts := db.sortedPosts(match, rank)
+
 	totalPosts := len(allPosts)
-	numPages = (totalPosts + limit - 1) / limit
+	numPages := (totalPosts + limit - 1) / limit
 
 	pageIndex := page - 1
 	if pageIndex < 0 {
@@ -71,7 +286,7 @@ func (db *Store) LatestPosts(ctx context.Context, page, limit int) (posts []stor
 
 	start := pageIndex * limit
 	if start >= totalPosts {
-		return []storage.Post{}, numPages, nil
+		return []storage.Post{}, numPages
 	}
 
 	end := start + limit
@@ -79,21 +294,160 @@ func (db *Store) LatestPosts(ctx context.Context, page, limit int) (posts []stor
 		end = totalPosts
 	}
 
-	return allPosts[start:end], numPages, nil
+	return allPosts[start:end], numPages
 }
 
-func (db *Store) FilterPosts(ctx context.Context, contains string, page, limit int) (posts []storage.Post, numPages int, err error) {
-	return
+// postsAt returns the page of at most limit canonical posts that satisfy match
+// the cursor points to.
+func (db *Store) postsAt(cursor storage.Cursor, limit int, match func(storage.Post) bool) storage.PostsPage {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	allPosts := db.sortedPosts(match, nil)
+	key := storage.Post{Published: cursor.Published, ID: cursor.ID}
+
+	var fetched []storage.Post
+	switch {
+	case cursor.IsZero():
+		fetched = allPosts[:min(limit+1, len(allPosts))]
+	case cursor.Backward:
+		end := sort.Search(len(allPosts), func(i int) bool { return !newer(allPosts[i], key) })
+		fetched = allPosts[max(end-limit-1, 0):end]
+		slices.Reverse(fetched)
+	default:
+		start := sort.Search(len(allPosts), func(i int) bool { return newer(key, allPosts[i]) })
+		fetched = allPosts[start:min(start+limit+1, len(allPosts))]
+	}
+
+	return storage.CursorPage(fetched, cursor, limit)
 }
 
-func (db *Store) Post(ctx context.Context, id uuid.UUID) (post storage.Post, err error) {
+// sortedPosts returns the canonical posts that satisfy match in descending
+// order by date or, if rank is set, by rank first.
+func (db *Store) sortedPosts(match func(storage.Post) bool, rank func(storage.Post) float64) []storage.Post {
 	db.mu.Lock()
-	defer db.mu.Unlock()
+	allPosts := make([]storage.Post, 0, len(db.posts))
+	for id, v := range db.posts {
+		if _, dup := db.duplicates[id]; !dup && match(v) {
+			allPosts = append(allPosts, v)
+		}
+	}
+	db.mu.Unlock()
 
-	post, ok := db.posts[id]
+	sort.Slice(allPosts, func(i, j int) bool {
+		if rank != nil {
+			if ri, rj := rank(allPosts[i]), rank(allPosts[j]); ri != rj {
+				return ri > rj
+			}
+		}
+		return newer(allPosts[i], allPosts[j])
+	})
+
+	return allPosts
+}
+
+// addPost stores the post and returns its ID. A new post that duplicates
+// a canonical post is recorded as its alternate, a change of a stored post
+// is recorded as its revision. Must be called with db.mu held.
+func (db *Store) addPost(post storage.Post) uuid.UUID {
+	post.ID = uuid.NewV5(uuid.NamespaceURL, post.Link)
+	post.Alternates = nil
+
+	if old, ok := db.posts[post.ID]; ok {
+		post.Published = old.Published
+		if changes := diff(old, post); len(changes) > 0 {
+			db.revisions[post.ID] = append(db.revisions[post.ID], storage.Revision{
+				Changed: time.Now().UTC(),
+				Source:  post.Source,
+				Changes: changes,
+			})
+		}
+	} else if canonical, ok := db.canonicalOf(post); ok {
+		db.duplicates[post.ID] = canonical
+	}
+	db.posts[post.ID] = post
+
+	return post.ID
+}
+
+// diff returns the changes of the title and the content of a post.
+func diff(old, new storage.Post) []storage.Change {
+	var changes []storage.Change
+	if old.Title != new.Title {
+		changes = append(changes, storage.Change{Field: "title", Old: old.Title, New: new.Title})
+	}
+	if old.Content != new.Content {
+		changes = append(changes, storage.Change{Field: "content", Old: old.Content, New: new.Content})
+	}
+	return changes
+}
+
+// canonicalOf returns the ID of the earliest canonical post the given post duplicates.
+// Must be called with db.mu held.
+func (db *Store) canonicalOf(post storage.Post) (uuid.UUID, bool) {
+	link := dedup.CanonicalLink(post.Link)
+	fp := dedup.Fingerprint(post.Title, post.Summary)
+
+	var match *storage.Post
+	for id, p := range db.posts {
+		if _, dup := db.duplicates[id]; dup || id == post.ID {
+			continue
+		}
+		if match != nil && !p.Published.Before(match.Published) {
+			continue
+		}
+
+		sameLink := dedup.CanonicalLink(p.Link) == link
+		similar := absDuration(p.Published.Sub(post.Published)) <= dedup.Window &&
+			dedup.Similar(fp, dedup.Fingerprint(p.Title, p.Summary))
+		if sameLink || similar {
+			match = &p
+		}
+	}
+	if match == nil {
+		return uuid.Nil, false
+	}
+
+	return match.ID, true
+}
+
+// alternates returns the other posts of the group the post belongs to, ordered by
+// published date. Must be called with db.mu held.
+func (db *Store) alternates(id uuid.UUID) []storage.Alternate {
+	canonical, ok := db.duplicates[id]
 	if !ok {
-		return storage.Post{}, storage.ErrPostNotFound
+		canonical = id
 	}
 
-	return post, nil
+	var group []storage.Post
+	for pid, p := range db.posts {
+		if pid == id {
+			continue
+		}
+		if pid == canonical || db.duplicates[pid] == canonical {
+			group = append(group, p)
+		}
+	}
+	sort.Slice(group, func(i, j int) bool {
+		return group[i].Published.Before(group[j].Published)
+	})
+
+	var alternates []storage.Alternate
+	for _, p := range group {
+		alternates = append(alternates, storage.Alternate{ID: p.ID, Link: p.Link, Source: p.Source})
+	}
+
+	return alternates
+}
+
+// feedURLTaken reports whether a feed other than the given one has the same URL.
+// Must be called with db.mu held.
+func (db *Store) feedURLTaken(feed storage.Feed) bool {
+	for id, f := range db.feeds {
+		if id != feed.ID && f.URL == feed.URL {
+			return true
+		}
+	}
+	return false
 }
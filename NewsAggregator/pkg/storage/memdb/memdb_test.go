@@ -10,6 +10,7 @@ import (
 	"github.com/gofrs/uuid"
 
 	"news/pkg/storage"
+	"news/pkg/storage/storagetest"
 )
 
 const testPostsPath = "../../../test_data/post_examples.json"
@@ -167,11 +168,11 @@ func TestDB_LatestPosts(t *testing.T) {
 			wantNumPages: 1,
 		},
 		{
-			name:         "Zero items per page (should handle gracefully)",
+			name:         "Zero items per page (default of 10)",
 			currentPage:  1,
 			limit:        0,
-			wantTitles:   []string{},
-			wantNumPages: 0,
+			wantTitles:   []string{"Seventh Post", "Sixth Post", "Fifth Post", "Fourth Post", "Third Post", "Second Post", "First Post"},
+			wantNumPages: 1,
 		},
 		{
 			name:         "Negative page number (should treat as first page)",
@@ -184,7 +185,7 @@ func TestDB_LatestPosts(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			posts, pagesNum, err := db.LatestPosts(context.Background(), tt.currentPage, tt.limit)
+			posts, pagesNum, err := db.LatestPosts(context.Background(), storage.PostFilter{}, tt.currentPage, tt.limit)
 			if err != nil {
 				t.Fatalf("LatestPosts returned error: %v", err)
 			}
@@ -203,3 +204,47 @@ func TestDB_LatestPosts(t *testing.T) {
 		})
 	}
 }
+
+func TestDB_Sources(t *testing.T) {
+	db := New()
+
+	habr := storage.Source{URL: "https://habr.com/rss", Name: "Habr"}
+	weekly := storage.Source{URL: "https://golangweekly.com/rss", Name: "Golang Weekly"}
+	testPosts := []storage.Post{
+		{Title: "First Post", Published: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Link: "https://habr.com/1", Source: habr},
+		{Title: "Second Post", Published: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Link: "https://habr.com/2", Source: habr},
+		{Title: "Third Post", Published: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), Link: "https://golangweekly.com/3", Source: weekly},
+	}
+	if err := db.AddPosts(context.Background(), testPosts); err != nil {
+		t.Fatalf("unexpected error while adding posts: %v", err)
+	}
+
+	sources, err := db.Sources(context.Background())
+	if err != nil {
+		t.Fatalf("Sources returned error: %v", err)
+	}
+	wantSources := []storage.SourceStat{
+		{Source: habr, Posts: 2},
+		{Source: weekly, Posts: 1},
+	}
+	if !reflect.DeepEqual(sources, wantSources) {
+		t.Errorf("want sources %+v, got %+v", wantSources, sources)
+	}
+
+	posts, numPages, err := db.LatestPosts(context.Background(), storage.PostFilter{Source: habr.URL}, 1, 10)
+	if err != nil {
+		t.Fatalf("LatestPosts returned error: %v", err)
+	}
+	if numPages != 1 {
+		t.Errorf("want pagesNum 1, got %d", numPages)
+	}
+	if len(posts) != 2 || posts[0].Title != "Second Post" || posts[1].Title != "First Post" {
+		t.Errorf("want posts of %s only, got %+v", habr.URL, posts)
+	}
+}
+
+func TestDB_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New()
+	})
+}
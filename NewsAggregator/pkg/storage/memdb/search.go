@@ -0,0 +1,172 @@
+package memdb
+
+import (
+	"context"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/gofrs/uuid"
+
+	"news/pkg/sanitize"
+	"news/pkg/storage"
+)
+
+const (
+	titleWeight   = 1.0 // Rank of a title match.
+	contentWeight = 0.4 // Rank of a content match.
+
+	snippetWords  = 35 // Words in a snippet.
+	snippetBefore = 5  // Words before the first match in a snippet.
+)
+
+// SearchPosts finds the posts as the full-text search of the database does,
+// but the words are compared as they are, without stemming. The rank of a post
+// is the number of the query matches weighted by the field they are found in.
+func (db *Store) SearchPosts(ctx context.Context, query string, filter storage.PostFilter, page, limit int) (results []storage.SearchResult, numPages int, err error) {
+	q := storage.ParseSearchQuery(query)
+	if len(q) == 0 {
+		return nil, 0, nil
+	}
+
+	// The ranks are found along with the matches, listPosts sorts the posts by them.
+	ranks := make(map[uuid.UUID]float64)
+	posts, numPages := db.listPosts(page, limit, func(p storage.Post) bool {
+		if !matchFilter(p, filter) {
+			return false
+		}
+		rank, ok := searchRank(p, q)
+		ranks[p.ID] = rank
+		return ok
+	}, func(p storage.Post) float64 {
+		return ranks[p.ID]
+	})
+
+	results = make([]storage.SearchResult, 0, len(posts))
+	for _, p := range posts {
+		results = append(results, storage.SearchResult{
+			Post:    p,
+			Rank:    ranks[p.ID],
+			Snippet: snippet(sanitize.Text(p.Content), q),
+		})
+	}
+
+	return results, numPages, nil
+}
+
+// searchRank reports whether the post matches the query and returns its rank.
+func searchRank(p storage.Post, q storage.SearchQuery) (float64, bool) {
+	title := storage.SearchWords(p.Title)
+	content := storage.SearchWords(sanitize.Text(p.Content))
+
+	var rank float64
+	var found bool
+	for _, group := range q {
+		matched := true
+		for _, term := range group {
+			inTitle, inContent := countTerm(title, term), countTerm(content, term)
+			if term.Exclude {
+				matched = matched && inTitle+inContent == 0
+				continue
+			}
+			matched = matched && inTitle+inContent > 0
+			rank += titleWeight*float64(inTitle) + contentWeight*float64(inContent)
+		}
+		found = found || matched
+	}
+
+	return rank, found
+}
+
+// countTerm returns the number of the term occurrences in the words.
+func countTerm(words []string, term storage.SearchTerm) int {
+	n := 0
+	for i := 0; i+len(term.Words) <= len(words); i++ {
+		if matchTerm(words[i:], term) {
+			n++
+		}
+	}
+	return n
+}
+
+// matchTerm reports whether the words begin with the term.
+func matchTerm(words []string, term storage.SearchTerm) bool {
+	for j, w := range term.Words {
+		if term.Prefix && j == len(term.Words)-1 {
+			if !strings.HasPrefix(words[j], w) {
+				return false
+			}
+		} else if words[j] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// snippet returns a fragment of the text around the first query match with
+// the words of the matched terms in <b> tags, the beginning of the text if
+// nothing matches. The text is escaped for HTML.
+func snippet(text string, q storage.SearchQuery) string {
+	// The text is split into words and the runs of other characters between them.
+	var parts []string
+	var words []int // Indexes of the words in parts.
+	var lower []string
+	start, inWord := 0, false
+	split := func(end int) {
+		if end > start {
+			if inWord {
+				words = append(words, len(parts))
+				lower = append(lower, strings.ToLower(text[start:end]))
+			}
+			parts = append(parts, text[start:end])
+		}
+		start = end
+	}
+	for i, r := range text {
+		if isWordRune(r) != inWord {
+			split(i)
+			inWord = !inWord
+		}
+	}
+	split(len(text))
+
+	marked := make([]bool, len(words))
+	for _, group := range q {
+		for _, term := range group {
+			if term.Exclude {
+				continue
+			}
+			for i := 0; i+len(term.Words) <= len(lower); i++ {
+				if matchTerm(lower[i:], term) {
+					for j := i; j < i+len(term.Words); j++ {
+						marked[j] = true
+					}
+				}
+			}
+		}
+	}
+
+	first := max(slices.Index(marked, true)-snippetBefore, 0)
+	last := min(first+snippetWords, len(words)) - 1
+	if last < first {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := words[first]; i <= words[last]; i++ {
+		w, isWord := slices.BinarySearch(words, i)
+		if isWord && marked[w] {
+			b.WriteString("<b>" + html.EscapeString(parts[i]) + "</b>")
+			continue
+		}
+		b.WriteString(html.EscapeString(parts[i]))
+	}
+
+	return b.String()
+}
+
+// isWordRune reports whether the rune belongs to a word, see storage.SearchWords.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -1,10 +1,12 @@
 package memdb
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"sort"
+	"time"
 
 	"github.com/gofrs/uuid"
 
@@ -37,3 +39,30 @@ func LoadTestPosts(path string) ([]storage.Post, error) {
 
 	return posts, nil
 }
+
+// matchFilter reports whether the post satisfies every set field of the filter.
+func matchFilter(p storage.Post, filter storage.PostFilter) bool {
+	if filter.Source != "" && p.Source.URL != filter.Source {
+		return false
+	}
+	if filter.Lang != "" && p.Lang != filter.Lang {
+		return false
+	}
+	return true
+}
+
+// newer reports whether post a precedes post b in the lists of posts: by
+// published date, newest first, and by ID in descending order within the same date.
+func newer(a, b storage.Post) bool {
+	if !a.Published.Equal(b.Published) {
+		return a.Published.After(b.Published)
+	}
+	return bytes.Compare(a.ID.Bytes(), b.ID.Bytes()) > 0
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"news/pkg/storage"
+)
+
+// LatestPostsAt returns the page of the posts matching the filter the cursor
+// points to. The page is found by the index on (published, id) without counting
+// the posts. If limit is less than or equal to zero, it defaults to 10.
+func (s *Store) LatestPostsAt(ctx context.Context, filter storage.PostFilter, cursor storage.Cursor, limit int) (storage.PostsPage, error) {
+	return s.postsAt(ctx, `($1 = '' OR source_url = $1) AND ($2 = '' OR lang = $2)`,
+		[]any{filter.Source, filter.Lang}, cursor, limit)
+}
+
+// FilterPostsAt returns the page of the posts matching the filter whose titles
+// contain the given substring the cursor points to. If the substring is empty,
+// it returns an empty page without error.
+func (s *Store) FilterPostsAt(ctx context.Context, contains string, filter storage.PostFilter, cursor storage.Cursor, limit int) (storage.PostsPage, error) {
+	if contains == "" {
+		return storage.PostsPage{}, nil
+	}
+
+	return s.postsAt(ctx, `title ILIKE $1 AND ($2 = '' OR source_url = $2) AND ($3 = '' OR lang = $3)`,
+		[]any{"%" + contains + "%", filter.Source, filter.Lang}, cursor, limit)
+}
+
+// postsAt returns the page of the canonical posts satisfying cond the cursor
+// points to. The condition refers to args as $1, $2 and so on.
+func (s *Store) postsAt(ctx context.Context, cond string, args []any, cursor storage.Cursor, limit int) (storage.PostsPage, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	// A backward page is read in the reverse order from the cursor on.
+	cmp, order := "<", "DESC"
+	if cursor.Backward {
+		cmp, order = ">", "ASC"
+	}
+	var published, id any
+	if !cursor.IsZero() {
+		published, id = cursor.Published, cursor.ID
+	}
+
+	n := len(args)
+	rows, err := s.db.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM posts
+		WHERE duplicate_of IS NULL AND %s
+			AND ($%d::timestamptz IS NULL OR (published, id) %s ($%d, $%d::uuid))
+		ORDER BY published %s, id %s
+		LIMIT $%d
+	`, postColumns, cond, n+1, cmp, n+1, n+2, order, order, n+3),
+		append(args, published, id, limit+1)...,
+	)
+	if err != nil {
+		return storage.PostsPage{}, err
+	}
+	defer rows.Close()
+
+	var posts []storage.Post
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return storage.PostsPage{}, err
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.PostsPage{}, err
+	}
+
+	return storage.CursorPage(posts, cursor, limit), nil
+}
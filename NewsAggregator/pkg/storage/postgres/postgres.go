@@ -3,14 +3,61 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 
+	"news/pkg/dedup"
 	"news/pkg/storage"
 )
 
+// uniqueViolationCode is the SQLSTATE of the unique_violation error.
+const uniqueViolationCode = "23505"
+
+// postColumns lists the posts columns in the order scanPost reads them.
+const postColumns = `id, title, content, summary, published, link, source_url, source_name,
+	authors, categories, image_url, enclosures, lang`
+
+// feedColumns lists the feeds columns in the order scanFeed reads them.
+const feedColumns = `id, url, name, category, poll_interval, enabled, user_agent, request_timeout, full_text, rules, scraper`
+
+// upsertPost inserts a post or updates the stored one with the same ID.
+// A new post is linked to the earliest canonical post with the same canonical
+// link or a similar fingerprint published close to it, see upsertArgs.
+// The link and the publication date of an existing post are left as is.
+const upsertPost = `
+	INSERT INTO posts (` + postColumns + `, canonical_link, fingerprint, duplicate_of)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, (
+		SELECT id FROM posts
+		WHERE duplicate_of IS NULL AND id <> $1 AND (
+			canonical_link = $14 OR (
+				$15 <> 0 AND fingerprint <> 0
+				AND published BETWEEN $16 AND $17
+				AND length(replace((fingerprint # $15)::bit(64)::text, '0', '')) <= $18
+			)
+		)
+		ORDER BY published
+		LIMIT 1
+	))
+	ON CONFLICT (id)
+	DO UPDATE SET
+		title = EXCLUDED.title,
+		content = EXCLUDED.content,
+		summary = EXCLUDED.summary,
+		link = EXCLUDED.link,
+		source_url = EXCLUDED.source_url,
+		source_name = EXCLUDED.source_name,
+		authors = EXCLUDED.authors,
+		categories = EXCLUDED.categories,
+		image_url = EXCLUDED.image_url,
+		enclosures = EXCLUDED.enclosures,
+		lang = EXCLUDED.lang,
+		canonical_link = EXCLUDED.canonical_link,
+		fingerprint = EXCLUDED.fingerprint`
+
 type Store struct {
 	db *pgxpool.Pool
 }
@@ -35,38 +82,58 @@ func (s *Store) Close() {
 	s.db.Close()
 }
 
+// recordRevision stores the changes of the title and the content of the post
+// about to be updated, if there are any. It must run before upsertPost in the
+// same transaction, so it still sees the old values.
+const recordRevision = `
+	INSERT INTO post_revisions (post_id, source_url, source_name, changes)
+	SELECT id, $4, $5, changes
+	FROM (
+		SELECT id, (
+			SELECT jsonb_agg(jsonb_build_object('field', f.field, 'old', f.old, 'new', f.new) ORDER BY f.n)
+			FROM (VALUES (1, 'title', title, $2::text), (2, 'content', content, $3::text)) AS f (n, field, old, new)
+			WHERE f.old IS DISTINCT FROM f.new
+		) AS changes
+		FROM posts
+		WHERE id = $1
+	) AS diff
+	WHERE changes IS NOT NULL
+`
+
+// revisionArgs returns the values recordRevision compares and stores.
+func revisionArgs(p storage.Post) []any {
+	return []any{p.ID, p.Title, p.Content, p.Source.URL, p.Source.Name}
+}
+
 // AddPost inserts a single post into the database or updates it if a post with the same ID already exists.
 // The post ID is generated as a UUIDv5 based on the post's Link.
+// A change of the title or the content of an existing post is recorded as its revision.
 // The method returns the ID of the inserted or updated post and an error if any occurs.
 func (s *Store) AddPost(ctx context.Context, post storage.Post) (id uuid.UUID, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback(ctx)
+
 	post.ID = uuid.NewV5(uuid.NamespaceURL, post.Link)
-	err = s.db.QueryRow(ctx, `
-		INSERT INTO posts (id, title, content, published, link)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (id)
-		DO UPDATE SET
-			title = EXCLUDED.title,
-			content = EXCLUDED.content,
-			published = EXCLUDED.published,
-			link = EXCLUDED.link
-		RETURNING id
-	`,
-		post.ID,
-		post.Title,
-		post.Content,
-		post.Published,
-		post.Link,
-	).Scan(&id)
+	_, err = tx.Exec(ctx, recordRevision, revisionArgs(post)...)
+	if err != nil {
+		return
+	}
+	err = tx.QueryRow(ctx, upsertPost+" RETURNING id", upsertArgs(post)...).Scan(&id)
 	if err != nil {
 		return
 	}
 
+	err = tx.Commit(ctx)
 	return
 }
 
 // AddPosts inserts or updates a batch of posts in the database within a single transaction.
 // For each post, it generates a UUIDv5 based on the post's Link to use as the ID.
-// If a post with the same ID already exists, it updates the existing record with the new data.
+// If a post with the same ID already exists, it updates the existing record with the new data
+// and records the change of its title or content as a revision.
 // Returns an error if beginning the transaction, executing the batch, or committing fails.
 func (s *Store) AddPosts(ctx context.Context, posts []storage.Post) (err error) {
 	tx, err := s.db.Begin(ctx)
@@ -78,22 +145,8 @@ func (s *Store) AddPosts(ctx context.Context, posts []storage.Post) (err error)
 	batch := new(pgx.Batch)
 	for _, post := range posts {
 		post.ID = uuid.NewV5(uuid.NamespaceURL, post.Link)
-		batch.Queue(`
-			INSERT INTO posts (id, title, content, published, link)
-			VALUES ($1, $2, $3, $4, $5)
-			ON CONFLICT (id)
-			DO UPDATE SET
-				title = EXCLUDED.title,
-				content = EXCLUDED.content,
-				published = EXCLUDED.published,
-				link = EXCLUDED.link
-		`,
-			post.ID,
-			post.Title,
-			post.Content,
-			post.Published,
-			post.Link,
-		)
+		batch.Queue(recordRevision, revisionArgs(post)...)
+		batch.Queue(upsertPost, upsertArgs(post)...)
 	}
 
 	res := tx.SendBatch(ctx, batch)
@@ -105,12 +158,12 @@ func (s *Store) AddPosts(ctx context.Context, posts []storage.Post) (err error)
 	return tx.Commit(ctx)
 }
 
-// LatestPosts returns a paginated list of posts ordered by published date descending.
+// LatestPosts returns a paginated list of posts matching the filter ordered by published date descending.
 // It accepts the page number and the number of items per page as parameters.
 // If page or limit are less than or equal to zero, they default to 1 and 10 respectively.
 // The method returns the posts for the requested page, the total number of pages available,
 // and any error encountered during the database queries.
-func (s *Store) LatestPosts(ctx context.Context, page, limit int) (posts []storage.Post, numPages int, err error) {
+func (s *Store) LatestPosts(ctx context.Context, filter storage.PostFilter, page, limit int) (posts []storage.Post, numPages int, err error) {
 	if limit <= 0 {
 		limit = 10
 	}
@@ -121,12 +174,15 @@ func (s *Store) LatestPosts(ctx context.Context, page, limit int) (posts []stora
 	offset := (page - 1) * limit
 
 	rows, err := s.db.Query(ctx, `
-        SELECT id, title, content, published, link
+        SELECT `+postColumns+`
         FROM posts
-        ORDER BY published DESC
+        WHERE duplicate_of IS NULL AND ($3 = '' OR source_url = $3) AND ($4 = '' OR lang = $4)
+        ORDER BY published DESC, id DESC
         LIMIT $1 OFFSET $2
     `, limit,
 		offset,
+		filter.Source,
+		filter.Lang,
 	)
 	if err != nil {
 		return nil, 0, err
@@ -134,17 +190,10 @@ func (s *Store) LatestPosts(ctx context.Context, page, limit int) (posts []stora
 	defer rows.Close()
 
 	for rows.Next() {
-		var p storage.Post
-		err := rows.Scan(
-			&p.ID,
-			&p.Title,
-			&p.Content,
-			&p.Published,
-			&p.Link)
+		p, err := scanPost(rows)
 		if err != nil {
 			return nil, 0, err
 		}
-		p.Published = p.Published.UTC()
 		posts = append(posts, p)
 	}
 	if err := rows.Err(); err != nil {
@@ -152,7 +201,12 @@ func (s *Store) LatestPosts(ctx context.Context, page, limit int) (posts []stora
 	}
 
 	var totalPosts int
-	err = s.db.QueryRow(ctx, `SELECT COUNT(id) FROM posts`).Scan(&totalPosts)
+	err = s.db.QueryRow(ctx, `
+	SELECT COUNT(id) FROM posts WHERE duplicate_of IS NULL AND ($1 = '' OR source_url = $1) AND ($2 = '' OR lang = $2)
+	`,
+		filter.Source,
+		filter.Lang,
+	).Scan(&totalPosts)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -161,12 +215,12 @@ func (s *Store) LatestPosts(ctx context.Context, page, limit int) (posts []stora
 	return
 }
 
-// FilterPosts returns a paginated list of posts whose titles contain the given substring.
+// FilterPosts returns a paginated list of posts matching the filter whose titles contain the given substring.
 // If the substring is empty, it returns an empty list without error.
 // It returns the list of matching posts for the specified page and limit,
 // along with the total number of pages available for the given filter and page size.
 // Returns an error if any occurs.
-func (s *Store) FilterPosts(ctx context.Context, contains string, page, limit int) ([]storage.Post, int, error) {
+func (s *Store) FilterPosts(ctx context.Context, contains string, filter storage.PostFilter, page, limit int) ([]storage.Post, int, error) {
 	if contains == "" {
 		return nil, 0, nil
 	}
@@ -180,14 +234,17 @@ func (s *Store) FilterPosts(ctx context.Context, contains string, page, limit in
 	offset := (page - 1) * limit
 
 	rows, err := s.db.Query(ctx, `
-		SELECT id, title, content, published, link
+		SELECT `+postColumns+`
 		FROM posts
-		WHERE title ILIKE $1
+		WHERE duplicate_of IS NULL AND title ILIKE $1 AND ($4 = '' OR source_url = $4) AND ($5 = '' OR lang = $5)
+		ORDER BY published DESC, id DESC
 		LIMIT $2 OFFSET $3
 	`,
 		"%"+contains+"%",
 		limit,
 		offset,
+		filter.Source,
+		filter.Lang,
 	)
 	if err != nil {
 		return nil, 0, err
@@ -196,17 +253,10 @@ func (s *Store) FilterPosts(ctx context.Context, contains string, page, limit in
 
 	var posts []storage.Post
 	for rows.Next() {
-		var p storage.Post
-		err := rows.Scan(
-			&p.ID,
-			&p.Title,
-			&p.Content,
-			&p.Published,
-			&p.Link)
+		p, err := scanPost(rows)
 		if err != nil {
 			return nil, 0, err
 		}
-		p.Published = p.Published.UTC()
 		posts = append(posts, p)
 	}
 	if err := rows.Err(); err != nil {
@@ -215,9 +265,11 @@ func (s *Store) FilterPosts(ctx context.Context, contains string, page, limit in
 
 	var totalPosts int
 	err = s.db.QueryRow(ctx, `
-	SELECT COUNT(id) FROM posts WHERE title ILIKE $1
+	SELECT COUNT(id) FROM posts WHERE duplicate_of IS NULL AND title ILIKE $1 AND ($2 = '' OR source_url = $2) AND ($3 = '' OR lang = $3)
 	`,
 		"%"+contains+"%",
+		filter.Source,
+		filter.Lang,
 	).Scan(&totalPosts)
 	if err != nil {
 		return nil, 0, err
@@ -227,21 +279,16 @@ func (s *Store) FilterPosts(ctx context.Context, contains string, page, limit in
 	return posts, numPages, nil
 }
 
-// Post retrieves a post by its ID. It returns the post and an error if any occurs.
+// Post retrieves a post by its ID along with the other copies of the story as alternates.
+// It returns the post and an error if any occurs.
 func (s *Store) Post(ctx context.Context, id uuid.UUID) (post storage.Post, err error) {
-	err = s.db.QueryRow(ctx, `
-		SELECT id, title, content, published, link
+	post, err = scanPost(s.db.QueryRow(ctx, `
+		SELECT `+postColumns+`
 		FROM posts
 		WHERE id = $1
 	`,
 		id,
-	).Scan(
-		&post.ID,
-		&post.Title,
-		&post.Content,
-		&post.Published,
-		&post.Link,
-	)
+	))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			err = storage.ErrPostNotFound
@@ -249,6 +296,391 @@ func (s *Store) Post(ctx context.Context, id uuid.UUID) (post storage.Post, err
 		return
 	}
 
-	post.Published = post.Published.UTC()
+	post.Alternates, err = s.alternates(ctx, id)
 	return
 }
+
+// ExpiredPosts returns the IDs of at most limit posts the retention policy expires,
+// oldest first: the posts published before policy.Before and the posts of each source
+// beyond the policy.MaxPerSource latest ones. Posts in policy.Keep never expire.
+func (s *Store) ExpiredPosts(ctx context.Context, policy storage.Retention, limit int) ([]uuid.UUID, error) {
+	var before *time.Time
+	if !policy.Before.IsZero() {
+		before = &policy.Before
+	}
+	keep := make([]string, 0, len(policy.Keep))
+	for _, id := range policy.Keep {
+		keep = append(keep, id.String())
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id
+		FROM (
+			SELECT id, published,
+				row_number() OVER (PARTITION BY source_url ORDER BY published DESC, id) AS rank
+			FROM posts
+		) AS ranked
+		WHERE (published < $1 OR ($2 > 0 AND rank > $2))
+			AND id <> ALL($3::uuid[])
+		ORDER BY published
+		LIMIT $4
+	`,
+		before,
+		policy.MaxPerSource,
+		keep,
+		max(limit, 0),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// DeletePosts removes the posts with the given IDs and returns the number of removed posts.
+// Revisions of the posts are removed with them and their alternates become canonical posts.
+func (s *Store) DeletePosts(ctx context.Context, ids []uuid.UUID) (int, error) {
+	strIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strIDs = append(strIDs, id.String())
+	}
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM posts WHERE id = ANY($1::uuid[])`, strIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// Revisions returns the changes of the post made by feed updates, newest first.
+// Returns storage.ErrPostNotFound if there is no such post.
+func (s *Store) Revisions(ctx context.Context, id uuid.UUID) ([]storage.Revision, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT changed_at, source_url, source_name, changes
+		FROM post_revisions
+		WHERE post_id = $1
+		ORDER BY changed_at DESC, id DESC
+	`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []storage.Revision{}
+	for rows.Next() {
+		var r storage.Revision
+		if err := rows.Scan(&r.Changed, &r.Source.URL, &r.Source.Name, &r.Changes); err != nil {
+			return nil, err
+		}
+		r.Changed = r.Changed.UTC()
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		var exists bool
+		err := s.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, storage.ErrPostNotFound
+		}
+	}
+
+	return revisions, nil
+}
+
+// alternates returns the other posts of the group the post belongs to, ordered by published date.
+func (s *Store) alternates(ctx context.Context, id uuid.UUID) ([]storage.Alternate, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT p.id, p.link, p.source_url, p.source_name
+		FROM posts p, (SELECT COALESCE(duplicate_of, id) AS id FROM posts WHERE id = $1) c
+		WHERE p.id <> $1 AND (p.id = c.id OR p.duplicate_of = c.id)
+		ORDER BY p.published
+	`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alternates []storage.Alternate
+	for rows.Next() {
+		var a storage.Alternate
+		err := rows.Scan(
+			&a.ID,
+			&a.Link,
+			&a.Source.URL,
+			&a.Source.Name)
+		if err != nil {
+			return nil, err
+		}
+		alternates = append(alternates, a)
+	}
+
+	return alternates, rows.Err()
+}
+
+// postArgs returns the values of the post in the order of postColumns.
+func postArgs(p storage.Post) []any {
+	return []any{
+		p.ID,
+		p.Title,
+		p.Content,
+		p.Summary,
+		p.Published,
+		p.Link,
+		p.Source.URL,
+		p.Source.Name,
+		p.Authors,
+		p.Categories,
+		p.ImageURL,
+		p.Enclosures,
+		p.Lang,
+	}
+}
+
+// upsertArgs returns the values of the post for upsertPost: the postColumns values
+// followed by the deduplication ones.
+func upsertArgs(p storage.Post) []any {
+	return append(postArgs(p),
+		dedup.CanonicalLink(p.Link),
+		int64(dedup.Fingerprint(p.Title, p.Summary)),
+		p.Published.Add(-dedup.Window),
+		p.Published.Add(dedup.Window),
+		dedup.MaxDistance,
+	)
+}
+
+// scanPost reads a row selected with postColumns into a post. The columns
+// selected after them are read into extra.
+func scanPost(row pgx.Row, extra ...any) (storage.Post, error) {
+	var p storage.Post
+	dest := []any{
+		&p.ID,
+		&p.Title,
+		&p.Content,
+		&p.Summary,
+		&p.Published,
+		&p.Link,
+		&p.Source.URL,
+		&p.Source.Name,
+		&p.Authors,
+		&p.Categories,
+		&p.ImageURL,
+		&p.Enclosures,
+		&p.Lang,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return storage.Post{}, err
+	}
+	p.Published = p.Published.UTC()
+
+	return p, nil
+}
+
+// Sources returns every source that has stored posts along with its post count,
+// ordered by post count descending.
+func (s *Store) Sources(ctx context.Context) ([]storage.SourceStat, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT source_url, source_name, COUNT(id)
+		FROM posts
+		GROUP BY source_url, source_name
+		ORDER BY COUNT(id) DESC, source_url
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []storage.SourceStat
+	for rows.Next() {
+		var src storage.SourceStat
+		err := rows.Scan(
+			&src.URL,
+			&src.Name,
+			&src.Posts)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// AddFeed inserts a feed into the database. The feed gets a random UUIDv4, so its ID doesn't
+// depend on the URL, which UpdateFeed may change.
+// Returns the ID of the inserted feed, storage.ErrFeedExists if a feed with the same URL
+// is already stored, or any other error that occurs.
+func (s *Store) AddFeed(ctx context.Context, feed storage.Feed) (id uuid.UUID, err error) {
+	feed.ID, err = uuid.NewV4()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO feeds (`+feedColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`,
+		feedArgs(feed)...,
+	).Scan(&id)
+	if isUniqueViolation(err) {
+		err = storage.ErrFeedExists
+	}
+
+	return
+}
+
+// Feeds returns all the stored feeds ordered by URL.
+func (s *Store) Feeds(ctx context.Context) ([]storage.Feed, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+feedColumns+`
+		FROM feeds
+		ORDER BY url
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feeds []storage.Feed
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return feeds, nil
+}
+
+// Feed retrieves a feed by its ID. It returns the feed and storage.ErrFeedNotFound
+// if there is no such feed, or any other error that occurs.
+func (s *Store) Feed(ctx context.Context, id uuid.UUID) (feed storage.Feed, err error) {
+	feed, err = scanFeed(s.db.QueryRow(ctx, `
+		SELECT `+feedColumns+`
+		FROM feeds
+		WHERE id = $1
+	`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = storage.ErrFeedNotFound
+	}
+
+	return
+}
+
+// UpdateFeed replaces the stored feed with the same ID. Returns storage.ErrFeedNotFound
+// if there is no such feed and storage.ErrFeedExists if another feed has the same URL.
+func (s *Store) UpdateFeed(ctx context.Context, feed storage.Feed) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE feeds SET
+			url = $2,
+			name = $3,
+			category = $4,
+			poll_interval = $5,
+			enabled = $6,
+			user_agent = $7,
+			request_timeout = $8,
+			full_text = $9,
+			rules = $10,
+			scraper = $11
+		WHERE id = $1
+	`,
+		feedArgs(feed)...,
+	)
+	if isUniqueViolation(err) {
+		return storage.ErrFeedExists
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrFeedNotFound
+	}
+
+	return nil
+}
+
+// feedArgs returns the values of the feed in the order of feedColumns.
+func feedArgs(f storage.Feed) []any {
+	return []any{
+		f.ID,
+		f.URL,
+		f.Name,
+		f.Category,
+		f.Interval,
+		f.Enabled,
+		f.UserAgent,
+		f.Timeout,
+		f.FullText,
+		f.Rules,
+		f.Scraper,
+	}
+}
+
+// scanFeed reads a row selected with feedColumns into a feed.
+func scanFeed(row pgx.Row) (storage.Feed, error) {
+	var f storage.Feed
+	err := row.Scan(
+		&f.ID,
+		&f.URL,
+		&f.Name,
+		&f.Category,
+		&f.Interval,
+		&f.Enabled,
+		&f.UserAgent,
+		&f.Timeout,
+		&f.FullText,
+		&f.Rules,
+		&f.Scraper,
+	)
+
+	return f, err
+}
+
+// DeleteFeed removes a feed by its ID. Returns storage.ErrFeedNotFound if there is no such feed.
+func (s *Store) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM feeds WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrFeedNotFound
+	}
+
+	return nil
+}
+
+// isUniqueViolation reports whether the error is caused by a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"news/pkg/storage"
 	"news/pkg/storage/memdb"
+	"news/pkg/storage/storagetest"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -61,7 +63,7 @@ func storageConnect() (*Store, error) {
 func truncatePosts(db *Store) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	_, err := db.db.Exec(ctx, "TRUNCATE TABLE posts")
+	_, err := db.db.Exec(ctx, "TRUNCATE TABLE posts CASCADE")
 	if err != nil {
 		return err
 	}
@@ -69,6 +71,15 @@ func truncatePosts(db *Store) error {
 	return nil
 }
 
+// truncateFeeds removes the feeds added during testing.
+func truncateFeeds(db *Store) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := db.db.Exec(ctx, "TRUNCATE TABLE feeds")
+
+	return err
+}
+
 func TestMain(m *testing.M) {
 	log.SetLevel(log.PanicLevel)
 	exitCode := m.Run()
@@ -328,7 +339,7 @@ func TestStore_LatestPosts(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			posts, pagesNum, err := db.LatestPosts(ctx, tt.currentPage, tt.itemsPerPage)
+			posts, pagesNum, err := db.LatestPosts(ctx, storage.PostFilter{}, tt.currentPage, tt.itemsPerPage)
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("LatestPosts() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -395,7 +406,7 @@ func TestStore_FilterPosts(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			posts, _, err := db.FilterPosts(ctx, tt.text, 1, 100)
+			posts, _, err := db.FilterPosts(ctx, tt.text, storage.PostFilter{}, 1, 100)
 			if err != nil {
 				t.Fatalf("FilterPosts() returned error: %v", err)
 			}
@@ -469,3 +480,232 @@ func TestStore_PostNotExist(t *testing.T) {
 		t.Errorf("want empty post, got post %+v", post)
 	}
 }
+
+func TestStore_Sources(t *testing.T) {
+	db, err := storageConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := truncatePosts(db)
+		if err != nil {
+			t.Errorf("unexpected error clearing posts table: %v", err)
+		}
+
+		db.Close()
+	})
+
+	habr := storage.Source{URL: "https://habr.com/rss", Name: "Habr"}
+	weekly := storage.Source{URL: "https://golangweekly.com/rss", Name: "Golang Weekly"}
+	testPosts := []storage.Post{
+		{Title: "Post 1", Content: "Content 1", Published: time.Now(), Link: "https://habr.com/1", Source: habr},
+		{Title: "Post 2", Content: "Content 2", Published: time.Now(), Link: "https://habr.com/2", Source: habr},
+		{Title: "Post 3", Content: "Content 3", Published: time.Now(), Link: "https://golangweekly.com/3", Source: weekly},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = db.AddPosts(ctx, testPosts)
+	if err != nil {
+		t.Fatalf("unexpected error while populating DB: %v", err)
+	}
+
+	gotSources, err := db.Sources(ctx)
+	if err != nil {
+		t.Fatalf("Sources() returned error: %v", err)
+	}
+	wantSources := []storage.SourceStat{
+		{Source: habr, Posts: 2},
+		{Source: weekly, Posts: 1},
+	}
+	if !reflect.DeepEqual(gotSources, wantSources) {
+		t.Errorf("want sources %+v, got %+v", wantSources, gotSources)
+	}
+
+	posts, _, err := db.LatestPosts(ctx, storage.PostFilter{Source: weekly.URL}, 1, 10)
+	if err != nil {
+		t.Fatalf("LatestPosts() returned error: %v", err)
+	}
+	if len(posts) != 1 || posts[0].Source != weekly {
+		t.Errorf("want 1 post from %s, got %+v", weekly.URL, posts)
+	}
+}
+
+func TestStore_PostMetadata(t *testing.T) {
+	db, err := storageConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := truncatePosts(db)
+		if err != nil {
+			t.Errorf("unexpected error clearing posts table: %v", err)
+		}
+
+		db.Close()
+	})
+
+	post := storage.Post{
+		Title:      "Episode 1",
+		Content:    "Show notes",
+		Published:  time.Now().UTC().Truncate(time.Microsecond),
+		Link:       "https://example.com/ep1",
+		Authors:    []string{"Jane Doe", "John Doe"},
+		Categories: []string{"golang"},
+		ImageURL:   "https://example.com/ep1.jpg",
+		Enclosures: []storage.Enclosure{{URL: "https://example.com/ep1.mp3", Type: "audio/mpeg", Length: 2048}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	post.ID, err = db.AddPost(ctx, post)
+	if err != nil {
+		t.Fatalf("unexpected error while populating DB: %v", err)
+	}
+
+	gotPost, err := db.Post(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("unexpected error retrieving post %v from DB: %v", post.ID, err)
+	}
+	if !reflect.DeepEqual(gotPost, post) {
+		t.Errorf("want post\n%+v\ngot post\n%+v\n", post, gotPost)
+	}
+}
+
+func TestStore_PostLang(t *testing.T) {
+	db, err := storageConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := truncatePosts(db)
+		if err != nil {
+			t.Errorf("unexpected error clearing posts table: %v", err)
+		}
+
+		db.Close()
+	})
+
+	published := time.Date(2024, 8, 13, 12, 0, 0, 0, time.UTC)
+	testPosts := []storage.Post{
+		{Title: "Вышел Go 1.23", Published: published, Link: "https://habr.com/1", Lang: "ru"},
+		{Title: "Go 1.23 is released", Published: published, Link: "https://go.dev/1", Lang: "en"},
+		{Title: "Go 1.23", Published: published, Link: "https://example.com/1"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.AddPosts(ctx, testPosts); err != nil {
+		t.Fatalf("AddPosts() returned error: %v", err)
+	}
+
+	posts, numPages, err := db.LatestPosts(ctx, storage.PostFilter{Lang: "ru"}, 1, 10)
+	if err != nil {
+		t.Fatalf("LatestPosts() returned error: %v", err)
+	}
+	if len(posts) != 1 || posts[0].Lang != "ru" || numPages != 1 {
+		t.Errorf("want 1 post in russian on 1 page, got %+v on %d pages", posts, numPages)
+	}
+
+	posts, _, err = db.FilterPosts(ctx, "Go 1.23", storage.PostFilter{Lang: "en"}, 1, 10)
+	if err != nil {
+		t.Fatalf("FilterPosts() returned error: %v", err)
+	}
+	if len(posts) != 1 || posts[0].Lang != "en" {
+		t.Errorf("want 1 post in english, got %+v", posts)
+	}
+}
+
+func TestStore_SearchSnippet(t *testing.T) {
+	db, err := storageConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := truncatePosts(db)
+		if err != nil {
+			t.Errorf("unexpected error clearing posts table: %v", err)
+		}
+
+		db.Close()
+	})
+
+	published := time.Date(2024, 8, 13, 12, 0, 0, 0, time.UTC)
+	testPosts := []storage.Post{
+		{Title: "Go 1.23", Content: "<p>The Go team has released Go 1.23 with iterators.</p>", Published: published, Link: "https://go.dev/1", Lang: "en"},
+		{Title: "Go 1.23", Content: "<p>Вышла новая версия языка Go с итераторами.</p>", Published: published, Link: "https://habr.com/1", Lang: "ru"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.AddPosts(ctx, testPosts); err != nil {
+		t.Fatalf("AddPosts() returned error: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "releases", want: "<b>released</b>"},
+		{query: "итератор", want: "<b>итераторами</b>"},
+	}
+	for _, tt := range tests {
+		results, _, err := db.SearchPosts(ctx, tt.query, storage.PostFilter{}, 1, 10)
+		if err != nil {
+			t.Fatalf("SearchPosts(%q) returned error: %v", tt.query, err)
+		}
+		if len(results) != 1 || !strings.Contains(results[0].Snippet, tt.want) {
+			t.Errorf("want 1 result for %q with %s in the snippet, got %+v", tt.query, tt.want, results)
+		}
+	}
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		db, err := storageConnect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := truncatePosts(db); err != nil {
+			t.Fatalf("unexpected error clearing posts table: %v", err)
+		}
+		if err := truncateFeeds(db); err != nil {
+			t.Fatalf("unexpected error clearing feeds table: %v", err)
+		}
+
+		t.Cleanup(func() {
+			if err := truncatePosts(db); err != nil {
+				t.Errorf("unexpected error clearing posts table: %v", err)
+			}
+			if err := truncateFeeds(db); err != nil {
+				t.Errorf("unexpected error clearing feeds table: %v", err)
+			}
+
+			db.Close()
+		})
+
+		return db
+	})
+}
+
+func TestTsQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"go release", "('go' & 'release')"},
+		{`"tale of a cat" relea*`, "('tale' <-> 'of' <-> 'a' <-> 'cat' & 'relea':*)"},
+		{`go -beta -"release candidate"`, "('go' & !('beta') & !('release' <-> 'candidate'))"},
+		{"go OR новости", "('go') | ('новости')"},
+		{"-beta", ""},
+	}
+	for _, tt := range tests {
+		if got := tsQuery(storage.ParseSearchQuery(tt.query)); got != tt.want {
+			t.Errorf("want tsQuery(%q) = %q, got %q", tt.query, tt.want, got)
+		}
+	}
+}
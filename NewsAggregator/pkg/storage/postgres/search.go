@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"news/pkg/storage"
+)
+
+// The tsqueries of the search with the query text in $1. The words are
+// normalized by both configurations the search vector is built with, see
+// schema.sql; either normalization is present in a matching post.
+const (
+	russianQuery = `to_tsquery('russian', $1)`
+	englishQuery = `to_tsquery('english', $1)`
+	searchQuery  = `(` + russianQuery + ` || ` + englishQuery + `)`
+)
+
+// headlineOptions configure the snippets of ts_headline.
+const headlineOptions = `StartSel=<b>, StopSel=</b>, MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=" … "`
+
+// SearchPosts returns the posts matching the query ranked by ts_rank over the
+// search vector along with the snippets of their content made by ts_headline.
+// An empty query or one of excluded terms alone finds nothing.
+// If page or limit are less than or equal to zero, they default to 1 and 10 respectively.
+func (s *Store) SearchPosts(ctx context.Context, query string, filter storage.PostFilter, page, limit int) ([]storage.SearchResult, int, error) {
+	q := tsQuery(storage.ParseSearchQuery(query))
+	if q == "" {
+		return nil, 0, nil
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	offset := (page - 1) * limit
+
+	// The snippets are only made for the posts of the page, as ts_headline
+	// reads the whole content. The tags are stripped off for it. ts_headline
+	// only marks the words its configuration normalizes the same way as the
+	// query, so the English snippet is made when no word is marked in the
+	// Russian one.
+	rows, err := s.db.Query(ctx, `
+		SELECT `+postColumns+`, rank,
+			CASE WHEN strpos(ru.snippet, '<b>') > 0 THEN ru.snippet
+				ELSE ts_headline('english', stripped.plain, `+englishQuery+`, '`+headlineOptions+`')
+			END
+		FROM (
+			SELECT *, ts_rank(search_vector, `+searchQuery+`)::float8 AS rank
+			FROM posts
+			WHERE duplicate_of IS NULL AND search_vector @@ `+searchQuery+` AND ($4 = '' OR source_url = $4) AND ($5 = '' OR lang = $5)
+			ORDER BY rank DESC, published DESC, id DESC
+			LIMIT $2 OFFSET $3
+		) AS found,
+			LATERAL (SELECT regexp_replace(content, '<[^>]*>', ' ', 'g') AS plain) AS stripped,
+			LATERAL (SELECT ts_headline('russian', stripped.plain, `+russianQuery+`, '`+headlineOptions+`') AS snippet) AS ru
+		ORDER BY rank DESC, published DESC, id DESC
+	`,
+		q,
+		limit,
+		offset,
+		filter.Source,
+		filter.Lang,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []storage.SearchResult
+	for rows.Next() {
+		var r storage.SearchResult
+		r.Post, err = scanPost(rows, &r.Rank, &r.Snippet)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var totalPosts int
+	err = s.db.QueryRow(ctx, `
+	SELECT COUNT(id) FROM posts WHERE duplicate_of IS NULL AND search_vector @@ `+searchQuery+` AND ($2 = '' OR source_url = $2) AND ($3 = '' OR lang = $3)
+	`,
+		q,
+		filter.Source,
+		filter.Lang,
+	).Scan(&totalPosts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	numPages := (totalPosts + limit - 1) / limit
+	return results, numPages, nil
+}
+
+// tsQuery returns the text of the query in the to_tsquery syntax, empty if
+// the query is empty. Each word is quoted, so it is normalized as a whole.
+func tsQuery(query storage.SearchQuery) string {
+	groups := make([]string, 0, len(query))
+	for _, group := range query {
+		terms := make([]string, 0, len(group))
+		for _, term := range group {
+			words := make([]string, 0, len(term.Words))
+			for _, w := range term.Words {
+				words = append(words, "'"+strings.ReplaceAll(w, "'", "''")+"'")
+			}
+			t := strings.Join(words, " <-> ")
+			if term.Prefix {
+				t += ":*"
+			}
+			if term.Exclude {
+				t = "!(" + t + ")"
+			}
+			terms = append(terms, t)
+		}
+		groups = append(groups, "("+strings.Join(terms, " & ")+")")
+	}
+	return strings.Join(groups, " | ")
+}
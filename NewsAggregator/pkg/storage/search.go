@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchResult is a post found by Storage.SearchPosts.
+type SearchResult struct {
+	Post
+	Rank    float64 `json:"rank"`    // Relevance to the query, only comparable within the same search.
+	Snippet string  `json:"snippet"` // HTML fragment of the post text with the matching words in <b> tags.
+}
+
+// SearchTerm is a word or a phrase of a search query.
+type SearchTerm struct {
+	Words   []string // Lower case words, a phrase if there are several.
+	Prefix  bool     // The last word matches the words it begins.
+	Exclude bool     // The posts with the term are left out.
+}
+
+// SearchQuery is a parsed search query. A post matches the query if it
+// matches all the terms of any of its groups.
+type SearchQuery [][]SearchTerm
+
+// ParseSearchQuery parses a query in the syntax of web search engines:
+//
+//	go release    both words
+//	"go release"  the phrase
+//	relea*        a word beginning with relea
+//	go -beta      go, but not beta, a phrase may be excluded as well
+//	go OR rust    either word
+//
+// Words are runs of letters and digits, so go1.25 is the phrase "go1 25".
+// Groups made of excluded terms alone are dropped, as they would match
+// almost every post.
+func ParseSearchQuery(q string) SearchQuery {
+	var query SearchQuery
+	var group []SearchTerm
+	endGroup := func() {
+		for _, term := range group {
+			if !term.Exclude {
+				query = append(query, group)
+				break
+			}
+		}
+		group = nil
+	}
+
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		if unicode.IsSpace(rs[i]) {
+			i++
+			continue
+		}
+
+		var term SearchTerm
+		if rs[i] == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]) {
+			term.Exclude = true
+			i++
+		}
+
+		var token string
+		quoted := rs[i] == '"'
+		if quoted {
+			end := i + 1
+			for end < len(rs) && rs[end] != '"' {
+				end++
+			}
+			token = string(rs[i+1 : end])
+			i = min(end+1, len(rs))
+		} else {
+			end := i
+			for end < len(rs) && !unicode.IsSpace(rs[end]) {
+				end++
+			}
+			token = string(rs[i:end])
+			i = end
+		}
+
+		if !quoted && !term.Exclude && token == "OR" {
+			endGroup()
+			continue
+		}
+		if !quoted && strings.HasSuffix(token, "*") {
+			term.Prefix = true
+		}
+		term.Words = SearchWords(token)
+		if len(term.Words) == 0 {
+			continue
+		}
+		group = append(group, term)
+	}
+	endGroup()
+
+	return query
+}
+
+// SearchWords splits the text into lower case words, the runs of letters and digits.
+func SearchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  SearchQuery
+	}{
+		{"Go release", SearchQuery{{{Words: []string{"go"}}, {Words: []string{"release"}}}}},
+		{`"go release" notes`, SearchQuery{{{Words: []string{"go", "release"}}, {Words: []string{"notes"}}}}},
+		{"relea*", SearchQuery{{{Words: []string{"relea"}, Prefix: true}}}},
+		{`go -beta -"release candidate"`, SearchQuery{{
+			{Words: []string{"go"}},
+			{Words: []string{"beta"}, Exclude: true},
+			{Words: []string{"release", "candidate"}, Exclude: true},
+		}}},
+		{"go OR rust -beta", SearchQuery{{{Words: []string{"go"}}}, {{Words: []string{"rust"}}, {Words: []string{"beta"}, Exclude: true}}}},
+		{"go or rust", SearchQuery{{{Words: []string{"go"}}, {Words: []string{"or"}}, {Words: []string{"rust"}}}}},
+		{"go1.25", SearchQuery{{{Words: []string{"go1", "25"}}}}},
+		{"Новости -спорт", SearchQuery{{{Words: []string{"новости"}}, {Words: []string{"спорт"}, Exclude: true}}}},
+		{`"unclosed phrase`, SearchQuery{{{Words: []string{"unclosed", "phrase"}}}}},
+		{"go - beta", SearchQuery{{{Words: []string{"go"}}, {Words: []string{"beta"}}}}},
+		{"-beta", nil},
+		{"go OR -beta", SearchQuery{{{Words: []string{"go"}}}}},
+		{"OR", nil},
+		{`"" * ...`, nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseSearchQuery(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("want ParseSearchQuery(%q) = %+v, got %+v", tt.query, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+
+	"news/pkg/storage"
+)
+
+// LatestPostsAt returns the page of the posts matching the filter the cursor
+// points to. The page is found by the index on (published, id) without counting
+// the posts. If limit is less than or equal to zero, it defaults to 10.
+func (s *Store) LatestPostsAt(ctx context.Context, filter storage.PostFilter, cursor storage.Cursor, limit int) (storage.PostsPage, error) {
+	return s.postsAt(ctx, filterCond, filterArgs(filter), cursor, limit)
+}
+
+// FilterPostsAt returns the page of the posts matching the filter whose titles
+// contain the given substring the cursor points to. If the substring is empty,
+// it returns an empty page without error.
+func (s *Store) FilterPostsAt(ctx context.Context, contains string, filter storage.PostFilter, cursor storage.Cursor, limit int) (storage.PostsPage, error) {
+	if contains == "" {
+		return storage.PostsPage{}, nil
+	}
+
+	return s.postsAt(ctx, filterCond+` AND instr(title_lower, @contains) > 0`,
+		append(filterArgs(filter), sql.Named("contains", strings.ToLower(contains))), cursor, limit)
+}
+
+// postsAt returns the page of the posts satisfying cond the cursor points to.
+// The condition refers to args by their names.
+func (s *Store) postsAt(ctx context.Context, cond string, args []any, cursor storage.Cursor, limit int) (storage.PostsPage, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	// A backward page is read in the reverse order from the cursor on.
+	cmp, order := "<", "DESC"
+	if cursor.Backward {
+		cmp, order = ">", "ASC"
+	}
+	if !cursor.IsZero() {
+		cond += ` AND (published, id) ` + cmp + ` (@published, @id)`
+		args = append(slices.Clip(args),
+			sql.Named("published", formatTime(cursor.Published)),
+			sql.Named("id", cursor.ID.Bytes()),
+		)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+postColumns+`
+		FROM posts
+		WHERE `+cond+`
+		ORDER BY published `+order+`, id `+order+`
+		LIMIT @limit
+	`,
+		append(slices.Clip(args), sql.Named("limit", limit+1))...,
+	)
+	if err != nil {
+		return storage.PostsPage{}, err
+	}
+	defer rows.Close()
+
+	var posts []storage.Post
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return storage.PostsPage{}, err
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.PostsPage{}, err
+	}
+
+	return storage.CursorPage(posts, cursor, limit), nil
+}
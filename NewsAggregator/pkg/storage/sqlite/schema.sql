@@ -0,0 +1,69 @@
+-- Schema of the SQLite storage, applied by Open to every database it opens.
+-- The statements are idempotent, so an existing database keeps its data.
+
+CREATE TABLE IF NOT EXISTS posts (
+    seq INTEGER PRIMARY KEY, -- Row ID of the post in posts_fts, stable across VACUUM.
+    id BLOB NOT NULL UNIQUE, -- 16 bytes of the UUID, so the order by ID is the one of the other storages.
+    title TEXT NOT NULL,
+    title_lower TEXT NOT NULL, -- Title in lower case for FilterPosts, as lower() only folds ASCII.
+    content TEXT NOT NULL, -- Sanitized HTML.
+    summary TEXT NOT NULL DEFAULT '', -- Plain-text excerpt of the content.
+    published TEXT NOT NULL, -- UTC in the fixed width layout of timeLayout, so the text order is the time order.
+    link TEXT NOT NULL,
+    source_url TEXT NOT NULL DEFAULT '', -- URL of the feed the post was taken from.
+    source_name TEXT NOT NULL DEFAULT '',
+    authors TEXT, -- JSON array of author names, NULL if the feed has none.
+    categories TEXT, -- JSON array.
+    image_url TEXT NOT NULL DEFAULT '',
+    enclosures TEXT, -- JSON array of {"url", "type", "length"} objects.
+    lang TEXT NOT NULL DEFAULT '', -- ISO 639-1 code, empty if the language is unknown.
+    canonical_link TEXT NOT NULL DEFAULT '', -- Link without tracking parameters, see package dedup.
+    fingerprint INTEGER NOT NULL DEFAULT 0, -- SimHash of the title and summary, 0 if the text is too short.
+    duplicate_of BLOB REFERENCES posts (id) ON DELETE SET NULL -- Canonical copy of the story, NULL for canonical posts.
+);
+
+CREATE INDEX IF NOT EXISTS posts_source_url_idx ON posts (source_url);
+CREATE INDEX IF NOT EXISTS posts_published_idx ON posts (published, id); -- Order of the lists and their cursors.
+CREATE INDEX IF NOT EXISTS posts_lang_idx ON posts (lang);
+CREATE INDEX IF NOT EXISTS posts_canonical_link_idx ON posts (canonical_link);
+CREATE INDEX IF NOT EXISTS posts_duplicate_of_idx ON posts (duplicate_of);
+
+-- Words of the title and the text of the content for SearchPosts. The rows are
+-- written by the store along with the posts, as the HTML tags of the content
+-- are stripped off in Go, and removed by the trigger below. The tokenizer folds
+-- the case of any script, but neither stems the words nor strips diacritics.
+CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5 (
+    title,
+    content,
+    tokenize = 'unicode61 remove_diacritics 0'
+);
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts
+BEGIN
+    DELETE FROM posts_fts WHERE rowid = old.seq;
+END;
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id INTEGER PRIMARY KEY,
+    post_id BLOB NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    changed_at TEXT NOT NULL, -- UTC in the layout of timeLayout.
+    source_url TEXT NOT NULL DEFAULT '', -- Feed the changed item came from.
+    source_name TEXT NOT NULL DEFAULT '',
+    changes TEXT NOT NULL -- JSON array of {"field", "old", "new"} objects.
+);
+
+CREATE INDEX IF NOT EXISTS post_revisions_post_id_idx ON post_revisions (post_id, changed_at);
+
+CREATE TABLE IF NOT EXISTS feeds (
+    id BLOB PRIMARY KEY,
+    url TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT '',
+    poll_interval INTEGER NOT NULL DEFAULT 0, -- Minutes, 0 means the parser default.
+    enabled INTEGER NOT NULL DEFAULT 1,
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_timeout INTEGER NOT NULL DEFAULT 0, -- Seconds, 0 means the fetcher default.
+    full_text INTEGER NOT NULL DEFAULT 0, -- Download full articles for the posts.
+    rules TEXT NOT NULL DEFAULT '{}', -- JSON of the include and exclude rules for the feed items.
+    scraper TEXT -- JSON of the CSS selectors of a site without a feed, NULL for feeds.
+);
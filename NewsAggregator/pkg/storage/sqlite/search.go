@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"slices"
+	"strings"
+
+	"news/pkg/storage"
+)
+
+// Weights of the columns of posts_fts in the bm25 rank, a title match is more
+// relevant than a content one.
+const (
+	titleWeight   = 10.0
+	contentWeight = 1.0
+)
+
+// Markers of the matches in the snippets made by FTS5. They are replaced by
+// the tags once the snippet is escaped for HTML.
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// snippetTokens is the largest number of words in a snippet, FTS5 allows up to 64.
+const snippetTokens = 35
+
+// SearchPosts returns the posts matching the query ranked by bm25 over the
+// title and the text of the content along with the snippets of the content
+// made by FTS5. The words are compared regardless of case, but without stemming.
+// An empty query or one of excluded terms alone finds nothing.
+// If page or limit are less than or equal to zero, they default to 1 and 10 respectively.
+func (s *Store) SearchPosts(ctx context.Context, query string, filter storage.PostFilter, page, limit int) ([]storage.SearchResult, int, error) {
+	q := ftsQuery(storage.ParseSearchQuery(query))
+	if q == "" {
+		return nil, 0, nil
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	offset := (page - 1) * limit
+	args := append(filterArgs(filter), sql.Named("query", q))
+
+	// bm25 is lower for the more relevant posts, its negation is the rank.
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+postColumns+`, rank, snippet
+		FROM posts, (
+			SELECT rowid, -bm25(posts_fts, @titleWeight, @contentWeight) AS rank,
+				snippet(posts_fts, 1, @matchStart, @matchEnd, '…', @snippetTokens) AS snippet
+			FROM posts_fts
+			WHERE posts_fts MATCH @query
+		) AS found
+		WHERE found.rowid = posts.seq AND `+filterCond+`
+		ORDER BY rank DESC, published DESC, id DESC
+		LIMIT @limit OFFSET @offset
+	`,
+		append(slices.Clip(args),
+			sql.Named("titleWeight", titleWeight),
+			sql.Named("contentWeight", contentWeight),
+			sql.Named("matchStart", matchStart),
+			sql.Named("matchEnd", matchEnd),
+			sql.Named("snippetTokens", snippetTokens),
+			sql.Named("limit", limit),
+			sql.Named("offset", offset),
+		)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []storage.SearchResult
+	for rows.Next() {
+		var r storage.SearchResult
+		r.Post, err = scanPost(rows, &r.Rank, &r.Snippet)
+		if err != nil {
+			return nil, 0, err
+		}
+		r.Snippet = highlight(r.Snippet)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var totalPosts int
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM posts, posts_fts
+		WHERE posts_fts.rowid = posts.seq AND posts_fts MATCH @query AND `+filterCond,
+		args...,
+	).Scan(&totalPosts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	numPages := (totalPosts + limit - 1) / limit
+	return results, numPages, nil
+}
+
+// highlight escapes the snippet made by FTS5 for HTML and puts its matches in <b> tags.
+func highlight(snippet string) string {
+	return strings.NewReplacer(matchStart, "<b>", matchEnd, "</b>").Replace(html.EscapeString(snippet))
+}
+
+// ftsQuery returns the text of the query in the FTS5 syntax, empty if the
+// query is empty. Each term is quoted as a string, so it is a phrase of the
+// words the tokenizer finds in it.
+func ftsQuery(query storage.SearchQuery) string {
+	groups := make([]string, 0, len(query))
+	for _, group := range query {
+		// NOT of FTS5 is a binary operator, so the excluded terms follow the others.
+		var include, exclude []string
+		for _, term := range group {
+			t := `"` + strings.Join(term.Words, " ") + `"`
+			if term.Prefix {
+				t += "*"
+			}
+			if term.Exclude {
+				exclude = append(exclude, t)
+			} else {
+				include = append(include, t)
+			}
+		}
+		g := "(" + strings.Join(include, " AND ") + ")"
+		for _, t := range exclude {
+			g += " NOT " + t
+		}
+		groups = append(groups, "("+g+")")
+	}
+	return strings.Join(groups, " OR ")
+}
@@ -0,0 +1,832 @@
+// Package sqlite implements storage.Storage over an embedded SQLite database
+// file for the deployments that have no PostgreSQL server. The full-text search
+// is done by FTS5, which the driver only includes with the sqlite_fts5 build tag:
+//
+//	go build -tags sqlite_fts5 ./...
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/mattn/go-sqlite3"
+
+	"news/pkg/dedup"
+	"news/pkg/sanitize"
+	"news/pkg/storage"
+)
+
+// ErrNoFTS5 is returned by New if the SQLite library is built without FTS5.
+var ErrNoFTS5 = fmt.Errorf("sqlite was built without FTS5, build with -tags sqlite_fts5")
+
+//go:embed schema.sql
+var schema string
+
+// connParams are the parameters of every database connection: the foreign keys
+// remove the revisions and unlink the alternates of the deleted posts, the write
+// ahead log lets the readers run along with a writer and the immediate
+// transactions wait for each other instead of failing at their first write.
+const connParams = "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+
+// timeLayout is the layout of the stored times, always in UTC. The fixed width
+// keeps the text order the same as the time order.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// postColumns lists the posts columns in the order scanPost reads them.
+const postColumns = `id, title, content, summary, published, link, source_url, source_name,
+	authors, categories, image_url, enclosures, lang`
+
+// feedColumns lists the feeds columns in the order scanFeed reads them.
+const feedColumns = `id, url, name, category, poll_interval, enabled, user_agent, request_timeout, full_text, rules, scraper`
+
+// filterCond selects the canonical posts matching the filter given by filterArgs.
+const filterCond = `duplicate_of IS NULL AND (@source = '' OR source_url = @source) AND (@lang = '' OR lang = @lang)`
+
+type Store struct {
+	db *sql.DB
+}
+
+// New opens the database file at the given path, creating it if there is none,
+// and brings its schema up to date. Returns ErrNoFTS5 if the SQLite library
+// lacks the full-text search.
+func New(ctx context.Context, path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path+"?"+connParams)
+	if err != nil {
+		return nil, err
+	}
+	s := Store{
+		db: db,
+	}
+
+	var fts5 bool
+	err = db.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5)
+	if err == nil && !fts5 {
+		err = ErrNoFTS5
+	}
+	if err == nil {
+		_, err = db.ExecContext(ctx, schema)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *Store) Close() {
+	s.db.Close()
+}
+
+// AddPost inserts a single post into the database or updates it if a post with the same ID already exists.
+// The post ID is generated as a UUIDv5 based on the post's Link.
+// A change of the title or the content of an existing post is recorded as its revision.
+// The method returns the ID of the inserted or updated post and an error if any occurs.
+func (s *Store) AddPost(ctx context.Context, post storage.Post) (id uuid.UUID, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	id, err = addPost(ctx, tx, post)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// AddPosts inserts or updates a batch of posts in the database within a single transaction,
+// as AddPost does.
+func (s *Store) AddPosts(ctx context.Context, posts []storage.Post) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, post := range posts {
+		if _, err := addPost(ctx, tx, post); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// addPost stores the post and returns its ID. A new post is linked to the
+// earliest canonical post it duplicates, see canonicalOf, a change of a stored
+// post is recorded as its revision. The search index is written along with the
+// title and the content.
+func addPost(ctx context.Context, tx *sql.Tx, post storage.Post) (uuid.UUID, error) {
+	post.ID = uuid.NewV5(uuid.NamespaceURL, post.Link)
+
+	var seq int64
+	var old storage.Post
+	err := tx.QueryRowContext(ctx, `SELECT seq, title, content FROM posts WHERE id = ?`, post.ID.Bytes()).
+		Scan(&seq, &old.Title, &old.Content)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		var duplicateOf any
+		canonical, ok, err := canonicalOf(ctx, tx, post)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if ok {
+			duplicateOf = canonical.Bytes()
+		}
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO posts (`+postColumns+`, title_lower, canonical_link, fingerprint, duplicate_of)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING seq
+		`,
+			append(upsertArgs(post), duplicateOf)...,
+		).Scan(&seq)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+	case err != nil:
+		return uuid.Nil, err
+
+	default:
+		// The link of an existing post is the one its ID is made of, and its
+		// publication date is kept, see storage.Storage.AddPost.
+		_, err := tx.ExecContext(ctx, `
+			UPDATE posts SET
+				title = ?2,
+				content = ?3,
+				summary = ?4,
+				source_url = ?7,
+				source_name = ?8,
+				authors = ?9,
+				categories = ?10,
+				image_url = ?11,
+				enclosures = ?12,
+				lang = ?13,
+				title_lower = ?14,
+				canonical_link = ?15,
+				fingerprint = ?16
+			WHERE id = ?1
+		`,
+			upsertArgs(post)...,
+		)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		changes := diff(old, post)
+		if len(changes) == 0 {
+			return post.ID, nil
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO post_revisions (post_id, changed_at, source_url, source_name, changes)
+			VALUES (?, ?, ?, ?, ?)
+		`,
+			post.ID.Bytes(),
+			formatTime(time.Now()),
+			post.Source.URL,
+			post.Source.Name,
+			jsonArg(changes),
+		)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM posts_fts WHERE rowid = ?`, seq)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO posts_fts (rowid, title, content) VALUES (?, ?, ?)`,
+		seq, post.Title, sanitize.Text(post.Content))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return post.ID, nil
+}
+
+// canonicalOf returns the ID of the earliest canonical post with the same
+// canonical link or a similar fingerprint published close to the given post.
+// SQLite has no bit count, so the fingerprints are compared by package dedup.
+func canonicalOf(ctx context.Context, tx *sql.Tx, post storage.Post) (uuid.UUID, bool, error) {
+	link := dedup.CanonicalLink(post.Link)
+	fp := dedup.Fingerprint(post.Title, post.Summary)
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, canonical_link, fingerprint
+		FROM posts
+		WHERE duplicate_of IS NULL AND id <> @id AND (
+			canonical_link = @link OR (
+				@fingerprint <> 0 AND fingerprint <> 0
+				AND published BETWEEN @from AND @to
+			)
+		)
+		ORDER BY published
+	`,
+		sql.Named("id", post.ID.Bytes()),
+		sql.Named("link", link),
+		sql.Named("fingerprint", int64(fp)),
+		sql.Named("from", formatTime(post.Published.Add(-dedup.Window))),
+		sql.Named("to", formatTime(post.Published.Add(dedup.Window))),
+	)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var canonicalLink string
+		var fingerprint int64
+		if err := rows.Scan(&id, &canonicalLink, &fingerprint); err != nil {
+			return uuid.Nil, false, err
+		}
+		if canonicalLink == link || dedup.Similar(fp, uint64(fingerprint)) {
+			return id, true, nil
+		}
+	}
+
+	return uuid.Nil, false, rows.Err()
+}
+
+// diff returns the changes of the title and the content of a post.
+func diff(old, new storage.Post) []storage.Change {
+	var changes []storage.Change
+	if old.Title != new.Title {
+		changes = append(changes, storage.Change{Field: "title", Old: old.Title, New: new.Title})
+	}
+	if old.Content != new.Content {
+		changes = append(changes, storage.Change{Field: "content", Old: old.Content, New: new.Content})
+	}
+	return changes
+}
+
+// LatestPosts returns a paginated list of posts matching the filter ordered by published date descending.
+// It accepts the page number and the number of items per page as parameters.
+// If page or limit are less than or equal to zero, they default to 1 and 10 respectively.
+// The method returns the posts for the requested page, the total number of pages available,
+// and any error encountered during the database queries.
+func (s *Store) LatestPosts(ctx context.Context, filter storage.PostFilter, page, limit int) (posts []storage.Post, numPages int, err error) {
+	return s.listPosts(ctx, filterCond, filterArgs(filter), page, limit)
+}
+
+// FilterPosts returns a paginated list of posts matching the filter whose titles contain the given substring
+// regardless of case. If the substring is empty, it returns an empty list without error.
+// It returns the list of matching posts for the specified page and limit,
+// along with the total number of pages available for the given filter and page size.
+// Returns an error if any occurs.
+func (s *Store) FilterPosts(ctx context.Context, contains string, filter storage.PostFilter, page, limit int) ([]storage.Post, int, error) {
+	if contains == "" {
+		return nil, 0, nil
+	}
+
+	return s.listPosts(ctx, filterCond+` AND instr(title_lower, @contains) > 0`,
+		append(filterArgs(filter), sql.Named("contains", strings.ToLower(contains))), page, limit)
+}
+
+// listPosts returns the given page of the posts satisfying cond in descending
+// order by date along with the total page count. The condition refers to args
+// by their names. If page or limit are less than or equal to zero, they default
+// to 1 and 10 respectively.
+func (s *Store) listPosts(ctx context.Context, cond string, args []any, page, limit int) ([]storage.Post, int, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	offset := (page - 1) * limit
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+postColumns+`
+		FROM posts
+		WHERE `+cond+`
+		ORDER BY published DESC, id DESC
+		LIMIT @limit OFFSET @offset
+	`,
+		append(slices.Clip(args), sql.Named("limit", limit), sql.Named("offset", offset))...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var posts []storage.Post
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var totalPosts int
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM posts WHERE `+cond, args...).Scan(&totalPosts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	numPages := (totalPosts + limit - 1) / limit
+	return posts, numPages, nil
+}
+
+// Post retrieves a post by its ID along with the other copies of the story as alternates.
+// It returns the post and an error if any occurs.
+func (s *Store) Post(ctx context.Context, id uuid.UUID) (post storage.Post, err error) {
+	post, err = scanPost(s.db.QueryRowContext(ctx, `
+		SELECT `+postColumns+`
+		FROM posts
+		WHERE id = ?
+	`,
+		id.Bytes(),
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = storage.ErrPostNotFound
+		}
+		return
+	}
+
+	post.Alternates, err = s.alternates(ctx, id)
+	return
+}
+
+// ExpiredPosts returns the IDs of at most limit posts the retention policy expires,
+// oldest first: the posts published before policy.Before and the posts of each source
+// beyond the policy.MaxPerSource latest ones. Posts in policy.Keep never expire.
+func (s *Store) ExpiredPosts(ctx context.Context, policy storage.Retention, limit int) ([]uuid.UUID, error) {
+	var before string
+	if !policy.Before.IsZero() {
+		before = formatTime(policy.Before)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id
+		FROM (
+			SELECT id, published,
+				row_number() OVER (PARTITION BY source_url ORDER BY published DESC, id) AS rank
+			FROM posts
+		) AS ranked
+		WHERE (published < @before OR (@max > 0 AND rank > @max))
+			AND id NOT IN (SELECT unhex(value) FROM json_each(@keep))
+		ORDER BY published
+		LIMIT @limit
+	`,
+		sql.Named("before", before),
+		sql.Named("max", policy.MaxPerSource),
+		sql.Named("keep", idsArg(policy.Keep)),
+		sql.Named("limit", max(limit, 0)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// DeletePosts removes the posts with the given IDs and returns the number of removed posts.
+// Revisions of the posts are removed with them and their alternates become canonical posts.
+func (s *Store) DeletePosts(ctx context.Context, ids []uuid.UUID) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM posts WHERE id IN (SELECT unhex(value) FROM json_each(?))`, idsArg(ids))
+	if err != nil {
+		return 0, err
+	}
+
+	removed, err := res.RowsAffected()
+	return int(removed), err
+}
+
+// Revisions returns the changes of the post made by feed updates, newest first.
+// Returns storage.ErrPostNotFound if there is no such post.
+func (s *Store) Revisions(ctx context.Context, id uuid.UUID) ([]storage.Revision, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT changed_at, source_url, source_name, changes
+		FROM post_revisions
+		WHERE post_id = ?
+		ORDER BY changed_at DESC, id DESC
+	`,
+		id.Bytes(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []storage.Revision{}
+	for rows.Next() {
+		var r storage.Revision
+		err := rows.Scan(timeColumn{&r.Changed}, &r.Source.URL, &r.Source.Name, jsonColumn{&r.Changes})
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		var exists bool
+		err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM posts WHERE id = ?)`, id.Bytes()).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, storage.ErrPostNotFound
+		}
+	}
+
+	return revisions, nil
+}
+
+// alternates returns the other posts of the group the post belongs to, ordered by published date.
+func (s *Store) alternates(ctx context.Context, id uuid.UUID) ([]storage.Alternate, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.id, p.link, p.source_url, p.source_name
+		FROM posts p, (SELECT COALESCE(duplicate_of, id) AS id FROM posts WHERE id = @id) c
+		WHERE p.id <> @id AND (p.id = c.id OR p.duplicate_of = c.id)
+		ORDER BY p.published
+	`,
+		sql.Named("id", id.Bytes()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alternates []storage.Alternate
+	for rows.Next() {
+		var a storage.Alternate
+		err := rows.Scan(
+			&a.ID,
+			&a.Link,
+			&a.Source.URL,
+			&a.Source.Name)
+		if err != nil {
+			return nil, err
+		}
+		alternates = append(alternates, a)
+	}
+
+	return alternates, rows.Err()
+}
+
+// Sources returns every source that has stored posts along with its post count,
+// ordered by post count descending.
+func (s *Store) Sources(ctx context.Context) ([]storage.SourceStat, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT source_url, source_name, COUNT(*)
+		FROM posts
+		GROUP BY source_url, source_name
+		ORDER BY COUNT(*) DESC, source_url
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []storage.SourceStat
+	for rows.Next() {
+		var src storage.SourceStat
+		err := rows.Scan(
+			&src.URL,
+			&src.Name,
+			&src.Posts)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// AddFeed inserts a feed into the database. The feed gets a random UUIDv4, so its ID doesn't
+// depend on the URL, which UpdateFeed may change.
+// Returns the ID of the inserted feed, storage.ErrFeedExists if a feed with the same URL
+// is already stored, or any other error that occurs.
+func (s *Store) AddFeed(ctx context.Context, feed storage.Feed) (id uuid.UUID, err error) {
+	feed.ID, err = uuid.NewV4()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO feeds (`+feedColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		feedArgs(feed)...,
+	)
+	if isUniqueViolation(err) {
+		return uuid.Nil, storage.ErrFeedExists
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return feed.ID, nil
+}
+
+// Feeds returns all the stored feeds ordered by URL.
+func (s *Store) Feeds(ctx context.Context) ([]storage.Feed, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+feedColumns+`
+		FROM feeds
+		ORDER BY url
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feeds []storage.Feed
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return feeds, nil
+}
+
+// Feed retrieves a feed by its ID. It returns the feed and storage.ErrFeedNotFound
+// if there is no such feed, or any other error that occurs.
+func (s *Store) Feed(ctx context.Context, id uuid.UUID) (feed storage.Feed, err error) {
+	feed, err = scanFeed(s.db.QueryRowContext(ctx, `
+		SELECT `+feedColumns+`
+		FROM feeds
+		WHERE id = ?
+	`,
+		id.Bytes(),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = storage.ErrFeedNotFound
+	}
+
+	return
+}
+
+// UpdateFeed replaces the stored feed with the same ID. Returns storage.ErrFeedNotFound
+// if there is no such feed and storage.ErrFeedExists if another feed has the same URL.
+func (s *Store) UpdateFeed(ctx context.Context, feed storage.Feed) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE feeds SET
+			url = ?2,
+			name = ?3,
+			category = ?4,
+			poll_interval = ?5,
+			enabled = ?6,
+			user_agent = ?7,
+			request_timeout = ?8,
+			full_text = ?9,
+			rules = ?10,
+			scraper = ?11
+		WHERE id = ?1
+	`,
+		feedArgs(feed)...,
+	)
+	if isUniqueViolation(err) {
+		return storage.ErrFeedExists
+	}
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrFeedNotFound
+	}
+
+	return nil
+}
+
+// DeleteFeed removes a feed by its ID. Returns storage.ErrFeedNotFound if there is no such feed.
+func (s *Store) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM feeds WHERE id = ?`, id.Bytes())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrFeedNotFound
+	}
+
+	return nil
+}
+
+// postArgs returns the values of the post in the order of postColumns.
+func postArgs(p storage.Post) []any {
+	return []any{
+		p.ID.Bytes(),
+		p.Title,
+		p.Content,
+		p.Summary,
+		formatTime(p.Published),
+		p.Link,
+		p.Source.URL,
+		p.Source.Name,
+		jsonArg(p.Authors),
+		jsonArg(p.Categories),
+		p.ImageURL,
+		jsonArg(p.Enclosures),
+		p.Lang,
+	}
+}
+
+// upsertArgs returns the values of the post for addPost: the postColumns values
+// followed by the lower case title and the deduplication ones.
+func upsertArgs(p storage.Post) []any {
+	return append(postArgs(p),
+		strings.ToLower(p.Title),
+		dedup.CanonicalLink(p.Link),
+		int64(dedup.Fingerprint(p.Title, p.Summary)),
+	)
+}
+
+// filterArgs returns the values of the filter for filterCond.
+func filterArgs(filter storage.PostFilter) []any {
+	return []any{
+		sql.Named("source", filter.Source),
+		sql.Named("lang", filter.Lang),
+	}
+}
+
+// scanner is a row to scan, either sql.Row or sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPost reads a row selected with postColumns into a post. The columns
+// selected after them are read into extra.
+func scanPost(row scanner, extra ...any) (storage.Post, error) {
+	var p storage.Post
+	dest := []any{
+		&p.ID,
+		&p.Title,
+		&p.Content,
+		&p.Summary,
+		timeColumn{&p.Published},
+		&p.Link,
+		&p.Source.URL,
+		&p.Source.Name,
+		jsonColumn{&p.Authors},
+		jsonColumn{&p.Categories},
+		&p.ImageURL,
+		jsonColumn{&p.Enclosures},
+		&p.Lang,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return storage.Post{}, err
+	}
+
+	return p, nil
+}
+
+// feedArgs returns the values of the feed in the order of feedColumns.
+func feedArgs(f storage.Feed) []any {
+	return []any{
+		f.ID.Bytes(),
+		f.URL,
+		f.Name,
+		f.Category,
+		f.Interval,
+		f.Enabled,
+		f.UserAgent,
+		f.Timeout,
+		f.FullText,
+		jsonArg(f.Rules),
+		jsonArg(f.Scraper),
+	}
+}
+
+// scanFeed reads a row selected with feedColumns into a feed.
+func scanFeed(row scanner) (storage.Feed, error) {
+	var f storage.Feed
+	err := row.Scan(
+		&f.ID,
+		&f.URL,
+		&f.Name,
+		&f.Category,
+		&f.Interval,
+		&f.Enabled,
+		&f.UserAgent,
+		&f.Timeout,
+		&f.FullText,
+		jsonColumn{&f.Rules},
+		jsonColumn{&f.Scraper},
+	)
+
+	return f, err
+}
+
+// formatTime returns the time as it is stored, see timeLayout.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// timeColumn reads a time stored by formatTime.
+type timeColumn struct {
+	t *time.Time
+}
+
+func (c timeColumn) Scan(src any) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unable to scan %T into time", src)
+	}
+	t, err := time.Parse(timeLayout, s)
+	if err != nil {
+		return err
+	}
+	*c.t = t
+
+	return nil
+}
+
+// jsonArg returns the JSON of the value to store, NULL for a nil slice or pointer.
+// The values are of the types encoding/json never fails on.
+func jsonArg(v any) any {
+	b, _ := json.Marshal(v)
+	if string(b) == "null" {
+		return nil
+	}
+	return string(b)
+}
+
+// jsonColumn reads a column stored by jsonArg into the value v points to,
+// leaving it as is for NULL.
+type jsonColumn struct {
+	v any
+}
+
+func (c jsonColumn) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src), c.v)
+	case []byte:
+		return json.Unmarshal(src, c.v)
+	}
+	return fmt.Errorf("unable to scan %T into JSON", src)
+}
+
+// idsArg returns the IDs as a JSON array of hex strings for json_each and unhex.
+func idsArg(ids []uuid.UUID) string {
+	hexIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		hexIDs = append(hexIDs, fmt.Sprintf("%x", id.Bytes()))
+	}
+	b, _ := json.Marshal(hexIDs)
+	return string(b)
+}
+
+// isUniqueViolation reports whether the error is caused by a unique or a primary key constraint violation.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"news/pkg/storage"
+	"news/pkg/storage/storagetest"
+)
+
+// openStore opens a store in a new database file removed after the test.
+// The test is skipped if the driver is built without FTS5.
+func openStore(t *testing.T, path string) *Store {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	db, err := New(ctx, path)
+	if errors.Is(err, ErrNoFTS5) {
+		t.Skip("run the tests with -tags sqlite_fts5")
+	}
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	return db
+}
+
+func newStore(t *testing.T) *Store {
+	return openStore(t, filepath.Join(t.TempDir(), "news.db"))
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return newStore(t)
+	})
+}
+
+func TestStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "news.db")
+	db := openStore(t, path)
+
+	post := storage.Post{
+		Title:     "Go 1.23 is released",
+		Content:   "<p>Release notes</p>",
+		Published: time.Date(2024, 8, 13, 12, 0, 0, 0, time.UTC),
+		Link:      "https://go.dev/blog/go1.23",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	id, err := db.AddPost(ctx, post)
+	if err != nil {
+		t.Fatalf("AddPost() returned error: %v", err)
+	}
+	db.Close()
+
+	db = openStore(t, path)
+	if _, err := db.Post(ctx, id); err != nil {
+		t.Fatalf("want post kept in the file, got error: %v", err)
+	}
+	results, _, err := db.SearchPosts(ctx, "release", storage.PostFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("SearchPosts() returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != id {
+		t.Errorf("want post found after reopening, got %+v", results)
+	}
+}
+
+func TestStore_PostMetadata(t *testing.T) {
+	db := newStore(t)
+
+	post := storage.Post{
+		Title:      "Episode 1",
+		Content:    "Show notes",
+		Published:  time.Now().UTC(),
+		Link:       "https://example.com/ep1",
+		Authors:    []string{"Jane Doe", "John Doe"},
+		Categories: []string{"golang"},
+		ImageURL:   "https://example.com/ep1.jpg",
+		Enclosures: []storage.Enclosure{{URL: "https://example.com/ep1.mp3", Type: "audio/mpeg", Length: 2048}},
+		Lang:       "en",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var err error
+	post.ID, err = db.AddPost(ctx, post)
+	if err != nil {
+		t.Fatalf("unexpected error while populating DB: %v", err)
+	}
+
+	gotPost, err := db.Post(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("unexpected error retrieving post %v from DB: %v", post.ID, err)
+	}
+	if !reflect.DeepEqual(gotPost, post) {
+		t.Errorf("want post\n%+v\ngot post\n%+v\n", post, gotPost)
+	}
+}
+
+func TestFtsQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"go release", `(("go" AND "release"))`},
+		{`"tale of a cat" relea*`, `(("tale of a cat" AND "relea"*))`},
+		{`go -beta -"release candidate"`, `(("go") NOT "beta" NOT "release candidate")`},
+		{"go OR новости", `(("go")) OR (("новости"))`},
+		{"-beta", ""},
+	}
+	for _, tt := range tests {
+		if got := ftsQuery(storage.ParseSearchQuery(tt.query)); got != tt.want {
+			t.Errorf("want ftsQuery(%q) = %q, got %q", tt.query, tt.want, got)
+		}
+	}
+}
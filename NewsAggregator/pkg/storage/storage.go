@@ -1,13 +1,16 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"regexp"
+	"slices"
 	"time"
 
-	log "github.com/sirupsen/logrus"
-
+	"github.com/andybalholm/cascadia"
 	"github.com/gofrs/uuid"
 )
 
@@ -15,47 +18,309 @@ var (
 	ErrConnectDB       = fmt.Errorf("unable to establish DB connection")
 	ErrDBNotResponding = fmt.Errorf("DB not responding")
 	ErrPostNotFound    = fmt.Errorf("post not found")
+	ErrFeedNotFound    = fmt.Errorf("feed not found")
+	ErrFeedExists      = fmt.Errorf("feed already exists")
+	ErrInvalidFeed     = fmt.Errorf("invalid feed")
+	ErrInvalidCursor   = fmt.Errorf("invalid cursor")
 )
 
+// Source identifies the feed a post was taken from.
+type Source struct {
+	URL  string `json:"url"`
+	Name string `json:"name"`
+}
+
+// SourceStat is a source along with the number of its stored posts.
+type SourceStat struct {
+	Source
+	Posts int `json:"posts"`
+}
+
 type Post struct {
+	ID         uuid.UUID   `json:"id"`
+	Title      string      `json:"title"`
+	Content    string      `json:"content,omitempty"` // Sanitized HTML.
+	Summary    string      `json:"summary"`           // Plain-text excerpt of the content.
+	Published  time.Time   `json:"published"`
+	Link       string      `json:"link"`
+	Source     Source      `json:"source"`
+	Authors    []string    `json:"authors,omitempty"`
+	Categories []string    `json:"categories,omitempty"`
+	ImageURL   string      `json:"image_url,omitempty"` // Lead image of the post.
+	Enclosures []Enclosure `json:"enclosures,omitempty"`
+	Lang       string      `json:"lang,omitempty"`       // ISO 639-1 code of the language, empty if unknown.
+	Alternates []Alternate `json:"alternates,omitempty"` // Other copies of the story, only set by Storage.Post.
+}
+
+// Alternate is a copy of a post published under another link or by another feed.
+// Lists of posts only contain the canonical copy of each story, see dedup.
+type Alternate struct {
+	ID     uuid.UUID `json:"id"`
+	Link   string    `json:"link"`
+	Source Source    `json:"source"`
+}
+
+// Revision records a change of a stored post made when its feed updated the item.
+type Revision struct {
+	Changed time.Time `json:"changed"`
+	Source  Source    `json:"source"` // Feed the changed item came from.
+	Changes []Change  `json:"changes"`
+}
+
+// Change is the old and the new value of a changed post field: title or content.
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Enclosure is a media file attached to a post, e.g. a podcast episode.
+type Enclosure struct {
+	URL    string `json:"url"`
+	Type   string `json:"type"`             // MIME type.
+	Length int64  `json:"length,omitempty"` // Size in bytes, if known.
+}
+
+// PostFilter narrows down the posts returned by LatestPosts and FilterPosts.
+// Zero value fields are not applied.
+type PostFilter struct {
+	Source string // URL of the source feed.
+	Lang   string // ISO 639-1 code of the post language, see Post.Lang.
+}
+
+// Retention selects the posts to purge, see Storage.ExpiredPosts.
+// Zero value fields are not applied.
+type Retention struct {
+	Before       time.Time   // Posts published before this time are expired.
+	MaxPerSource int         // Posts of a source beyond this many latest ones are expired.
+	Keep         []uuid.UUID // Posts that never expire.
+}
+
+// Feed describes a single news source and how it is polled.
+// The feed ID is generated as a UUIDv5 based on the URL the feed was added with.
+type Feed struct {
 	ID        uuid.UUID `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Published time.Time `json:"published"`
-	Link      string    `json:"link"`
+	URL       string    `json:"url"`
+	Name      string    `json:"name"`
+	Category  string    `json:"category"`
+	Interval  int       `json:"interval"` // Polling interval in minutes, parser default if not set.
+	Enabled   bool      `json:"enabled"`
+	UserAgent string    `json:"user_agent"`
+	Timeout   int       `json:"timeout"`   // Request timeout in seconds, fetcher default if not set.
+	FullText  bool      `json:"full_text"` // Replace the post content with the article downloaded from the post link.
+	Rules     Rules     `json:"rules"`
+	Scraper   *Scraper  `json:"scraper,omitempty"` // Set for sites without a feed, whose list page is scraped instead.
+}
+
+// Scraper describes how posts are taken from the list page of a site that has
+// no feed. The selectors are CSS selectors; all but Item are matched within
+// an item container. Empty optional selectors leave the post fields unset.
+type Scraper struct {
+	Item       string `json:"item"`                  // Container of a single post on the list page.
+	Title      string `json:"title"`                 // Element with the post title.
+	Link       string `json:"link"`                  // Link to the post, the first link within the element if it is not a link itself.
+	Date       string `json:"date,omitempty"`        // Element with the publication date, a datetime attribute is preferred to the text.
+	DateLayout string `json:"date_layout,omitempty"` // Layout of the date as in package time, the feed date layouts are tried if empty.
+	Summary    string `json:"summary,omitempty"`     // Element whose HTML becomes the post content.
+}
+
+// Fields of a post matched by rules.
+const (
+	RuleTitle    = "title"
+	RuleContent  = "content"  // Text of the post content, without HTML.
+	RuleCategory = "category" // Matches if any of the post categories does.
+	RuleAuthor   = "author"   // Matches if any of the post authors does.
+)
+
+// Rules decide which items of a feed are stored. An item is rejected if it
+// matches any exclude rule, if there are include rules and it matches none of
+// them or if its text content is shorter than MinLength characters.
+type Rules struct {
+	Include   []Rule `json:"include,omitempty"`
+	Exclude   []Rule `json:"exclude,omitempty"`
+	MinLength int    `json:"min_length,omitempty"`
+}
+
+// Rule matches the posts whose field matches the regular expression.
+type Rule struct {
+	Field   string `json:"field"`   // One of the Rule* field names.
+	Pattern string `json:"pattern"` // Regular expression in the RE2 syntax, see package regexp.
+}
+
+// String returns the rule as it is shown in logs and rejection counts.
+func (r Rule) String() string {
+	return r.Field + " ~ " + r.Pattern
+}
+
+// validate checks that the required selectors are set and every selector is valid.
+func (s Scraper) validate() error {
+	if s.Item == "" || s.Title == "" || s.Link == "" {
+		return fmt.Errorf("scraper requires item, title and link selectors")
+	}
+	for _, sel := range []string{s.Item, s.Title, s.Link, s.Date, s.Summary} {
+		if sel == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(sel); err != nil {
+			return fmt.Errorf("bad scraper selector %q: %w", sel, err)
+		}
+	}
+	return nil
+}
+
+// validate checks that the rules match known fields with valid patterns.
+func (r Rules) validate() error {
+	if r.MinLength < 0 {
+		return fmt.Errorf("negative min_length")
+	}
+	for _, rule := range append(slices.Clip(r.Include), r.Exclude...) {
+		switch rule.Field {
+		case RuleTitle, RuleContent, RuleCategory, RuleAuthor:
+		default:
+			return fmt.Errorf("unknown rule field %q", rule.Field)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("bad rule pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a feed either from an object or from a plain URL
+// string, the format of the parser config before per-feed settings were
+// introduced. Feeds are enabled unless stated otherwise.
+func (f *Feed) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte(`"`)) {
+		var addr string
+		if err := json.Unmarshal(b, &addr); err != nil {
+			return err
+		}
+		*f = Feed{URL: addr, Enabled: true}
+		return nil
+	}
+
+	// The alias type has no UnmarshalJSON method, which prevents recursion.
+	type feed Feed
+	v := feed{Enabled: true}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*f = Feed(v)
+
+	return nil
+}
+
+// Validate checks that the feed has an absolute URL, no negative settings, valid
+// rules and, for a scraped site, valid scraper selectors.
+func (f Feed) Validate() error {
+	u, err := url.ParseRequestURI(f.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: bad url %q", ErrInvalidFeed, f.URL)
+	}
+	if f.Interval < 0 || f.Timeout < 0 {
+		return fmt.Errorf("%w: negative interval or timeout of feed %s", ErrInvalidFeed, f.URL)
+	}
+	if err := f.Rules.validate(); err != nil {
+		return fmt.Errorf("%w: feed %s: %w", ErrInvalidFeed, f.URL, err)
+	}
+	if f.Scraper != nil {
+		if err := f.Scraper.validate(); err != nil {
+			return fmt.Errorf("%w: feed %s: %w", ErrInvalidFeed, f.URL, err)
+		}
+	}
+	return nil
+}
+
+// Equal reports whether the feeds have the same settings, rules and scraper selectors.
+func (f Feed) Equal(other Feed) bool {
+	return f.ID == other.ID &&
+		f.URL == other.URL &&
+		f.Name == other.Name &&
+		f.Category == other.Category &&
+		f.Interval == other.Interval &&
+		f.Enabled == other.Enabled &&
+		f.UserAgent == other.UserAgent &&
+		f.Timeout == other.Timeout &&
+		f.FullText == other.FullText &&
+		slices.Equal(f.Rules.Include, other.Rules.Include) &&
+		slices.Equal(f.Rules.Exclude, other.Rules.Exclude) &&
+		f.Rules.MinLength == other.Rules.MinLength &&
+		(f.Scraper == other.Scraper || f.Scraper != nil && other.Scraper != nil && *f.Scraper == *other.Scraper)
 }
 
 type Storage interface {
 	// AddPost adds a single post to the storage and returns the post ID and an error if any occurs.
+	// A new post whose canonical link or fingerprint matches a stored post (see package dedup)
+	// is kept as an alternate of that post. A stored post with the same ID is updated, but keeps
+	// its publication date, so an item dated by the time it was seen never moves in the lists.
 	AddPost(ctx context.Context, post Post) (id uuid.UUID, err error)
 
 	// AddPosts adds multiple posts to the storage and returns an error if any occurs.
 	AddPosts(ctx context.Context, posts []Post) (err error)
 
-	// LatestPosts fetches recent posts in descending order by date.
+	// LatestPosts fetches recent posts matching the filter in descending order by date,
+	// posts published at the same time in descending order by ID. Alternates are left out of the list.
+	// A page number below 1 is the first page, a limit below 1 is 10 posts per page.
 	// Returns a list of posts, total page count, and an error if any occurs.
-	LatestPosts(ctx context.Context, currentPage, limit int) (posts []Post, numPages int, err error)
+	LatestPosts(ctx context.Context, filter PostFilter, currentPage, limit int) (posts []Post, numPages int, err error)
+
+	// LatestPostsAt fetches the page of the list of LatestPosts the cursor points to,
+	// the first page for the zero cursor. Unlike the numbered pages, the cursor pages
+	// neither skip nor repeat posts as new ones are added.
+	LatestPostsAt(ctx context.Context, filter PostFilter, cursor Cursor, limit int) (page PostsPage, err error)
 
-	// Post retrieves a post by its ID. It returns the post and an error if any occurs.
+	// Post retrieves a post by its ID along with the other copies of the story as Alternates.
+	// It returns the post and an error if any occurs.
 	Post(ctx context.Context, id uuid.UUID) (post Post, err error)
 
-	// FilterPosts returns a list of posts whose titles contain the given substring,
-	// total page count and an error if any occurs.
-	FilterPosts(ctx context.Context, contains string, page, limit int) (posts []Post, numPages int, err error)
-}
-
-// ValidatePosts accepts a slice of posts and removes the invalid ones, i.e., posts containing any empty fields.
-func ValidatePosts(posts ...Post) []Post {
-	var validPosts []Post
-	for _, p := range posts {
-		if p.Title != "" && p.Content != "" && p.Link != "" && !p.Published.IsZero() {
-			if _, err := url.ParseRequestURI(p.Link); err == nil {
-				validPosts = append(validPosts, p)
-			}
-		} else {
-			log.Warnf("Invalidated post: %+v", p)
-		}
-	}
+	// Revisions returns the changes of the post made by feed updates, newest first.
+	// AddPost and AddPosts record a revision whenever they change the title or
+	// the content of a stored post. Returns ErrPostNotFound if there is no such post.
+	Revisions(ctx context.Context, id uuid.UUID) (revisions []Revision, err error)
+
+	// FilterPosts returns a list of posts matching the filter whose titles contain
+	// the given substring regardless of case, total page count and an error if any occurs.
+	// The posts are ordered and paginated as by LatestPosts. An empty substring matches nothing.
+	FilterPosts(ctx context.Context, contains string, filter PostFilter, page, limit int) (posts []Post, numPages int, err error)
+
+	// FilterPostsAt fetches the page of the list of FilterPosts the cursor points to,
+	// see LatestPostsAt.
+	FilterPostsAt(ctx context.Context, contains string, filter PostFilter, cursor Cursor, limit int) (page PostsPage, err error)
+
+	// SearchPosts returns the posts matching the filter and the query, see
+	// ParseSearchQuery, in the title or the text of the content. The most relevant
+	// posts go first, posts of the same relevance are ordered as by LatestPosts.
+	// A title match is more relevant than a content one. Alternates are left out of the list.
+	// Returns the results, total page count and an error if any occurs.
+	SearchPosts(ctx context.Context, query string, filter PostFilter, page, limit int) (results []SearchResult, numPages int, err error)
+
+	// Sources returns every source that has stored posts along with its post count.
+	Sources(ctx context.Context) (sources []SourceStat, err error)
+
+	// ExpiredPosts returns the IDs of at most limit posts the retention policy
+	// expires, oldest first.
+	ExpiredPosts(ctx context.Context, policy Retention, limit int) (ids []uuid.UUID, err error)
+
+	// DeletePosts removes the posts with the given IDs along with their revisions
+	// and returns the number of removed posts. Alternates of a removed canonical
+	// post are no longer grouped with it. Unknown IDs are ignored.
+	DeletePosts(ctx context.Context, ids []uuid.UUID) (removed int, err error)
+
+	// AddFeed adds a feed to the storage and returns the feed ID and an error if any occurs.
+	// The ID is generated anew, whatever the ID of the given feed, and doesn't depend on the URL.
+	// Returns ErrFeedExists if a feed with the same URL is already stored.
+	AddFeed(ctx context.Context, feed Feed) (id uuid.UUID, err error)
+
+	// Feeds returns all the stored feeds ordered by URL.
+	Feeds(ctx context.Context) (feeds []Feed, err error)
+
+	// Feed retrieves a feed by its ID. Returns ErrFeedNotFound if there is no such feed.
+	Feed(ctx context.Context, id uuid.UUID) (feed Feed, err error)
+
+	// UpdateFeed replaces the stored feed with the same ID. Returns ErrFeedNotFound
+	// if there is no such feed and ErrFeedExists if another feed has the same URL.
+	UpdateFeed(ctx context.Context, feed Feed) (err error)
 
-	return validPosts
+	// DeleteFeed removes a feed by its ID. Returns ErrFeedNotFound if there is no such feed.
+	DeleteFeed(ctx context.Context, id uuid.UUID) (err error)
 }
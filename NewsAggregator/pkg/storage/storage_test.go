@@ -1,14 +1,11 @@
 package storage
 
 import (
+	"errors"
 	"os"
-	"reflect"
 	"testing"
-	"time"
 
 	log "github.com/sirupsen/logrus"
-
-	"github.com/gofrs/uuid"
 )
 
 func TestMain(m *testing.M) {
@@ -17,64 +14,94 @@ func TestMain(m *testing.M) {
 	os.Exit(exitCode)
 }
 
-func TestValidatePosts(t *testing.T) {
-	testPosts := []Post{
+func TestFeed_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		feed    Feed
+		wantErr bool
+	}{
+		{
+			name: "valid rules",
+			feed: Feed{URL: "https://example.com/rss", Rules: Rules{
+				Include:   []Rule{{Field: RuleTitle, Pattern: `(?i)\bgo(lang)?\b`}, {Field: RuleCategory, Pattern: "^Go$"}},
+				Exclude:   []Rule{{Field: RuleAuthor, Pattern: "bot"}},
+				MinLength: 100,
+			}},
+		},
+		{
+			name:    "relative url",
+			feed:    Feed{URL: "/rss"},
+			wantErr: true,
+		},
 		{
-			// Empty title
-			Title:     "",
-			Content:   "Sample content",
-			Published: time.Now(),
-			Link:      "https://example.com/post/1",
+			name:    "unknown rule field",
+			feed:    Feed{URL: "https://example.com/rss", Rules: Rules{Exclude: []Rule{{Field: "link", Pattern: "ads"}}}},
+			wantErr: true,
 		},
 		{
-			// Empty content
-			Title:     "Sample Title",
-			Content:   "",
-			Published: time.Now(),
-			Link:      "https://example.com/post/2",
+			name:    "bad rule pattern",
+			feed:    Feed{URL: "https://example.com/rss", Rules: Rules{Include: []Rule{{Field: RuleTitle, Pattern: "go("}}}},
+			wantErr: true,
 		},
 		{
-			// Default time
-			Title:     "Sample Title",
-			Content:   "Sample content",
-			Published: time.Time{},
-			Link:      "https://example.com/post/3",
+			name:    "negative min length",
+			feed:    Feed{URL: "https://example.com/rss", Rules: Rules{MinLength: -1}},
+			wantErr: true,
 		},
 		{
-			// Empty link
-			Title:     "Sample Title",
-			Content:   "Sample content",
-			Published: time.Now(),
-			Link:      "",
+			name: "valid scraper",
+			feed: Feed{URL: "https://example.com/news", Scraper: &Scraper{
+				Item: "article.post", Title: "h2", Link: "h2 a", Date: "time", DateLayout: "02.01.2006", Summary: ".lead",
+			}},
 		},
 		{
-			// Valid post
-			Title:     "Sample Title",
-			Content:   "Sample content",
-			Published: time.Now(),
-			Link:      "https://example.com/post/5",
+			name:    "scraper without link selector",
+			feed:    Feed{URL: "https://example.com/news", Scraper: &Scraper{Item: "article", Title: "h2"}},
+			wantErr: true,
 		},
 		{
-			// Invalid url
-			Title:     "Valid Title",
-			Content:   "Valid Content",
-			Published: time.Now(),
-			Link:      "invalid_url",
+			name:    "bad scraper selector",
+			feed:    Feed{URL: "https://example.com/news", Scraper: &Scraper{Item: "article", Title: "h2[", Link: "a"}},
+			wantErr: true,
 		},
 	}
 
-	for i, post := range testPosts {
-		testPosts[i].ID = uuid.NewV5(uuid.NamespaceURL, post.Link)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.feed.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidFeed) {
+				t.Errorf("want ErrInvalidFeed, got %v", err)
+			}
+		})
 	}
+}
+
+func TestFeed_Equal(t *testing.T) {
+	feed := Feed{URL: "https://example.com/rss", Rules: Rules{Include: []Rule{{Field: RuleTitle, Pattern: "go"}}}}
 
-	gotPosts := ValidatePosts(testPosts...)
-	if len(gotPosts) != 1 {
-		t.Fatalf("want 1 post after validation, got %d posts after validation", len(gotPosts))
+	same := feed
+	same.Rules.Include = []Rule{{Field: RuleTitle, Pattern: "go"}}
+	if !feed.Equal(same) {
+		t.Error("want feeds with the same rules equal")
 	}
 
-	gotValidPost := gotPosts[0]
-	wantValidPost := testPosts[4]
-	if !reflect.DeepEqual(gotValidPost, wantValidPost) {
-		t.Errorf("want valid post ID:%v, got valid post ID:%v", wantValidPost, gotValidPost)
+	changed := feed
+	changed.Rules.Include = []Rule{{Field: RuleTitle, Pattern: "rust"}}
+	if feed.Equal(changed) {
+		t.Error("want feeds with different rules not equal")
+	}
+
+	scraped := feed
+	scraped.Scraper = &Scraper{Item: "article", Title: "h2", Link: "a"}
+	if feed.Equal(scraped) {
+		t.Error("want feed and scraped site not equal")
+	}
+	sameScraper := scraped
+	sameScraper.Scraper = &Scraper{Item: "article", Title: "h2", Link: "a"}
+	if !scraped.Equal(sameScraper) {
+		t.Error("want scraped sites with the same selectors equal")
 	}
 }
@@ -0,0 +1,880 @@
+// Package storagetest is a conformance suite for the implementations of
+// storage.Storage. Every storage backend runs it from its own tests, so they
+// all order, paginate, filter, deduplicate and expire posts and manage feeds
+// the same way.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"news/pkg/storage"
+)
+
+// Sources of the test posts.
+var (
+	sourceNews = storage.Source{URL: "https://news.example.com/rss", Name: "News"}
+	sourceBlog = storage.Source{URL: "https://blog.example.org/feed", Name: "Blog"}
+)
+
+// testPosts returns posts from newest to oldest, published days apart so that
+// none of them is a duplicate of another, but for a pair published at the
+// same time to check the order by ID.
+func testPosts() []storage.Post {
+	day := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	post := func(n int, title string, published time.Time, src storage.Source, lang string) storage.Post {
+		return storage.Post{
+			Title:     title,
+			Content:   fmt.Sprintf("<p>Content %d</p>", n),
+			Summary:   fmt.Sprintf("Content %d", n),
+			Published: published,
+			Link:      fmt.Sprintf("%s/posts/%d", src.URL, n),
+			Source:    src,
+			Lang:      lang,
+		}
+	}
+
+	posts := []storage.Post{
+		post(1, "Go 1.25 is released", day, sourceNews, "en"),
+		post(2, "Release notes of GoLand", day, sourceBlog, "en"),
+		post(3, "Новости недели", day.AddDate(0, 0, -4), sourceNews, "ru"),
+		post(4, "A tale of a cat", day.AddDate(0, 0, -8), sourceBlog, "en"),
+		post(5, "Обзор релиза Go", day.AddDate(0, 0, -12), sourceBlog, "ru"),
+		post(6, "Weekly digest", day.AddDate(0, 0, -16), sourceNews, "en"),
+		post(7, "Untitled language", day.AddDate(0, 0, -20), sourceNews, ""),
+	}
+	posts[2].Content = "<p>Content 3, the story of a <i>cat</i></p>"
+
+	return posts
+}
+
+// Run runs the conformance suite. newStore must return an empty storage for
+// each subtest; cleaning it up after the subtest is up to newStore.
+func Run(t *testing.T, newStore func(t *testing.T) storage.Storage) {
+	t.Run("Ordering", func(t *testing.T) { testOrdering(t, newStore(t)) })
+	t.Run("Pagination", func(t *testing.T) { testPagination(t, newStore(t)) })
+	t.Run("Cursor", func(t *testing.T) { testCursor(t, newStore(t)) })
+	t.Run("Upsert", func(t *testing.T) { testUpsert(t, newStore(t)) })
+	t.Run("Filters", func(t *testing.T) { testFilters(t, newStore(t)) })
+	t.Run("FilterPosts", func(t *testing.T) { testFilterPosts(t, newStore(t)) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newStore(t)) })
+	t.Run("PostNotFound", func(t *testing.T) { testPostNotFound(t, newStore(t)) })
+	t.Run("Feeds", func(t *testing.T) { testFeeds(t, newStore(t)) })
+	t.Run("Duplicates", func(t *testing.T) { testDuplicates(t, newStore(t)) })
+	t.Run("Revisions", func(t *testing.T) { testRevisions(t, newStore(t)) })
+	t.Run("Retention", func(t *testing.T) { testRetention(t, newStore(t)) })
+}
+
+// populate adds the test posts to the storage and returns them with their IDs
+// in the order the storage lists them.
+func populate(t *testing.T, db storage.Storage) []storage.Post {
+	t.Helper()
+
+	posts := testPosts()
+	for i, p := range posts {
+		id, err := db.AddPost(context.Background(), p)
+		if err != nil {
+			t.Fatalf("unexpected error while adding post: %v", err)
+		}
+		posts[i].ID = id
+	}
+
+	// The posts published at the same time go in descending order by ID.
+	sort.SliceStable(posts, func(i, j int) bool {
+		if !posts[i].Published.Equal(posts[j].Published) {
+			return posts[i].Published.After(posts[j].Published)
+		}
+		return bytes.Compare(posts[i].ID.Bytes(), posts[j].ID.Bytes()) > 0
+	})
+
+	return posts
+}
+
+// titles returns the titles of the posts, an empty slice for no posts.
+func titles(posts []storage.Post) []string {
+	titles := []string{}
+	for _, p := range posts {
+		titles = append(titles, p.Title)
+	}
+	return titles
+}
+
+func testOrdering(t *testing.T, db storage.Storage) {
+	want := populate(t, db)
+
+	got, numPages, err := db.LatestPosts(context.Background(), storage.PostFilter{}, 1, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if numPages != 1 {
+		t.Errorf("want 1 page, got %d", numPages)
+	}
+	if !reflect.DeepEqual(titles(got), titles(want)) {
+		t.Errorf("want posts %v, got %v", titles(want), titles(got))
+	}
+
+	// Every call returns the posts in the same order.
+	again, _, err := db.LatestPosts(context.Background(), storage.PostFilter{}, 1, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(titles(again), titles(got)) {
+		t.Errorf("want posts %v on repeated call, got %v", titles(got), titles(again))
+	}
+}
+
+func testPagination(t *testing.T, db storage.Storage) {
+	posts, numPages, err := db.LatestPosts(context.Background(), storage.PostFilter{}, 1, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 0 || numPages != 0 {
+		t.Errorf("want no posts and pages in empty storage, got %d posts and %d pages", len(posts), numPages)
+	}
+
+	all := titles(populate(t, db))
+
+	tests := []struct {
+		name         string
+		page         int
+		limit        int
+		wantTitles   []string
+		wantNumPages int
+	}{
+		{"first page", 1, 3, all[:3], 3},
+		{"middle page", 2, 3, all[3:6], 3},
+		{"last page with fewer posts", 3, 3, all[6:], 3},
+		{"page past the last one", 4, 3, []string{}, 3},
+		{"zero page is the first one", 0, 3, all[:3], 3},
+		{"negative page is the first one", -1, 3, all[:3], 3},
+		{"limit equal to post count", 1, len(all), all, 1},
+		{"limit above post count", 1, len(all) + 1, all, 1},
+		{"one post per page", len(all), 1, all[len(all)-1:], len(all)},
+		{"zero limit is 10 posts per page", 1, 0, all, 1},
+		{"negative limit is 10 posts per page", 1, -1, all, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, numPages, err := db.LatestPosts(context.Background(), storage.PostFilter{}, tt.page, tt.limit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if numPages != tt.wantNumPages {
+				t.Errorf("want %d pages, got %d", tt.wantNumPages, numPages)
+			}
+			if got := titles(posts); !reflect.DeepEqual(got, tt.wantTitles) {
+				t.Errorf("want posts %v, got %v", tt.wantTitles, got)
+			}
+		})
+	}
+}
+
+func testCursor(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	page, err := db.LatestPostsAt(ctx, storage.PostFilter{}, storage.Cursor{}, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Posts) != 0 || !page.Next.IsZero() || !page.Prev.IsZero() {
+		t.Errorf("want empty page without cursors in empty storage, got %+v", page)
+	}
+
+	all := titles(populate(t, db))
+
+	// A limit below 1 is 10 posts per page.
+	page, err = db.LatestPostsAt(ctx, storage.PostFilter{}, storage.Cursor{}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := titles(page.Posts); !reflect.DeepEqual(got, all) || !page.Next.IsZero() {
+		t.Errorf("want posts %v on one page for zero limit, got %v and next cursor %+v", all, got, page.Next)
+	}
+
+	// Forward through the list: the pages cover it once, in order.
+	var got []string
+	var pages []storage.PostsPage
+	for cursor := (storage.Cursor{}); ; {
+		page, err := db.LatestPostsAt(ctx, storage.PostFilter{}, cursor, 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(pages) == 0 != page.Prev.IsZero() {
+			t.Errorf("want previous cursor on all pages but the first, got %+v on page %d", page.Prev, len(pages)+1)
+		}
+		pages = append(pages, page)
+		got = append(got, titles(page.Posts)...)
+
+		if len(pages) == 1 {
+			// A post added on top meanwhile doesn't shift the next pages.
+			added := storage.Post{
+				Title:     "Breaking news",
+				Content:   "<p>Breaking</p>",
+				Published: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+				Link:      sourceNews.URL + "/posts/breaking",
+				Source:    sourceNews,
+			}
+			if _, err := db.AddPost(ctx, added); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if page.Next.IsZero() || len(pages) > len(all) {
+			break
+		}
+		cursor = page.Next
+	}
+	if !reflect.DeepEqual(got, all) {
+		t.Errorf("want posts %v, got %v", all, got)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("want 3 pages, got %d", len(pages))
+	}
+
+	// Back from the last page: the previous pages are the same as before.
+	page, err = db.LatestPostsAt(ctx, storage.PostFilter{}, pages[2].Prev, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := titles(pages[1].Posts); !reflect.DeepEqual(titles(page.Posts), want) {
+		t.Errorf("want previous page %v, got %v", want, titles(page.Posts))
+	}
+	if page.Next != pages[1].Next || page.Prev != pages[1].Prev {
+		t.Errorf("want cursors of the previous page %+v, got %+v", pages[1], page)
+	}
+	// The first page has the added post before it now.
+	page, err = db.LatestPostsAt(ctx, storage.PostFilter{}, pages[1].Prev, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := titles(pages[0].Posts); !reflect.DeepEqual(titles(page.Posts), want) {
+		t.Errorf("want first page %v, got %v", want, titles(page.Posts))
+	}
+	if page.Prev.IsZero() {
+		t.Error("want previous cursor to the added post")
+	}
+
+	// Past the end of the list there is the way back only.
+	last := pages[2].Posts[len(pages[2].Posts)-1]
+	page, err = db.LatestPostsAt(ctx, storage.PostFilter{}, storage.After(last), 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Posts) != 0 || !page.Next.IsZero() || page.Prev.IsZero() {
+		t.Errorf("want empty page with previous cursor only past the end, got %+v", page)
+	}
+
+	// The cursors of a filtered list.
+	filter := storage.PostFilter{Source: sourceBlog.URL}
+	first, err := db.FilterPostsAt(ctx, "o", filter, storage.Cursor{}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, _, err := db.FilterPosts(ctx, "o", filter, 1, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(want) != 3 {
+		t.Fatalf("want 3 posts, got %v", titles(want))
+	}
+	second, err := db.FilterPostsAt(ctx, "o", filter, first.Next, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := append(titles(first.Posts), titles(second.Posts)...); !reflect.DeepEqual(got, titles(want)) {
+		t.Errorf("want posts %v, got %v", titles(want), got)
+	}
+	if !second.Next.IsZero() {
+		t.Errorf("want last page without next cursor, got %+v", second.Next)
+	}
+
+	page, err = db.FilterPostsAt(ctx, "", storage.PostFilter{}, storage.Cursor{}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Posts) != 0 {
+		t.Errorf("want no posts for empty substring, got %v", titles(page.Posts))
+	}
+}
+
+func testUpsert(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+	posts := testPosts()[:2]
+
+	id, err := db.AddPost(ctx, posts[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := uuid.NewV5(uuid.NamespaceURL, posts[0].Link); id != want {
+		t.Errorf("want ID %v derived from the link, got %v", want, id)
+	}
+
+	updated := posts[0]
+	updated.Title = "Go 1.25 is out"
+	updated.Content = "<p>Updated content</p>"
+	updated.Published = posts[0].Published.Add(time.Hour)
+	gotID, err := db.AddPost(ctx, updated)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotID != id {
+		t.Errorf("want ID %v of the stored post, got %v", id, gotID)
+	}
+
+	got, err := db.Post(ctx, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Title != updated.Title || got.Content != updated.Content {
+		t.Errorf("want post updated to %q, %q, got %q, %q", updated.Title, updated.Content, got.Title, got.Content)
+	}
+	if !got.Published.Equal(posts[0].Published) {
+		t.Errorf("want publication date %v kept, got %v", posts[0].Published, got.Published)
+	}
+
+	// AddPosts updates the stored posts and adds the new ones alike.
+	updated.Title = "Go 1.25 is here"
+	if err := db.AddPosts(ctx, []storage.Post{updated, posts[1]}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	list, numPages, err := db.LatestPosts(ctx, storage.PostFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if numPages != 1 || len(list) != 2 {
+		t.Fatalf("want 2 posts on 1 page, got %v on %d pages", titles(list), numPages)
+	}
+	for _, p := range list {
+		if p.ID == id && p.Title != updated.Title {
+			t.Errorf("want post updated to %q, got %q", updated.Title, p.Title)
+		}
+	}
+
+	revisions, err := db.Revisions(ctx, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Errorf("want 2 revisions, got %d", len(revisions))
+	}
+}
+
+func testFilters(t *testing.T, db storage.Storage) {
+	all := populate(t, db)
+
+	match := func(keep func(storage.Post) bool) []string {
+		titles := []string{}
+		for _, p := range all {
+			if keep(p) {
+				titles = append(titles, p.Title)
+			}
+		}
+		return titles
+	}
+
+	tests := []struct {
+		name   string
+		filter storage.PostFilter
+		want   []string
+	}{
+		{"no filter", storage.PostFilter{}, match(func(storage.Post) bool { return true })},
+		{"source", storage.PostFilter{Source: sourceBlog.URL}, match(func(p storage.Post) bool { return p.Source == sourceBlog })},
+		{"lang", storage.PostFilter{Lang: "ru"}, match(func(p storage.Post) bool { return p.Lang == "ru" })},
+		{"source and lang", storage.PostFilter{Source: sourceNews.URL, Lang: "en"}, match(func(p storage.Post) bool {
+			return p.Source == sourceNews && p.Lang == "en"
+		})},
+		{"unknown source", storage.PostFilter{Source: "https://unknown.example.com/rss"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, numPages, err := db.LatestPosts(context.Background(), tt.filter, 1, 2)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := (len(tt.want) + 1) / 2; numPages != want {
+				t.Errorf("want %d pages, got %d", want, numPages)
+			}
+			if got, want := titles(posts), tt.want[:min(2, len(tt.want))]; !reflect.DeepEqual(got, want) {
+				t.Errorf("want posts %v, got %v", want, got)
+			}
+		})
+	}
+
+	// A duplicate of a stored post is left out of the lists.
+	dup := all[3]
+	dup.ID = uuid.Nil
+	dup.Link += "?utm_source=mirror"
+	dup.Source = storage.Source{URL: "https://mirror.example.net/rss", Name: "Mirror"}
+	if _, err := db.AddPost(context.Background(), dup); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	posts, _, err := db.LatestPosts(context.Background(), storage.PostFilter{}, 1, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != len(all) {
+		t.Errorf("want %d posts without the duplicate, got %v", len(all), titles(posts))
+	}
+	posts, _, err = db.FilterPosts(context.Background(), all[3].Title, storage.PostFilter{}, 1, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("want 1 post without the duplicate, got %v", titles(posts))
+	}
+}
+
+func testFilterPosts(t *testing.T, db storage.Storage) {
+	all := titles(populate(t, db))
+
+	tests := []struct {
+		name         string
+		contains     string
+		filter       storage.PostFilter
+		page         int
+		limit        int
+		wantTitles   []string
+		wantNumPages int
+	}{
+		{"exact title", "A tale of a cat", storage.PostFilter{}, 1, 10, []string{"A tale of a cat"}, 1},
+		{"part of title", "e of a", storage.PostFilter{}, 1, 10, []string{"A tale of a cat"}, 1},
+		{"mixed case", "tAlE", storage.PostFilter{}, 1, 10, []string{"A tale of a cat"}, 1},
+		{"upper case", "GO", storage.PostFilter{}, 1, 10, withWord(all, "go"), 1},
+		{"cyrillic", "релиз", storage.PostFilter{}, 1, 10, []string{"Обзор релиза Go"}, 1},
+		{"ordered and paginated", "go", storage.PostFilter{}, 2, 2, withWord(all, "go")[2:], 2},
+		{"page past the last one", "go", storage.PostFilter{}, 3, 2, []string{}, 2},
+		{"with filter", "go", storage.PostFilter{Source: sourceBlog.URL, Lang: "ru"}, 1, 10, []string{"Обзор релиза Go"}, 1},
+		{"no match", "x-x-x", storage.PostFilter{}, 1, 10, []string{}, 0},
+		{"empty string", "", storage.PostFilter{}, 1, 10, []string{}, 0},
+		{"zero limit is 10 posts per page", "go", storage.PostFilter{}, 1, 0, withWord(all, "go"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, numPages, err := db.FilterPosts(context.Background(), tt.contains, tt.filter, tt.page, tt.limit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if numPages != tt.wantNumPages {
+				t.Errorf("want %d pages, got %d", tt.wantNumPages, numPages)
+			}
+			if got := titles(posts); !reflect.DeepEqual(got, tt.wantTitles) {
+				t.Errorf("want posts %v, got %v", tt.wantTitles, got)
+			}
+		})
+	}
+}
+
+// withWord returns the titles that contain the lower case word regardless of case.
+func withWord(titles []string, word string) []string {
+	var found []string
+	for _, title := range titles {
+		if strings.Contains(strings.ToLower(title), word) {
+			found = append(found, title)
+		}
+	}
+	return found
+}
+
+func testSearch(t *testing.T, db storage.Storage) {
+	all := titles(populate(t, db))
+	sorted := func(titles ...string) []string {
+		return slices.Sorted(slices.Values(titles))
+	}
+
+	// The words of the queries are their own stems in both languages, so that
+	// the backends without stemming find the same posts.
+	tests := []struct {
+		name         string
+		query        string
+		filter       storage.PostFilter
+		page         int
+		limit        int
+		ordered      bool // Compare the order of the results, the set of them otherwise.
+		wantTitles   []string
+		wantNumPages int
+	}{
+		{"title ranks above content", "cat", storage.PostFilter{}, 1, 10, true, []string{"A tale of a cat", "Новости недели"}, 1},
+		{"all words", "tale cat", storage.PostFilter{}, 1, 10, true, []string{"A tale of a cat"}, 1},
+		{"phrase", `"tale of a cat"`, storage.PostFilter{}, 1, 10, true, []string{"A tale of a cat"}, 1},
+		{"phrase out of order", `"cat tale"`, storage.PostFilter{}, 1, 10, true, []string{}, 0},
+		{"prefix", "relea*", storage.PostFilter{}, 1, 10, false, sorted("Go 1.25 is released", "Release notes of GoLand"), 1},
+		{"exclusion", "content -cat", storage.PostFilter{}, 1, 10, false, sorted(withoutTitles(all, "A tale of a cat", "Новости недели")...), 1},
+		{"either word", "cat OR digest", storage.PostFilter{}, 1, 10, false, sorted("A tale of a cat", "Новости недели", "Weekly digest"), 1},
+		{"cyrillic", "новости", storage.PostFilter{}, 1, 10, true, []string{"Новости недели"}, 1},
+		{"with filter", "cat", storage.PostFilter{Lang: "ru"}, 1, 10, true, []string{"Новости недели"}, 1},
+		{"page past the last one", "cat", storage.PostFilter{}, 2, 10, true, []string{}, 1},
+		{"paginated", "content", storage.PostFilter{}, 4, 2, true, nil, 4},
+		{"no match", "dog", storage.PostFilter{}, 1, 10, true, []string{}, 0},
+		{"exclusion alone", "-cat", storage.PostFilter{}, 1, 10, true, []string{}, 0},
+		{"empty query", "", storage.PostFilter{}, 1, 10, true, []string{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, numPages, err := db.SearchPosts(context.Background(), tt.query, tt.filter, tt.page, tt.limit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if numPages != tt.wantNumPages {
+				t.Errorf("want %d pages, got %d", tt.wantNumPages, numPages)
+			}
+
+			got := []string{}
+			for _, r := range results {
+				got = append(got, r.Title)
+			}
+			if !tt.ordered {
+				got = sorted(got...)
+			}
+			if tt.wantTitles == nil {
+				if len(got) != 1 {
+					t.Errorf("want 1 post on the last page, got %v", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.wantTitles) {
+				t.Errorf("want posts %v, got %v", tt.wantTitles, got)
+			}
+		})
+	}
+
+	results, _, err := db.SearchPosts(context.Background(), "cat", storage.PostFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("want 2 results, got %d", len(results))
+	}
+	if results[0].Rank <= results[1].Rank {
+		t.Errorf("want rank of title match %v above rank of content match %v", results[0].Rank, results[1].Rank)
+	}
+	if !strings.Contains(results[1].Snippet, "<b>cat</b>") {
+		t.Errorf("want matching word highlighted in snippet, got %q", results[1].Snippet)
+	}
+}
+
+// withoutTitles returns the titles but the given ones.
+func withoutTitles(titles []string, drop ...string) []string {
+	var kept []string
+	for _, title := range titles {
+		if !slices.Contains(drop, title) {
+			kept = append(kept, title)
+		}
+	}
+	return kept
+}
+
+func testPostNotFound(t *testing.T, db storage.Storage) {
+	populate(t, db)
+	id := uuid.NewV5(uuid.NamespaceURL, "https://example.com/missing")
+
+	post, err := db.Post(context.Background(), id)
+	if !errors.Is(err, storage.ErrPostNotFound) {
+		t.Errorf("want error %v, got %v", storage.ErrPostNotFound, err)
+	}
+	if !reflect.DeepEqual(post, storage.Post{}) {
+		t.Errorf("want empty post, got %+v", post)
+	}
+
+	if _, err := db.Revisions(context.Background(), id); !errors.Is(err, storage.ErrPostNotFound) {
+		t.Errorf("want error %v for revisions, got %v", storage.ErrPostNotFound, err)
+	}
+}
+
+func testFeeds(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	feed := storage.Feed{URL: "https://habr.com/rss", Name: "Habr", Interval: 10, Enabled: true}
+	id, err := db.AddFeed(ctx, feed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id == uuid.Nil {
+		t.Error("want feed ID, got nil UUID")
+	}
+	if _, err := db.AddFeed(ctx, feed); !errors.Is(err, storage.ErrFeedExists) {
+		t.Errorf("want error %v for duplicate feed, got %v", storage.ErrFeedExists, err)
+	}
+
+	feed.ID = id
+	feed.Enabled = false
+	feed.Rules = storage.Rules{
+		Include:   []storage.Rule{{Field: storage.RuleTitle, Pattern: "(?i)go"}},
+		MinLength: 100,
+	}
+	feed.Scraper = &storage.Scraper{Item: "article", Title: "h2", Link: "h2 a", Date: "time", DateLayout: "02.01.2006"}
+	if err := db.UpdateFeed(ctx, feed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := db.Feed(ctx, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.Equal(feed) {
+		t.Errorf("want feed %+v, got %+v", feed, got)
+	}
+
+	other := storage.Feed{URL: "https://go.dev/blog/feed.atom", Enabled: true}
+	other.ID, err = db.AddFeed(ctx, other)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	taken := other
+	taken.URL = feed.URL
+	if err := db.UpdateFeed(ctx, taken); !errors.Is(err, storage.ErrFeedExists) {
+		t.Errorf("want error %v for taken URL, got %v", storage.ErrFeedExists, err)
+	}
+	feeds, err := db.Feeds(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(feeds) != 2 || feeds[0].URL != other.URL || !feeds[1].Equal(feed) {
+		t.Errorf("want 2 feeds ordered by URL, got %+v", feeds)
+	}
+
+	// The URL a feed had is free once it is changed.
+	moved := feed
+	moved.URL = "https://habr.com/ru/rss"
+	if err := db.UpdateFeed(ctx, moved); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if readded, err := db.AddFeed(ctx, storage.Feed{URL: feed.URL, Enabled: true}); err != nil || readded == id {
+		t.Errorf("want feed with the former URL added with a new ID, got %v, %v", readded, err)
+	}
+
+	if err := db.DeleteFeed(ctx, id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := db.Feed(ctx, id); !errors.Is(err, storage.ErrFeedNotFound) {
+		t.Errorf("want error %v for deleted feed, got %v", storage.ErrFeedNotFound, err)
+	}
+	if err := db.DeleteFeed(ctx, id); !errors.Is(err, storage.ErrFeedNotFound) {
+		t.Errorf("want error %v for deleted feed, got %v", storage.ErrFeedNotFound, err)
+	}
+	if err := db.UpdateFeed(ctx, moved); !errors.Is(err, storage.ErrFeedNotFound) {
+		t.Errorf("want error %v for deleted feed, got %v", storage.ErrFeedNotFound, err)
+	}
+}
+
+func testDuplicates(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	published := time.Date(2024, 8, 13, 12, 0, 0, 0, time.UTC)
+	original := storage.Post{
+		Title:     "Go 1.23 released with range over function iterators",
+		Content:   "Release notes",
+		Summary:   "The Go team is happy to announce the release of Go 1.23, which brings iterators, telemetry and many toolchain improvements.",
+		Published: published,
+		Link:      "https://go.dev/blog/go1.23",
+		Source:    storage.Source{URL: "https://go.dev/blog/feed.atom", Name: "Go Blog"},
+	}
+	// The same link with tracking parameters.
+	tracked := original
+	tracked.Link = "http://www.go.dev/blog/go1.23/?utm_source=weekly"
+	tracked.Published = published.Add(time.Hour)
+	tracked.Source = storage.Source{URL: "https://golangweekly.com/rss", Name: "Golang Weekly"}
+	// The same story under another link.
+	syndicated := original
+	syndicated.Title += "!"
+	syndicated.Link = "https://habr.com/ru/news/go123"
+	syndicated.Published = published.Add(2 * time.Hour)
+	syndicated.Source = storage.Source{URL: "https://habr.com/rss", Name: "Habr"}
+	unrelated := storage.Post{Title: "Post 2", Published: published, Link: "https://news.today/articles/2"}
+
+	if err := db.AddPosts(ctx, []storage.Post{original, tracked, syndicated, unrelated}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	posts, _, err := db.LatestPosts(ctx, storage.PostFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("want 2 canonical posts, got %d posts: %+v", len(posts), posts)
+	}
+
+	originalID := uuid.NewV5(uuid.NamespaceURL, original.Link)
+	post, err := db.Post(ctx, originalID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantAlternates := []storage.Alternate{
+		{ID: uuid.NewV5(uuid.NamespaceURL, tracked.Link), Link: tracked.Link, Source: tracked.Source},
+		{ID: uuid.NewV5(uuid.NamespaceURL, syndicated.Link), Link: syndicated.Link, Source: syndicated.Source},
+	}
+	if !reflect.DeepEqual(post.Alternates, wantAlternates) {
+		t.Errorf("want alternates\n%+v\ngot\n%+v", wantAlternates, post.Alternates)
+	}
+
+	// An alternate lists the canonical post and the other copies.
+	post, err = db.Post(ctx, wantAlternates[1].ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(post.Alternates) != 2 || post.Alternates[0].ID != originalID {
+		t.Errorf("want canonical post first among 2 alternates, got %+v", post.Alternates)
+	}
+
+	// Updating the canonical post keeps the group intact.
+	if _, err := db.AddPost(ctx, original); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	posts, _, _ = db.LatestPosts(ctx, storage.PostFilter{}, 1, 10)
+	if len(posts) != 2 {
+		t.Errorf("want 2 canonical posts after update, got %d", len(posts))
+	}
+
+	// The alternates of a deleted post are listed on their own.
+	if _, err := db.DeletePosts(ctx, []uuid.UUID{originalID}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	posts, _, err = db.LatestPosts(ctx, storage.PostFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 3 {
+		t.Errorf("want 2 former alternates listed with the unrelated post, got %+v", posts)
+	}
+}
+
+func testRevisions(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	post := storage.Post{
+		Title:     "Go 1.23 is released",
+		Content:   "<p>Release notes</p>",
+		Published: time.Date(2024, 8, 13, 12, 0, 0, 0, time.UTC),
+		Link:      "https://go.dev/blog/go1.23",
+		Source:    storage.Source{URL: "https://go.dev/blog/feed.atom", Name: "Go Blog"},
+	}
+	id, err := db.AddPost(ctx, post)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// An unchanged post makes no revision, nor does a repeated edit.
+	edited := post
+	edited.Title = "Go 1.23 released with iterators"
+	if err := db.AddPosts(ctx, []storage.Post{post, edited, edited}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rewritten := edited
+	rewritten.Content = "<p>Updated release notes</p>"
+	if _, err := db.AddPost(ctx, rewritten); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	revisions, err := db.Revisions(ctx, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("want 2 revisions, got %+v", revisions)
+	}
+	wantChanges := [][]storage.Change{
+		{{Field: "content", Old: post.Content, New: rewritten.Content}},
+		{{Field: "title", Old: post.Title, New: edited.Title}},
+	}
+	for i, r := range revisions {
+		if !reflect.DeepEqual(r.Changes, wantChanges[i]) {
+			t.Errorf("want revision #%d changes %+v, got %+v", i, wantChanges[i], r.Changes)
+		}
+		if r.Source != post.Source || r.Changed.IsZero() {
+			t.Errorf("want revision #%d from %+v with time, got %+v", i, post.Source, r)
+		}
+	}
+
+	// The search index follows the edit.
+	results, _, err := db.SearchPosts(ctx, "iterators", storage.PostFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != id {
+		t.Errorf("want edited post found by new title, got %+v", results)
+	}
+
+	if _, err := db.Revisions(ctx, uuid.Must(uuid.NewV4())); !errors.Is(err, storage.ErrPostNotFound) {
+		t.Errorf("want error %v for unknown post, got %v", storage.ErrPostNotFound, err)
+	}
+}
+
+func testRetention(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+
+	day := time.Date(2024, 8, 13, 0, 0, 0, 0, time.UTC)
+	goBlog := storage.Source{URL: "https://go.dev/blog/feed.atom", Name: "Go Blog"}
+	rustBlog := storage.Source{URL: "https://blog.rust-lang.org/feed.xml", Name: "Rust Blog"}
+	var posts []storage.Post
+	for i := range 4 {
+		posts = append(posts,
+			storage.Post{Title: "Go", Link: fmt.Sprintf("https://go.dev/blog/%d", i), Published: day.AddDate(0, 0, i), Source: goBlog},
+			storage.Post{Title: "Rust", Link: fmt.Sprintf("https://blog.rust-lang.org/%d", i), Published: day.AddDate(0, 0, i).Add(time.Hour), Source: rustBlog},
+		)
+	}
+	if err := db.AddPosts(ctx, posts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	id := func(p storage.Post) uuid.UUID { return uuid.NewV5(uuid.NamespaceURL, p.Link) }
+
+	// Posts of the first day are too old, the Go post of the second day is the
+	// third latest of its source and the Rust one is kept.
+	policy := storage.Retention{Before: day.AddDate(0, 0, 1), MaxPerSource: 2, Keep: []uuid.UUID{id(posts[3])}}
+	got, err := db.ExpiredPosts(ctx, policy, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []uuid.UUID{id(posts[0]), id(posts[1]), id(posts[2])}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want expired posts %v, got %v", want, got)
+	}
+
+	if got, _ := db.ExpiredPosts(ctx, policy, 1); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("want the oldest expired post %v, got %v", want[0], got)
+	}
+	if got, err := db.ExpiredPosts(ctx, policy, -1); err != nil || len(got) != 0 {
+		t.Errorf("want no expired posts for a negative limit, got %v, %v", got, err)
+	}
+	if got, _ := db.ExpiredPosts(ctx, storage.Retention{}, 10); len(got) != 0 {
+		t.Errorf("want no expired posts without a policy, got %v", got)
+	}
+
+	removed, err := db.DeletePosts(ctx, append(want, uuid.Must(uuid.NewV4())))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != len(want) {
+		t.Errorf("want %d posts removed, got %d", len(want), removed)
+	}
+	if _, err := db.Post(ctx, want[0]); !errors.Is(err, storage.ErrPostNotFound) {
+		t.Errorf("want error %v for removed post, got %v", storage.ErrPostNotFound, err)
+	}
+	if got, _ := db.ExpiredPosts(ctx, policy, 10); len(got) != 0 {
+		t.Errorf("want no expired posts left, got %v", got)
+	}
+	results, _, err := db.SearchPosts(ctx, "go", storage.PostFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("want removed posts left out of search, got %d results", len(results))
+	}
+
+	sources, err := db.Sources(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantSources := []storage.SourceStat{{Source: rustBlog, Posts: 3}, {Source: goBlog, Posts: 2}}
+	if !reflect.DeepEqual(sources, wantSources) {
+		t.Errorf("want sources %+v, got %+v", wantSources, sources)
+	}
+}
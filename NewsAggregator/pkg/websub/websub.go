@@ -0,0 +1,510 @@
+// Package websub subscribes to the WebSub (PubSubHubbub) hubs of feeds, so
+// the hubs push the new copies of the feeds as soon as they are published.
+// See https://www.w3.org/TR/websub/.
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultLeaseSeconds is the lease requested when leaseSeconds is not set.
+	defaultLeaseSeconds = 10 * 24 * 60 * 60
+	// defaultCheckInterval is how often the subscriptions are checked for renewal.
+	defaultCheckInterval = time.Minute
+	// verifyTimeout is how long a hub has to verify a subscription request.
+	verifyTimeout = 10 * time.Minute
+	// requestTimeout limits a request to a hub.
+	requestTimeout = 15 * time.Second
+	// maxPushSize limits the size of a pushed feed.
+	maxPushSize = 20 << 20
+	// maxQueuedPushes limits the pushed feeds waiting for Deliver.
+	maxQueuedPushes = 64
+	// pushRetryAfter is the delay the hubs are asked to redeliver after when the queue is full.
+	pushRetryAfter = 60
+)
+
+var ErrInvalidConfig = fmt.Errorf("invalid websub config")
+
+// Config is the WebSub subscriber config. Subscriptions are disabled unless
+// CallbackURL is set.
+type Config struct {
+	// CallbackURL is the public address of the callback endpoint the hubs
+	// reach the aggregator at, e.g. https://news.example.com/websub/.
+	CallbackURL  string `toml:"callbackURL"`
+	LeaseSeconds int    `toml:"leaseSeconds"` // Lease requested from the hubs.
+}
+
+// Enabled reports whether the feeds are subscribed to.
+func (c Config) Enabled() bool {
+	return c.CallbackURL != ""
+}
+
+type state int
+
+const (
+	pending       state = iota // Requested, waiting for the hub to verify.
+	active                     // Verified, the hub pushes updates until the lease expires.
+	unsubscribing              // Unsubscription requested, waiting for the hub to verify.
+)
+
+// subscription is a subscription of a feed to its hub.
+type subscription struct {
+	id        string // Last element of the callback path.
+	feed      string // Address of the feed in the aggregator.
+	hub       string
+	topic     string // Address the hub knows the feed by.
+	secret    string // Key of the signatures of the pushed content.
+	state     state
+	requested time.Time // Time of the last request to the hub.
+	verified  time.Time // Time of the last verification by the hub.
+	lease     time.Duration
+	expires   time.Time
+}
+
+// push is a copy of a feed pushed by its hub.
+type push struct {
+	feed string
+	body []byte
+}
+
+// Subscriber keeps the subscriptions of the feeds to their hubs and serves the
+// callback endpoint the hubs verify the subscriptions at and push the updates
+// to. Every subscription has its own callback address and secret, and pushed
+// content that is not signed with the secret is ignored. Active subscriptions
+// are renewed before their lease expires; those of the feeds that are no
+// longer wanted are cancelled. Subscriptions are kept in memory, so after a
+// restart the feeds are subscribed to again as they are polled.
+type Subscriber struct {
+	Lease         time.Duration
+	CheckInterval time.Duration // How often the subscriptions are checked for renewal.
+	// Deliver gets the copies of the feeds pushed by the hubs. The pushes are
+	// acknowledged before they are delivered, Run calls Deliver for them one
+	// at a time, so the hubs do not wait for the feeds to be processed.
+	Deliver func(ctx context.Context, feedURL string, body []byte)
+	// Wanted, if set, tells whether the feed is still to be subscribed to.
+	Wanted func(feedURL string) bool
+
+	callback *url.URL
+	client   *http.Client
+
+	pushes chan push // Pushed content waiting for Deliver.
+
+	mu     sync.Mutex
+	subs   map[string]*subscription // By id.
+	byFeed map[string]*subscription // Subscriptions that are not being cancelled, by feed.
+}
+
+// New creates a subscriber from the config. If client is nil,
+// http.DefaultClient is used.
+func New(conf Config, client *http.Client) (*Subscriber, error) {
+	callback, err := url.Parse(conf.CallbackURL)
+	if err != nil || callback.Host == "" || (callback.Scheme != "http" && callback.Scheme != "https") {
+		return nil, fmt.Errorf("%w: callbackURL must be an absolute http(s) URL", ErrInvalidConfig)
+	}
+	if conf.LeaseSeconds < 0 {
+		return nil, fmt.Errorf("%w: negative leaseSeconds", ErrInvalidConfig)
+	}
+	if conf.LeaseSeconds == 0 {
+		conf.LeaseSeconds = defaultLeaseSeconds
+	}
+	if !strings.HasSuffix(callback.Path, "/") {
+		callback.Path += "/"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	s := Subscriber{
+		Lease:         time.Duration(conf.LeaseSeconds) * time.Second,
+		CheckInterval: defaultCheckInterval,
+		callback:      callback,
+		client:        client,
+		pushes:        make(chan push, maxQueuedPushes),
+		subs:          make(map[string]*subscription),
+		byFeed:        make(map[string]*subscription),
+	}
+
+	return &s, nil
+}
+
+// Path returns the path of the callback endpoint, the prefix of the callback
+// addresses of the subscriptions.
+func (s *Subscriber) Path() string {
+	return s.callback.Path
+}
+
+// Subscribed reports whether the hub of the feed pushes its updates.
+func (s *Subscriber) Subscribed(feedURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.byFeed[feedURL]
+	return ok && sub.state == active && time.Now().Before(sub.expires)
+}
+
+// Subscribe subscribes the feed to the hub by topic. Nothing is requested if
+// the feed is already subscribed to the same hub and topic or the hub has yet
+// to verify the subscription; a verified subscription to another hub or topic
+// is cancelled. The hub verifies the subscription at the callback endpoint later.
+func (s *Subscriber) Subscribe(ctx context.Context, feedURL, hub, topic string) error {
+	s.mu.Lock()
+	prev, ok := s.byFeed[feedURL]
+	if ok && prev.hub == hub && prev.topic == topic {
+		if prev.state == active || time.Since(prev.requested) < verifyTimeout {
+			s.mu.Unlock()
+			return nil
+		}
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	sub := &subscription{
+		id:        id,
+		feed:      feedURL,
+		hub:       hub,
+		topic:     topic,
+		secret:    secret,
+		state:     pending,
+		requested: time.Now(),
+	}
+	// A subscription the hub has not verified is only forgotten, there is
+	// nothing to unsubscribe from.
+	wasVerified := ok && prev.state != pending
+	if wasVerified {
+		s.cancel(prev)
+	} else if ok {
+		s.drop(prev)
+	}
+	s.subs[id] = sub
+	s.byFeed[feedURL] = sub
+	s.mu.Unlock()
+
+	if wasVerified {
+		if err := s.request(ctx, prev, "unsubscribe"); err != nil {
+			log.Warnf("[websub] unable to unsubscribe %s from %s: %v", prev.topic, prev.hub, err)
+		}
+	}
+
+	if err := s.request(ctx, sub, "subscribe"); err != nil {
+		s.mu.Lock()
+		s.drop(sub)
+		s.mu.Unlock()
+		return fmt.Errorf("unable to subscribe %s to %s: %w", topic, hub, err)
+	}
+	log.Infof("[websub] subscription of %s to %s requested", topic, hub)
+
+	return nil
+}
+
+// Run passes the pushed content to Deliver, renews the subscriptions that are
+// about to expire and cancels those of the unwanted feeds once per
+// CheckInterval until ctx is cancelled. The content still queued then is dropped,
+// the feeds are polled anyway.
+func (s *Subscriber) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// A slow delivery, e.g. of a feed with full text, does not hold up the renewals.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case p := <-s.pushes:
+				if s.Deliver != nil {
+					s.Deliver(ctx, p.feed, p.body)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(s.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check renews the subscriptions whose lease is less than a tenth away from
+// expiring, cancels those of the unwanted feeds and forgets the expired
+// subscriptions and the requests the hubs have not verified in time.
+func (s *Subscriber) check(ctx context.Context) {
+	// Wanted may call into the parser, so it is called without holding s.mu.
+	unwanted := make(map[string]bool)
+	if s.Wanted != nil {
+		for _, feed := range s.feeds() {
+			if !s.Wanted(feed) {
+				unwanted[feed] = true
+			}
+		}
+	}
+
+	var renew, cancel []*subscription
+
+	s.mu.Lock()
+	now := time.Now()
+	for _, sub := range s.subs {
+		switch {
+		case sub.state != active && now.Sub(sub.requested) > verifyTimeout:
+			s.drop(sub)
+		case sub.state != active:
+		case !now.Before(sub.expires):
+			log.Warnf("[websub] subscription of %s to %s expired", sub.topic, sub.hub)
+			s.drop(sub)
+		case unwanted[sub.feed]:
+			s.cancel(sub)
+			sub.requested = now
+			cancel = append(cancel, sub)
+		case sub.expires.Sub(now) < sub.lease/10 && !sub.requested.After(sub.verified):
+			// The renewal is requested once, when the last request has been verified.
+			sub.requested = now
+			renew = append(renew, sub)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sub := range cancel {
+		if err := s.request(ctx, sub, "unsubscribe"); err != nil {
+			log.Warnf("[websub] unable to unsubscribe %s from %s: %v", sub.topic, sub.hub, err)
+		}
+	}
+	for _, sub := range renew {
+		if err := s.request(ctx, sub, "subscribe"); err != nil {
+			log.Warnf("[websub] unable to renew subscription of %s to %s: %v", sub.topic, sub.hub, err)
+		}
+	}
+}
+
+// feeds returns the feeds with active subscriptions.
+func (s *Subscriber) feeds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var feeds []string
+	for feed, sub := range s.byFeed {
+		if sub.state == active {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds
+}
+
+// cancel marks the subscription as being cancelled. Must be called with s.mu held.
+func (s *Subscriber) cancel(sub *subscription) {
+	sub.state = unsubscribing
+	if s.byFeed[sub.feed] == sub {
+		delete(s.byFeed, sub.feed)
+	}
+}
+
+// drop forgets the subscription. Must be called with s.mu held.
+func (s *Subscriber) drop(sub *subscription) {
+	delete(s.subs, sub.id)
+	if s.byFeed[sub.feed] == sub {
+		delete(s.byFeed, sub.feed)
+	}
+}
+
+// request sends a subscription request of the given mode to the hub of the subscription.
+func (s *Subscriber) request(ctx context.Context, sub *subscription, mode string) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	form := url.Values{
+		"hub.callback": {s.callback.JoinPath(sub.id).String()},
+		"hub.mode":     {mode},
+		"hub.topic":    {sub.topic},
+	}
+	if mode == "subscribe" {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(s.Lease.Seconds())))
+		form.Set("hub.secret", sub.secret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// ServeHTTP serves the callback endpoint: GET requests verify the intents of
+// the subscriber, POST requests push the content.
+func (s *Subscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, s.callback.Path)
+
+	switch r.Method {
+	case http.MethodGet:
+		s.verify(w, r, id)
+	case http.MethodPost:
+		s.receive(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verify answers the verification request of the hub with the challenge if
+// the subscriber did request the subscription or unsubscription.
+func (s *Subscriber) verify(w http.ResponseWriter, r *http.Request, id string) {
+	q := r.URL.Query()
+	mode, topic, challenge := q.Get("hub.mode"), q.Get("hub.topic"), q.Get("hub.challenge")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[id]
+	if !ok || sub.topic != topic {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case mode == "subscribe" && sub.state != unsubscribing:
+		lease, err := strconv.Atoi(q.Get("hub.lease_seconds"))
+		if err != nil || lease <= 0 {
+			lease = int(s.Lease.Seconds())
+		}
+		sub.state = active
+		sub.verified = time.Now()
+		sub.lease = time.Duration(lease) * time.Second
+		sub.expires = sub.verified.Add(sub.lease)
+		log.Infof("[websub] subscription of %s to %s verified for %v", sub.topic, sub.hub, sub.lease)
+	case mode == "unsubscribe" && sub.state == unsubscribing:
+		s.drop(sub)
+		log.Infof("[websub] subscription of %s to %s cancelled", sub.topic, sub.hub)
+	case mode == "denied":
+		s.drop(sub)
+		log.Warnf("[websub] subscription of %s to %s denied: %s", sub.topic, sub.hub, q.Get("hub.reason"))
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	io.WriteString(w, challenge)
+}
+
+// receive queues the content pushed by the hub for Deliver if it is signed with
+// the secret of the subscription. As the spec requires, content with a missing
+// or invalid signature is acknowledged too, but ignored. If the queue is full,
+// the hub is asked to push the content again later.
+func (s *Subscriber) receive(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	sub, ok := s.subs[id]
+	var feed, secret string
+	if ok {
+		feed, secret = sub.feed, sub.secret
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown subscription", http.StatusGone)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPushSize))
+	if err != nil {
+		http.Error(w, "Unable to read body", http.StatusBadRequest)
+		return
+	}
+	if !validSignature(r.Header.Get("X-Hub-Signature"), secret, body) {
+		log.Warnf("[websub] content of %s pushed with invalid signature, ignored", feed)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	select {
+	case s.pushes <- push{feed: feed, body: body}:
+	default:
+		log.Warnf("[websub] too many pushes queued, content of %s refused", feed)
+		w.Header().Set("Retry-After", strconv.Itoa(pushRetryAfter))
+		http.Error(w, "Too many pushes", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validSignature reports whether the X-Hub-Signature header value, like
+// sha256=<hex>, is the HMAC of the body with the secret.
+func validSignature(signature, secret string, body []byte) bool {
+	method, sum, ok := strings.Cut(signature, "=")
+	if !ok {
+		return false
+	}
+	var h func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return false
+	}
+	want, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+// randomHex returns n random bytes in hex.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,435 @@
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testFeedURL = "https://example.com/feed"
+	testTopic   = "https://example.com/feed.xml"
+	testContent = `<rss version="2.0"><channel><title>Pushed</title></channel></rss>`
+)
+
+// testHub is a stand-in WebSub hub. It accepts the subscription requests,
+// verifies them at the callback address right away granting the lease it is
+// configured with and pushes content to the verified subscribers.
+type testHub struct {
+	t      *testing.T
+	srv    *httptest.Server
+	lease  int  // Lease granted to the subscribers in seconds.
+	silent bool // Accept the requests, but never verify them.
+
+	mu       sync.Mutex
+	requests []url.Values
+	callback string
+	secret   string
+}
+
+func newTestHub(t *testing.T, lease int) *testHub {
+	t.Helper()
+
+	h := &testHub{t: t, lease: lease}
+	h.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		form := r.PostForm
+
+		h.mu.Lock()
+		h.requests = append(h.requests, form)
+		h.mu.Unlock()
+
+		w.WriteHeader(http.StatusAccepted)
+		if !h.silent {
+			go h.verify(form)
+		}
+	}))
+	t.Cleanup(h.srv.Close)
+
+	return h
+}
+
+// verify confirms the intent of the subscriber as a hub does after it has
+// accepted the request.
+func (h *testHub) verify(form url.Values) {
+	q := url.Values{
+		"hub.mode":      {form.Get("hub.mode")},
+		"hub.topic":     {form.Get("hub.topic")},
+		"hub.challenge": {"challenge-" + form.Get("hub.mode")},
+	}
+	if form.Get("hub.mode") == "subscribe" {
+		q.Set("hub.lease_seconds", strconv.Itoa(h.lease))
+	}
+
+	resp, err := http.Get(form.Get("hub.callback") + "?" + q.Encode())
+	if err != nil {
+		h.t.Errorf("unexpected error while verifying: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != q.Get("hub.challenge") {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if form.Get("hub.mode") == "subscribe" {
+		h.callback, h.secret = form.Get("hub.callback"), form.Get("hub.secret")
+	} else {
+		h.callback, h.secret = "", ""
+	}
+}
+
+// subscriber returns the callback address and the secret of the verified
+// subscriber, empty if there is none.
+func (h *testHub) subscriber() (callback, secret string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.callback, h.secret
+}
+
+// modes returns the modes of the requests the hub has received.
+func (h *testHub) modes() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var modes []string
+	for _, r := range h.requests {
+		modes = append(modes, r.Get("hub.mode"))
+	}
+	return modes
+}
+
+// publish pushes the content to the subscriber signed with the secret and
+// returns the status of the response.
+func (h *testHub) publish(content, secret string) int {
+	h.t.Helper()
+
+	callback, _ := h.subscriber()
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+
+	req, _ := http.NewRequest(http.MethodPost, callback, strings.NewReader(content))
+	req.Header.Set("Content-Type", "application/rss+xml")
+	req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatalf("unexpected error while publishing: %v", err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+// newTestSubscriber returns a subscriber with its callback endpoint served by
+// a test server. The pushed content is sent to delivered.
+func newTestSubscriber(t *testing.T, delivered chan<- string) *Subscriber {
+	t.Helper()
+
+	var sub *Subscriber
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	sub, err := New(Config{CallbackURL: srv.URL + "/websub"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sub.Deliver = func(_ context.Context, feedURL string, body []byte) {
+		delivered <- feedURL + " " + string(body)
+	}
+
+	return sub
+}
+
+// waitFor fails the test unless cond holds within a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("want %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSubscriber_Push(t *testing.T) {
+	hub := newTestHub(t, 3600)
+	delivered := make(chan string, 1)
+	sub := newTestSubscriber(t, delivered)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sub.Run(ctx)
+
+	if err := sub.Subscribe(context.Background(), testFeedURL, hub.srv.URL, testTopic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, "subscription verified", func() bool {
+		callback, _ := hub.subscriber()
+		return sub.Subscribed(testFeedURL) && callback != ""
+	})
+
+	// A repeated request of the same subscription is not sent to the hub.
+	if err := sub.Subscribe(context.Background(), testFeedURL, hub.srv.URL, testTopic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if modes := hub.modes(); len(modes) != 1 {
+		t.Errorf("want 1 request to the hub, got %v", modes)
+	}
+
+	_, secret := hub.subscriber()
+	if status := hub.publish(testContent, secret); status != http.StatusAccepted {
+		t.Errorf("want status %d, got %d", http.StatusAccepted, status)
+	}
+	select {
+	case got := <-delivered:
+		if want := testFeedURL + " " + testContent; got != want {
+			t.Errorf("want delivered %q, got %q", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("want pushed content delivered")
+	}
+
+	// Content signed with another secret is acknowledged, but ignored.
+	if status := hub.publish(testContent, "wrong"); status != http.StatusAccepted {
+		t.Errorf("want status %d, got %d", http.StatusAccepted, status)
+	}
+	select {
+	case got := <-delivered:
+		t.Errorf("want content with invalid signature ignored, got %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubscriber_PushQueue(t *testing.T) {
+	hub := newTestHub(t, 3600)
+	sub := newTestSubscriber(t, make(chan string, 1))
+
+	// Deliver takes as long as the test wants it to, as with full text downloads.
+	release := make(chan struct{})
+	delivering := make(chan struct{}, 1)
+	sub.Deliver = func(ctx context.Context, feedURL string, body []byte) {
+		select {
+		case delivering <- struct{}{}:
+		default:
+		}
+		<-release
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		sub.Run(ctx)
+		close(done)
+	}()
+
+	if err := sub.Subscribe(context.Background(), testFeedURL, hub.srv.URL, testTopic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, "subscription verified", func() bool {
+		callback, _ := hub.subscriber()
+		return sub.Subscribed(testFeedURL) && callback != ""
+	})
+	_, secret := hub.subscriber()
+
+	// The push is acknowledged while it is still being delivered.
+	if status := hub.publish(testContent, secret); status != http.StatusAccepted {
+		t.Errorf("want status %d, got %d", http.StatusAccepted, status)
+	}
+	<-delivering
+
+	for range maxQueuedPushes {
+		if status := hub.publish(testContent, secret); status != http.StatusAccepted {
+			t.Fatalf("want queued push accepted, got status %d", status)
+		}
+	}
+	if status := hub.publish(testContent, secret); status != http.StatusServiceUnavailable {
+		t.Errorf("want status %d for push beyond the queue, got %d", http.StatusServiceUnavailable, status)
+	}
+
+	close(release)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("want Run stopped once ctx is cancelled")
+	}
+}
+
+func TestSubscriber_Verify(t *testing.T) {
+	sub := newTestSubscriber(t, make(chan string, 1))
+	hub := newTestHub(t, 3600)
+	if err := sub.Subscribe(context.Background(), testFeedURL, hub.srv.URL, testTopic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, "subscription verified", func() bool {
+		callback, _ := hub.subscriber()
+		return sub.Subscribed(testFeedURL) && callback != ""
+	})
+	callback, _ := hub.subscriber()
+
+	tests := []struct {
+		name   string
+		target string
+		mode   string
+		topic  string
+	}{
+		{"unknown subscription", sub.callback.JoinPath("unknown").String(), "subscribe", testTopic},
+		{"another topic", callback, "subscribe", "https://example.com/other"},
+		{"unrequested unsubscription", callback, "unsubscribe", testTopic},
+	}
+	for _, tt := range tests {
+		q := url.Values{"hub.mode": {tt.mode}, "hub.topic": {tt.topic}, "hub.challenge": {"challenge"}}
+		req := httptest.NewRequest(http.MethodGet, tt.target+"?"+q.Encode(), nil)
+		rr := httptest.NewRecorder()
+		sub.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: want status %d, got %d", tt.name, http.StatusNotFound, rr.Code)
+		}
+	}
+
+	// The hub may deny a subscription that it has verified before.
+	q := url.Values{"hub.mode": {"denied"}, "hub.topic": {testTopic}, "hub.reason": {"banned"}}
+	req := httptest.NewRequest(http.MethodGet, callback+"?"+q.Encode(), nil)
+	sub.ServeHTTP(httptest.NewRecorder(), req)
+	if sub.Subscribed(testFeedURL) {
+		t.Error("want denied subscription dropped")
+	}
+}
+
+func TestSubscriber_ReplacePending(t *testing.T) {
+	hub := newTestHub(t, 3600)
+	hub.silent = true
+	sub := newTestSubscriber(t, make(chan string, 1))
+
+	if err := sub.Subscribe(context.Background(), testFeedURL, hub.srv.URL, testTopic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The hub has not verified the request in time, so it is requested again.
+	sub.mu.Lock()
+	sub.byFeed[testFeedURL].requested = time.Now().Add(-verifyTimeout - time.Second)
+	sub.mu.Unlock()
+	if err := sub.Subscribe(context.Background(), testFeedURL, hub.srv.URL, testTopic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The feed has moved to another topic before the hub verified it.
+	if err := sub.Subscribe(context.Background(), testFeedURL, hub.srv.URL, "https://example.com/atom.xml"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if modes := hub.modes(); !slices.Equal(modes, []string{"subscribe", "subscribe", "subscribe"}) {
+		t.Errorf("want only subscription requests sent to the hub, got %v", modes)
+	}
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if len(sub.subs) != 1 {
+		t.Errorf("want replaced pending subscriptions forgotten, got %d subscriptions", len(sub.subs))
+	}
+}
+
+func TestSubscriber_Renew(t *testing.T) {
+	hub := newTestHub(t, 1)
+	sub := newTestSubscriber(t, make(chan string, 1))
+	sub.CheckInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sub.Run(ctx)
+
+	if err := sub.Subscribe(ctx, testFeedURL, hub.srv.URL, testTopic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, "subscription renewed before the lease expires", func() bool {
+		return len(hub.modes()) >= 2 && sub.Subscribed(testFeedURL)
+	})
+	if modes := hub.modes(); modes[1] != "subscribe" {
+		t.Errorf("want renewal requested, got %v", modes)
+	}
+}
+
+func TestSubscriber_Unwanted(t *testing.T) {
+	hub := newTestHub(t, 3600)
+	sub := newTestSubscriber(t, make(chan string, 1))
+	sub.CheckInterval = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	wanted := true
+	sub.Wanted = func(feedURL string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return wanted
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sub.Run(ctx)
+
+	if err := sub.Subscribe(ctx, testFeedURL, hub.srv.URL, testTopic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, "subscription verified", func() bool { return sub.Subscribed(testFeedURL) })
+
+	mu.Lock()
+	wanted = false
+	mu.Unlock()
+
+	waitFor(t, "unwanted subscription cancelled", func() bool {
+		sub.mu.Lock()
+		defer sub.mu.Unlock()
+		return len(sub.subs) == 0
+	})
+	if modes := hub.modes(); len(modes) != 2 || modes[1] != "unsubscribe" {
+		t.Errorf("want unsubscription requested, got %v", modes)
+	}
+	if sub.Subscribed(testFeedURL) {
+		t.Error("want feed unsubscribed")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, conf := range []Config{
+		{CallbackURL: "/websub/"},
+		{CallbackURL: "ftp://example.com/websub/"},
+		{CallbackURL: "https://example.com/websub/", LeaseSeconds: -1},
+	} {
+		if _, err := New(conf, nil); err == nil {
+			t.Errorf("want error for config %+v, got nil", conf)
+		}
+	}
+
+	sub, err := New(Config{CallbackURL: "https://example.com/websub"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sub.Path() != "/websub/" {
+		t.Errorf("want path /websub/, got %s", sub.Path())
+	}
+	if sub.Lease != defaultLeaseSeconds*time.Second {
+		t.Errorf("want default lease, got %v", sub.Lease)
+	}
+}
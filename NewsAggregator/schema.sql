@@ -8,7 +8,62 @@ DROP TABLE IF EXISTS posts;
 CREATE TABLE posts (
     id UUID PRIMARY KEY,
     title TEXT NOT NULL,
-    content TEXT NOT NULL,
+    content TEXT NOT NULL, -- Sanitized HTML.
+    summary TEXT NOT NULL DEFAULT '', -- Plain-text excerpt of the content.
     published TIMESTAMP WITH TIME ZONE NOT NULL, -- All posts are converted to UTC before being saved.
-    link TEXT NOT NULL
+    link TEXT NOT NULL,
+    source_url TEXT NOT NULL DEFAULT '', -- URL of the feed the post was taken from.
+    source_name TEXT NOT NULL DEFAULT '',
+    authors TEXT[], -- Author names, NULL if the feed has none.
+    categories TEXT[],
+    image_url TEXT NOT NULL DEFAULT '',
+    enclosures JSONB, -- Array of {"url", "type", "length"} objects.
+    lang TEXT NOT NULL DEFAULT '', -- ISO 639-1 code, empty if the language is unknown.
+    canonical_link TEXT NOT NULL DEFAULT '', -- Link without tracking parameters, see package dedup.
+    fingerprint BIGINT NOT NULL DEFAULT 0, -- SimHash of the title and summary, 0 if the text is too short.
+    duplicate_of UUID REFERENCES posts (id) ON DELETE SET NULL, -- Canonical copy of the story, NULL for canonical posts.
+    -- Words of the title and the content for SearchPosts, stemmed as Russian and as English.
+    -- The HTML tags of the content are skipped by the parser.
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', title), 'A') ||
+        setweight(to_tsvector('english', title), 'A') ||
+        setweight(to_tsvector('russian', content), 'B') ||
+        setweight(to_tsvector('english', content), 'B')
+    ) STORED
+);
+
+CREATE INDEX posts_source_url_idx ON posts (source_url);
+CREATE INDEX posts_published_idx ON posts (published, id); -- Order of the lists and their cursors.
+CREATE INDEX posts_lang_idx ON posts (lang);
+CREATE INDEX posts_canonical_link_idx ON posts (canonical_link);
+CREATE INDEX posts_duplicate_of_idx ON posts (duplicate_of);
+CREATE INDEX posts_search_vector_idx ON posts USING GIN (search_vector);
+
+DROP TABLE IF EXISTS post_revisions;
+
+CREATE TABLE post_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    post_id UUID NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    source_url TEXT NOT NULL DEFAULT '', -- Feed the changed item came from.
+    source_name TEXT NOT NULL DEFAULT '',
+    changes JSONB NOT NULL -- Array of {"field", "old", "new"} objects.
+);
+
+CREATE INDEX post_revisions_post_id_idx ON post_revisions (post_id, changed_at);
+
+DROP TABLE IF EXISTS feeds;
+
+CREATE TABLE feeds (
+    id UUID PRIMARY KEY,
+    url TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT '',
+    poll_interval INTEGER NOT NULL DEFAULT 0, -- Minutes, 0 means the parser default.
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_timeout INTEGER NOT NULL DEFAULT 0, -- Seconds, 0 means the fetcher default.
+    full_text BOOLEAN NOT NULL DEFAULT FALSE, -- Download full articles for the posts.
+    rules JSONB NOT NULL DEFAULT '{}', -- Include and exclude rules for the feed items.
+    scraper JSONB -- CSS selectors of a site without a feed, NULL for feeds.
 );
\ No newline at end of file